@@ -0,0 +1,139 @@
+// Package bazelshim renders the files the protocolbuffers plugins build with Bazel in
+// the protobuf repository: the BUILD file and C++ main (shim) of each plugin, and the
+// patches disabling experimental editions support.
+package bazelshim
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"text/template"
+
+	"golang.org/x/mod/semver"
+)
+
+const (
+	// minimumVersion is the first protobuf version the shims are rendered for. The
+	// shims of earlier versions are kept as they were created.
+	minimumVersion = "v21.8"
+)
+
+var (
+	//go:embed templates
+	templateFS embed.FS
+
+	// languages are the protocolbuffers plugins built from a shim, by plugin name.
+	languages = map[string]*language{
+		"protocolbuffers/cpp": {
+			name: "cpp",
+			experimentalEditions: &versionRange{
+				from:   "v25.0",
+				before: "v26.0",
+				patch:  "disable_cpp_editions.patch",
+			},
+		},
+		"protocolbuffers/csharp": {name: "csharp"},
+		"protocolbuffers/java":   {name: "java"},
+		"protocolbuffers/kotlin": {name: "kotlin"},
+		"protocolbuffers/objc": {
+			name: "objectivec",
+			experimentalEditions: &versionRange{
+				from:   "v25.3",
+				before: "v26.0",
+				patch:  "disable_objc_editions.patch",
+			},
+		},
+		"protocolbuffers/php": {name: "php"},
+		"protocolbuffers/pyi": {
+			name:    "pyi",
+			since:   "v23.2",
+			comment: "Create a standalone binary to generate Python .pyi files",
+		},
+		"protocolbuffers/python": {name: "python"},
+		"protocolbuffers/ruby":   {name: "ruby"},
+	}
+)
+
+// File is a rendered file of a plugin version directory.
+type File struct {
+	Name    string
+	Content []byte
+}
+
+type language struct {
+	// name is the name of the generator, such as "cpp" for cpp.cc and protoc-gen-cpp.
+	name string
+	// since is the first version with a shim, if later than minimumVersion.
+	since string
+	// comment is the comment of the BUILD target, if any.
+	comment string
+	// experimentalEditions are the versions whose generator declares editions support
+	// while editions were experimental: a patch removes the support.
+	experimentalEditions *versionRange
+}
+
+type versionRange struct {
+	from   string
+	before string
+	patch  string
+}
+
+func (r *versionRange) contains(version string) bool {
+	return r != nil && semver.Compare(version, r.from) >= 0 && semver.Compare(version, r.before) < 0
+}
+
+type templateData struct {
+	// Name is the name of the generator (see language.name).
+	Name string
+	// Version is the protobuf version, such as "v29.1".
+	Version string
+	// Comment is the comment of the BUILD target, if any.
+	Comment string
+}
+
+// Render returns the files rendered for the version of a plugin (such as
+// "protocolbuffers/cpp"): the BUILD file, the shim, then the patches. It returns no
+// files if the plugin version isn't built from a shim.
+func Render(pluginName string, version string) ([]File, error) {
+	lang, ok := languages[pluginName]
+	if !ok || !semver.IsValid(version) || semver.Compare(version, minimumVersion) < 0 {
+		return nil, nil
+	}
+	if lang.since != "" && semver.Compare(version, lang.since) < 0 {
+		return nil, nil
+	}
+	data := templateData{
+		Name:    lang.name,
+		Version: version,
+		Comment: lang.comment,
+	}
+	names := []string{"BUILD", lang.name + ".cc"}
+	if lang.experimentalEditions.contains(version) {
+		names = append(names, lang.experimentalEditions.patch)
+	}
+	files := make([]File, 0, len(names))
+	for _, name := range names {
+		content, err := render(name, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s for %s:%s: %w", name, pluginName, version, err)
+		}
+		files = append(files, File{Name: name, Content: content})
+	}
+	return files, nil
+}
+
+func render(name string, data templateData) ([]byte, error) {
+	tmpl, err := template.New(name+".gotext").Funcs(template.FuncMap{
+		"atLeast": func(version string) bool {
+			return semver.Compare(data.Version, version) >= 0
+		},
+	}).ParseFS(templateFS, "templates/"+name+".gotext")
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,60 @@
+package bazelshim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+	files, err := Render("protocolbuffers/cpp", "v25.1")
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	assert.Equal(t, "BUILD", files[0].Name)
+	assert.Equal(t, `cc_binary(
+    name = "protoc-gen-cpp",
+    srcs = ["cpp.cc"],
+    deps = [
+        "//:protoc_lib",
+    ],
+)
+`, string(files[0].Content))
+	assert.Equal(t, "cpp.cc", files[1].Name)
+	assert.Equal(t, `#include <google/protobuf/compiler/cpp/generator.h>
+#include <google/protobuf/compiler/plugin.h>
+
+int main(int argc, char *argv[]) {
+  google::protobuf::compiler::cpp::CppGenerator generator;
+  return google::protobuf::compiler::PluginMain(argc, argv, &generator);
+}
+`, string(files[1].Content))
+	assert.Equal(t, "disable_cpp_editions.patch", files[2].Name)
+
+	files, err = Render("protocolbuffers/kotlin", "v28.3")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Contains(t, string(files[1].Content), "#include <google/protobuf/compiler/java/kotlin_generator.h>\n")
+	assert.Contains(t, string(files[1].Content), "google::protobuf::compiler::java::KotlinGenerator generator;\n")
+	files, err = Render("protocolbuffers/kotlin", "v29.0")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Contains(t, string(files[1].Content), "#include <google/protobuf/compiler/kotlin/generator.h>\n")
+	assert.Contains(t, string(files[1].Content), "google::protobuf::compiler::kotlin::KotlinGenerator generator;\n")
+
+	files, err = Render("protocolbuffers/pyi", "v30.0")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Contains(t, string(files[0].Content), "# Create a standalone binary to generate Python .pyi files\ncc_binary(\n")
+
+	for _, unrendered := range [][2]string{
+		{"protocolbuffers/go", "v1.36.11"},
+		{"protocolbuffers/cpp", "v21.7"},
+		{"protocolbuffers/pyi", "v23.1"},
+	} {
+		files, err = Render(unrendered[0], unrendered[1])
+		require.NoError(t, err)
+		assert.Empty(t, files, unrendered)
+	}
+}
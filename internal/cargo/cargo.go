@@ -0,0 +1,248 @@
+// Package cargo checks the Cargo lockfiles of Rust plugins.
+package cargo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufremoteplugin/bufremotepluginconfig"
+)
+
+// Package is a package locked in a Cargo.lock file.
+type Package struct {
+	Name    string
+	Version string
+}
+
+// ParseLockfile returns the packages of a Cargo.lock file.
+func ParseLockfile(content []byte) ([]Package, error) {
+	var (
+		packages []Package
+		current  *Package
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "[[package]]":
+			packages = append(packages, Package{})
+			current = &packages[len(packages)-1]
+		case strings.HasPrefix(line, "["):
+			// Other tables (such as [metadata]) don't describe packages.
+			current = nil
+		case current != nil:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			switch strings.TrimSpace(key) {
+			case "name", "version":
+				unquoted, err := strconv.Unquote(strings.TrimSpace(value))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid value %s", lineNumber, strings.TrimSpace(value))
+				}
+				if strings.TrimSpace(key) == "name" {
+					current.Name = unquoted
+				} else {
+					current.Version = unquoted
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, pkg := range packages {
+		if pkg.Name == "" || pkg.Version == "" {
+			return nil, errors.New("package without a name or version")
+		}
+	}
+	return packages, nil
+}
+
+// CheckDeps checks that the registry.cargo deps of the plugin's buf.plugin.yaml agree
+// with its Cargo.lock: each dependency must be locked at a version matching its
+// requirement. It does nothing if the plugin has no Cargo registry config or no Cargo.lock.
+func CheckDeps(pluginVersionDir string) error {
+	pluginConfig, err := bufremotepluginconfig.ParseConfig(filepath.Join(pluginVersionDir, "buf.plugin.yaml"))
+	if err != nil {
+		return err
+	}
+	if pluginConfig.Registry == nil || pluginConfig.Registry.Cargo == nil {
+		return nil
+	}
+	content, err := os.ReadFile(filepath.Join(pluginVersionDir, "Cargo.lock"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	packages, err := ParseLockfile(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse Cargo.lock: %w", err)
+	}
+	var errs []error
+	for _, dep := range pluginConfig.Registry.Cargo.Deps {
+		var locked []string
+		matched := false
+		for _, pkg := range packages {
+			if pkg.Name != dep.Name {
+				continue
+			}
+			locked = append(locked, pkg.Version)
+			ok, err := MatchesRequirement(dep.VersionRequirement, pkg.Version)
+			if err != nil {
+				return fmt.Errorf("registry.cargo dep %s: %w", dep.Name, err)
+			}
+			matched = matched || ok
+		}
+		switch {
+		case len(locked) == 0:
+			errs = append(errs, fmt.Errorf("registry.cargo dep %s %q is not in Cargo.lock", dep.Name, dep.VersionRequirement))
+		case !matched:
+			errs = append(errs, fmt.Errorf("registry.cargo dep %s %q does not match Cargo.lock version %s", dep.Name, dep.VersionRequirement, strings.Join(locked, ", ")))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MatchesRequirement returns true if the version matches the Cargo version requirement,
+// such as "1.2.3" (a caret requirement), "~1.2", ">= 1.2, < 1.5", or "1.*".
+//
+// Ref: https://doc.rust-lang.org/cargo/reference/specifying-dependencies.html#version-requirement-syntax
+func MatchesRequirement(requirement string, version string) (bool, error) {
+	parsedVersion, err := parseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	for comparator := range strings.SplitSeq(requirement, ",") {
+		ok, err := matchesComparator(strings.TrimSpace(comparator), parsedVersion)
+		if err != nil {
+			return false, fmt.Errorf("invalid requirement %q: %w", requirement, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matchesComparator returns true if the version matches a single comparator of a
+// requirement.
+func matchesComparator(comparator string, version semver) (bool, error) {
+	operator := strings.TrimRight(comparator, "0123456789.*xX ")
+	partialString := strings.TrimSpace(comparator[len(operator):])
+	partial, err := parsePartial(partialString)
+	if err != nil {
+		return false, err
+	}
+	if operator == "" && strings.ContainsAny(partialString, "*xX") {
+		// A wildcard requirement ("1.2.*") matches the versions of the partial version.
+		operator = "="
+	}
+	// lower is the partial version with missing components set to 0, and upper is the
+	// first version after the versions matching the partial version ("1.2" matches
+	// 1.2.x, up to 1.3.0).
+	lower := semver{partial.get(0), partial.get(1), partial.get(2)}
+	upper := lower
+	switch len(partial) {
+	case 0:
+		upper = semver{maxComponent, 0, 0}
+	case 1:
+		upper = semver{lower[0] + 1, 0, 0}
+	case 2:
+		upper = semver{lower[0], lower[1] + 1, 0}
+	default:
+		upper[2]++
+	}
+	switch operator {
+	case "", "^":
+		// Compatible versions: the left-most non-zero component can't change.
+		switch {
+		case len(partial) == 0:
+		case lower[0] > 0 || len(partial) == 1:
+			upper = semver{lower[0] + 1, 0, 0}
+		case lower[1] > 0 || len(partial) == 2:
+			upper = semver{0, lower[1] + 1, 0}
+		}
+		return version.compare(lower) >= 0 && version.compare(upper) < 0, nil
+	case "~":
+		if len(partial) == 3 {
+			upper = semver{lower[0], lower[1] + 1, 0}
+		}
+		return version.compare(lower) >= 0 && version.compare(upper) < 0, nil
+	case "=":
+		return version.compare(lower) >= 0 && version.compare(upper) < 0, nil
+	case ">":
+		return version.compare(upper) >= 0, nil
+	case ">=":
+		return version.compare(lower) >= 0, nil
+	case "<":
+		return version.compare(lower) < 0, nil
+	case "<=":
+		return version.compare(upper) < 0, nil
+	}
+	return false, fmt.Errorf("unknown operator %q", operator)
+}
+
+// maxComponent is larger than any version component.
+const maxComponent = int(^uint(0) >> 1)
+
+// semver is the major, minor, and patch components of a version. Pre-release and
+// build metadata are ignored.
+type semver [3]int
+
+func (v semver) compare(other semver) int {
+	return slices.Compare(v[:], other[:])
+}
+
+// partialVersion is the components of a version in a requirement, up to the first
+// wildcard.
+type partialVersion []int
+
+func (p partialVersion) get(i int) int {
+	if i < len(p) {
+		return p[i]
+	}
+	return 0
+}
+
+func parsePartial(s string) (partialVersion, error) {
+	if s == "" {
+		return nil, errors.New("missing version")
+	}
+	components := strings.Split(s, ".")
+	if len(components) > 3 {
+		return nil, fmt.Errorf("invalid version %q", s)
+	}
+	var partial partialVersion
+	for _, component := range components {
+		if component == "*" || component == "x" || component == "X" {
+			break
+		}
+		n, err := strconv.Atoi(component)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+		partial = append(partial, n)
+	}
+	return partial, nil
+}
+
+func parseVersion(s string) (semver, error) {
+	core, _, _ := strings.Cut(s, "+")
+	core, _, _ = strings.Cut(core, "-")
+	partial, err := parsePartial(core)
+	if err != nil || len(partial) != 3 {
+		return semver{}, fmt.Errorf("invalid version %q", s)
+	}
+	return semver{partial[0], partial[1], partial[2]}, nil
+}
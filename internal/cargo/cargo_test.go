@@ -0,0 +1,123 @@
+package cargo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testLockfile = `# This file is automatically @generated by Cargo.
+# It is not intended for manual editing.
+version = 4
+
+[[package]]
+name = "bytes"
+version = "1.10.1"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+checksum = "d71b6127be86fdcfddb610f7182ac57211d4b18a3e9c82eb2d17662f2227ad6a"
+
+[[package]]
+name = "prost"
+version = "0.14.1"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+dependencies = [
+ "bytes",
+ "prost-derive",
+]
+
+[[package]]
+name = "prost-types"
+version = "0.13.5"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+`
+
+func TestParseLockfile(t *testing.T) {
+	t.Parallel()
+	packages, err := ParseLockfile([]byte(testLockfile))
+	require.NoError(t, err)
+	assert.Equal(t, []Package{
+		{Name: "bytes", Version: "1.10.1"},
+		{Name: "prost", Version: "0.14.1"},
+		{Name: "prost-types", Version: "0.13.5"},
+	}, packages)
+
+	_, err = ParseLockfile([]byte("[[package]]\nname = \"bytes\"\n"))
+	require.Error(t, err)
+}
+
+func TestMatchesRequirement(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		requirement string
+		version     string
+		want        bool
+	}{
+		{requirement: "1.2.3", version: "1.2.3", want: true},
+		{requirement: "1.2.3", version: "1.9.0", want: true},
+		{requirement: "1.2.3", version: "1.2.2", want: false},
+		{requirement: "1.2.3", version: "2.0.0", want: false},
+		{requirement: "^0.14.1", version: "0.14.9", want: true},
+		{requirement: "0.14.1", version: "0.15.0", want: false},
+		{requirement: "0.0.3", version: "0.0.3", want: true},
+		{requirement: "0.0.3", version: "0.0.4", want: false},
+		{requirement: "0.0", version: "0.0.9", want: true},
+		{requirement: "0", version: "0.9.0", want: true},
+		{requirement: "1", version: "1.99.0", want: true},
+		{requirement: "1", version: "2.0.0", want: false},
+		{requirement: "~1.2.3", version: "1.2.9", want: true},
+		{requirement: "~1.2.3", version: "1.3.0", want: false},
+		{requirement: "~1", version: "1.9.0", want: true},
+		{requirement: "=1.2.3", version: "1.2.4", want: false},
+		{requirement: "=1.2", version: "1.2.4", want: true},
+		{requirement: "1.*", version: "1.5.0", want: true},
+		{requirement: "1.2.*", version: "1.3.0", want: false},
+		{requirement: "*", version: "7.0.0", want: true},
+		{requirement: ">= 1.2, < 1.5", version: "1.4.9", want: true},
+		{requirement: ">= 1.2, < 1.5", version: "1.5.0", want: false},
+		{requirement: ">1.2", version: "1.2.9", want: false},
+		{requirement: ">1.2", version: "1.3.0", want: true},
+		{requirement: "<=1.2", version: "1.2.9", want: true},
+		{requirement: "1.2.3", version: "1.2.4-rc.1", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.requirement+"@"+tt.version, func(t *testing.T) {
+			t.Parallel()
+			got, err := MatchesRequirement(tt.requirement, tt.version)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	_, err := MatchesRequirement("!1.2", "1.2.0")
+	require.Error(t, err)
+}
+
+func TestCheckDeps(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "buf.plugin.yaml"), []byte(`version: v1
+name: buf.build/community/neoeinstein-prost
+plugin_version: v0.5.0
+output_languages:
+  - rust
+registry:
+  cargo:
+    deps:
+      - name: "prost"
+        req: "0.14.1"
+      - name: "prost-types"
+        req: "0.14.1"
+      - name: "prost-build"
+        req: "0.14.1"
+`), 0644))
+	// Without a lockfile, there's nothing to check.
+	require.NoError(t, CheckDeps(dir))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Cargo.lock"), []byte(testLockfile), 0644))
+	err := CheckDeps(dir)
+	require.Error(t, err)
+	assert.Equal(t, `registry.cargo dep prost-types "0.14.1" does not match Cargo.lock version 0.13.5
+registry.cargo dep prost-build "0.14.1" is not in Cargo.lock`, err.Error())
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"buf.build/go/app/appcmd"
+	"buf.build/go/app/appext"
+	"github.com/spf13/pflag"
+
+	"github.com/bufbuild/plugins/internal/bazelshim"
+	"github.com/bufbuild/plugins/internal/plugin"
+)
+
+func main() {
+	appcmd.Main(context.Background(), newRootCommand("bazel-shims"))
+}
+
+func newRootCommand(name string) *appcmd.Command {
+	builder := appext.NewBuilder(name)
+	f := &flags{}
+	return &appcmd.Command{
+		Use:                 name,
+		Short:               "Renders the Bazel BUILD files, shims and patches of the protocolbuffers plugins.",
+		Args:                appcmd.NoArgs,
+		Run:                 builder.NewRunFunc(func(ctx context.Context, container appext.Container) error { return run(ctx, container, f) }),
+		BindFlags:           f.Bind,
+		BindPersistentFlags: builder.BindRoot,
+	}
+}
+
+type flags struct {
+	dir   string
+	check bool
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(&f.dir, "dir", ".", "directory path to plugins")
+	flagSet.BoolVar(&f.check, "check", false, "check that the checked in files match, without writing them")
+}
+
+func run(ctx context.Context, container appext.Container, f *flags) error {
+	plugins, err := plugin.FindAll(f.dir)
+	if err != nil {
+		return fmt.Errorf("find plugins: %w", err)
+	}
+	outdated, err := renderShims(plugins, !f.check)
+	if err != nil {
+		return err
+	}
+	for _, path := range outdated {
+		if f.check {
+			container.Logger().ErrorContext(ctx, "out of date", slog.String("file", path))
+		} else {
+			container.Logger().InfoContext(ctx, "updated", slog.String("file", path))
+		}
+	}
+	if f.check && len(outdated) > 0 {
+		return fmt.Errorf("%d files are out of date: run 'go run ./internal/cmd/bazel-shims'", len(outdated))
+	}
+	return nil
+}
+
+// renderShims renders the shims of the plugins, writing them if write is set. It returns
+// the paths of the files which differed from the rendered files (relative to the
+// plugins' directory).
+func renderShims(plugins []*plugin.Plugin, write bool) ([]string, error) {
+	var outdated []string
+	for _, p := range plugins {
+		files, err := bazelshim.Render(strings.TrimPrefix(p.Name, "buf.build/"), p.PluginVersion)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			filename := filepath.Join(filepath.Dir(p.Path), file.Name)
+			content, err := os.ReadFile(filename)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			if bytes.Equal(content, file.Content) {
+				continue
+			}
+			if write {
+				if err := os.WriteFile(filename, file.Content, 0644); err != nil { //nolint:gosec
+					return nil, err
+				}
+			}
+			outdated = append(outdated, filepath.ToSlash(filepath.Join(filepath.Dir(p.Relpath), file.Name)))
+		}
+	}
+	return outdated, nil
+}
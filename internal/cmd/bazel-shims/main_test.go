@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bufbuild/plugins/internal/plugin"
+)
+
+func TestRenderShims(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFile := func(path string, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+	}
+	for _, version := range []string{"v29.0", "v30.0"} {
+		writeFile("plugins/protocolbuffers/ruby/"+version+"/buf.plugin.yaml", "version: v1\nname: buf.build/protocolbuffers/ruby\nplugin_version: "+version+"\n")
+	}
+	writeFile("plugins/protocolbuffers/ruby/v29.0/BUILD", "cc_binary(\n    name = \"protoc-gen-ruby\",\n    srcs = [\"ruby.cc\"],\n    deps = [\n        \"//:protoc_lib\",\n    ],\n)\n")
+	writeFile("plugins/protocolbuffers/ruby/v29.0/ruby.cc", "// outdated\n")
+	writeFile("plugins/acme/plugin/v1.0.0/buf.plugin.yaml", "version: v1\nname: buf.build/acme/plugin\nplugin_version: v1.0.0\n")
+
+	plugins, err := plugin.FindAll(dir)
+	require.NoError(t, err)
+	outdated, err := renderShims(plugins, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"plugins/protocolbuffers/ruby/v29.0/ruby.cc",
+		"plugins/protocolbuffers/ruby/v30.0/BUILD",
+		"plugins/protocolbuffers/ruby/v30.0/ruby.cc",
+	}, outdated)
+	_, err = os.Stat(filepath.Join(dir, "plugins/protocolbuffers/ruby/v30.0/BUILD"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	outdated, err = renderShims(plugins, true)
+	require.NoError(t, err)
+	assert.Len(t, outdated, 3)
+	content, err := os.ReadFile(filepath.Join(dir, "plugins/protocolbuffers/ruby/v29.0/ruby.cc"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "google::protobuf::compiler::ruby::Generator generator;")
+	outdated, err = renderShims(plugins, false)
+	require.NoError(t, err)
+	assert.Empty(t, outdated)
+}
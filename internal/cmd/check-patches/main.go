@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"buf.build/go/app/appcmd"
+	"buf.build/go/app/appext"
+	"github.com/spf13/pflag"
+	"golang.org/x/mod/semver"
+
+	"github.com/bufbuild/plugins/internal/patches"
+	"github.com/bufbuild/plugins/internal/plugin"
+)
+
+func main() {
+	appcmd.Main(context.Background(), newRootCommand("check-patches"))
+}
+
+func newRootCommand(name string) *appcmd.Command {
+	builder := appext.NewBuilder(name)
+	f := &flags{}
+	return &appcmd.Command{
+		Use:   name + " [<org/name>[:<version>]...]",
+		Short: "Applies the patches of plugins to their upstream source, and outputs the results as JSON.",
+		Long: `Applies the patches of plugins to the source cloned by their Dockerfile, reporting the hunks applied with offsets or fuzz.
+Patches only applying with fuzz fail, as 'git apply' in the Dockerfile rejects them, unless refreshed with --refresh.
+Defaults to the latest version of every plugin with patches.`,
+		Args:                appcmd.ArbitraryArgs,
+		Run:                 builder.NewRunFunc(func(ctx context.Context, container appext.Container) error { return run(ctx, container, f) }),
+		BindFlags:           f.Bind,
+		BindPersistentFlags: builder.BindRoot,
+	}
+}
+
+type flags struct {
+	dir     string
+	gitURL  string
+	refresh bool
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(&f.dir, "dir", ".", "directory path to plugins")
+	flagSet.StringVar(&f.gitURL, "git-url", "", "the git repository to fetch the upstream source from, instead of the repository cloned by the Dockerfile")
+	flagSet.BoolVar(&f.refresh, "refresh", false, "rewrite the patches which apply with offsets or fuzz with refreshed context")
+}
+
+// report is the results of applying the patches of plugin versions.
+type report struct {
+	Plugins []*pluginReport `json:"plugins"`
+}
+
+type pluginReport struct {
+	// Plugin is the org and name of the plugin (such as "grpc/go").
+	Plugin   string            `json:"plugin"`
+	Version  string            `json:"version"`
+	URL      string            `json:"url"`
+	Ref      string            `json:"ref"`
+	Patches  []*patches.Result `json:"patches"`
+	failures int
+}
+
+func run(ctx context.Context, container appext.Container, f *flags) error {
+	plugins, err := plugin.FindAll(f.dir)
+	if err != nil {
+		return fmt.Errorf("find plugins: %w", err)
+	}
+	args := make([]string, container.NumArgs())
+	for i := range args {
+		args[i] = container.Arg(i)
+	}
+	selected, err := selectPlugins(plugins, args)
+	if err != nil {
+		return err
+	}
+	if f.gitURL != "" && len(selected) != 1 {
+		return errors.New("--git-url requires a single plugin")
+	}
+	result := &report{Plugins: []*pluginReport{}}
+	var failures int
+	for _, p := range selected {
+		pluginReport, err := checkPatches(ctx, p, f.gitURL, f.refresh)
+		if err != nil {
+			return fmt.Errorf("check patches of %s:%s: %w", p.Name, p.PluginVersion, err)
+		}
+		if pluginReport == nil {
+			continue
+		}
+		for _, patch := range pluginReport.Patches {
+			container.Logger().InfoContext(
+				ctx,
+				"applied patch",
+				slog.String("plugin", pluginReport.Plugin+":"+pluginReport.Version),
+				slog.String("patch", patch.Patch),
+				slog.String("status", string(patch.Status)),
+			)
+		}
+		failures += pluginReport.failures
+		result.Plugins = append(result.Plugins, pluginReport)
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(container.Stdout(), string(data)); err != nil {
+		return err
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d patches failed to apply", failures)
+	}
+	return nil
+}
+
+// selectPlugins returns the plugin versions of the args ("org/name" for the latest
+// version, or "org/name:version"), or the latest version of every plugin if there
+// are no args.
+func selectPlugins(plugins []*plugin.Plugin, args []string) ([]*plugin.Plugin, error) {
+	latestByName := make(map[string]*plugin.Plugin)
+	for _, p := range plugins {
+		if latest, ok := latestByName[p.Name]; !ok || semver.Compare(p.PluginVersion, latest.PluginVersion) > 0 {
+			latestByName[p.Name] = p
+		}
+	}
+	if len(args) == 0 {
+		selected := make([]*plugin.Plugin, 0, len(latestByName))
+		for _, p := range latestByName {
+			selected = append(selected, p)
+		}
+		slices.SortFunc(selected, func(a, b *plugin.Plugin) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+		return selected, nil
+	}
+	selected := make([]*plugin.Plugin, 0, len(args))
+	for _, arg := range args {
+		name, version, _ := strings.Cut(arg, ":")
+		var found *plugin.Plugin
+		if version == "" {
+			found = latestByName["buf.build/"+name]
+		} else {
+			index := slices.IndexFunc(plugins, func(p *plugin.Plugin) bool {
+				return p.Name == "buf.build/"+name && p.PluginVersion == version
+			})
+			if index != -1 {
+				found = plugins[index]
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("plugin %s not found", arg)
+		}
+		selected = append(selected, found)
+	}
+	return selected, nil
+}
+
+// checkPatches applies the patches of the plugin version to its upstream source. It
+// returns nil if the plugin version has no patches.
+func checkPatches(ctx context.Context, p *plugin.Plugin, gitURL string, refresh bool) (*pluginReport, error) {
+	versionDir := filepath.Dir(p.Path)
+	patchFiles, err := patches.FindPatches(versionDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(patchFiles) == 0 {
+		return nil, nil
+	}
+	dockerfile, err := os.ReadFile(filepath.Join(versionDir, "Dockerfile"))
+	if err != nil {
+		return nil, err
+	}
+	upstream, err := patches.FindUpstream(dockerfile)
+	if err != nil {
+		return nil, err
+	}
+	if gitURL != "" {
+		upstream.URL = gitURL
+	}
+	mode := patches.RefreshNone
+	if refresh {
+		mode = patches.RefreshFuzz
+	}
+	results, err := patches.Apply(ctx, upstream, versionDir, patchFiles, mode)
+	if err != nil {
+		return nil, err
+	}
+	report := &pluginReport{
+		Plugin:  strings.TrimPrefix(p.Name, "buf.build/"),
+		Version: p.PluginVersion,
+		URL:     upstream.URL,
+		Ref:     upstream.Ref,
+		Patches: results,
+	}
+	for _, result := range results {
+		if result.Status == patches.StatusFailed {
+			report.failures++
+		}
+	}
+	return report, nil
+}
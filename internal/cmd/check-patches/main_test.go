@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bufbuild/plugins/internal/plugin"
+)
+
+func TestSelectPlugins(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFile := func(path string, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+	}
+	for _, version := range []string{"v1.9.0", "v1.10.0"} {
+		writeFile("plugins/grpc/go/"+version+"/buf.plugin.yaml", "version: v1\nname: buf.build/grpc/go\nplugin_version: "+version+"\n")
+	}
+	writeFile("plugins/acme/plugin/v1.0.0/buf.plugin.yaml", "version: v1\nname: buf.build/acme/plugin\nplugin_version: v1.0.0\n")
+	plugins, err := plugin.FindAll(dir)
+	require.NoError(t, err)
+
+	versions := func(selected []*plugin.Plugin) []string {
+		var result []string
+		for _, p := range selected {
+			result = append(result, p.Name+":"+p.PluginVersion)
+		}
+		return result
+	}
+	selected, err := selectPlugins(plugins, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"buf.build/acme/plugin:v1.0.0", "buf.build/grpc/go:v1.10.0"}, versions(selected))
+	selected, err = selectPlugins(plugins, []string{"grpc/go:v1.9.0", "grpc/go"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"buf.build/grpc/go:v1.9.0", "buf.build/grpc/go:v1.10.0"}, versions(selected))
+	_, err = selectPlugins(plugins, []string{"grpc/go:v2.0.0"})
+	require.ErrorContains(t, err, "not found")
+
+	// Plugins without patches are skipped.
+	report, err := checkPatches(t.Context(), selected[0], "", false)
+	require.NoError(t, err)
+	assert.Nil(t, report)
+}
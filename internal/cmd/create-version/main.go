@@ -0,0 +1,13 @@
+package main
+
+import (
+	"context"
+
+	"buf.build/go/app/appcmd"
+
+	"github.com/bufbuild/plugins/internal/fetcher"
+)
+
+func main() {
+	appcmd.Main(context.Background(), fetcher.NewCreateVersionCommand("create-version"))
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"buf.build/go/app/appcmd"
+	"buf.build/go/app/appext"
+	"github.com/spf13/pflag"
+	"golang.org/x/mod/semver"
+
+	"github.com/bufbuild/plugins/internal/docker"
+	"github.com/bufbuild/plugins/internal/plugin"
+)
+
+func main() {
+	appcmd.Main(context.Background(), newRootCommand("refresh-base-images"))
+}
+
+func newRootCommand(name string) *appcmd.Command {
+	builder := appext.NewBuilder(name)
+	f := &flags{}
+	return &appcmd.Command{
+		Use:                 name,
+		Short:               "Updates the base images of the latest version of every plugin, and outputs the changes as JSON.",
+		Args:                appcmd.NoArgs,
+		Run:                 builder.NewRunFunc(func(ctx context.Context, container appext.Container) error { return run(ctx, container, f) }),
+		BindFlags:           f.Bind,
+		BindPersistentFlags: builder.BindRoot,
+	}
+}
+
+type flags struct {
+	dir    string
+	dryRun bool
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(&f.dir, "dir", ".", "directory path to plugins")
+	flagSet.BoolVar(&f.dryRun, "dry-run", false, "output the changes without writing them")
+}
+
+// changeSet is the plugin versions whose Dockerfiles were updated to the latest base images.
+type changeSet struct {
+	Plugins []*pluginChange `json:"plugins"`
+}
+
+type pluginChange struct {
+	// Plugin is the org and name of the plugin (such as "connectrpc/go").
+	Plugin  string        `json:"plugin"`
+	Version string        `json:"version"`
+	Files   []*fileChange `json:"files"`
+}
+
+type fileChange struct {
+	// Path is the path of the Dockerfile, relative to --dir.
+	Path    string             `json:"path"`
+	Updates []*baseImageUpdate `json:"updates"`
+}
+
+type baseImageUpdate struct {
+	Name string `json:"name"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+func run(ctx context.Context, container appext.Container, f *flags) error {
+	baseImageDir, err := docker.FindBaseImageDir(f.dir)
+	if err != nil {
+		return err
+	}
+	latestBaseImages, err := docker.LoadLatestBaseImages(baseImageDir)
+	if err != nil {
+		return fmt.Errorf("load base images: %w", err)
+	}
+	plugins, err := plugin.FindAll(f.dir)
+	if err != nil {
+		return fmt.Errorf("find plugins: %w", err)
+	}
+	changes, err := refreshBaseImages(latestPlugins(plugins), latestBaseImages, !f.dryRun)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes.Plugins {
+		for _, file := range change.Files {
+			for _, update := range file.Updates {
+				container.Logger().InfoContext(
+					ctx,
+					"updated base image",
+					slog.String("file", file.Path),
+					slog.String("image", update.Name),
+					slog.String("old", update.Old),
+					slog.String("new", update.New),
+				)
+			}
+		}
+	}
+	data, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(container.Stdout(), string(data))
+	return err
+}
+
+// latestPlugins returns the latest version of each plugin, sorted by name.
+func latestPlugins(plugins []*plugin.Plugin) []*plugin.Plugin {
+	latestByName := make(map[string]*plugin.Plugin)
+	for _, p := range plugins {
+		if latest, ok := latestByName[p.Name]; !ok || semver.Compare(p.PluginVersion, latest.PluginVersion) > 0 {
+			latestByName[p.Name] = p
+		}
+	}
+	latest := make([]*plugin.Plugin, 0, len(latestByName))
+	for _, p := range latestByName {
+		latest = append(latest, p)
+	}
+	slices.SortFunc(latest, func(a, b *plugin.Plugin) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return latest
+}
+
+// refreshBaseImages updates the FROM images and syntax directive of the Dockerfiles of
+// the plugins to the latest base images, writing them if write is set. It returns the
+// plugins with updated Dockerfiles.
+func refreshBaseImages(plugins []*plugin.Plugin, latestBaseImages *docker.BaseImages, write bool) (*changeSet, error) {
+	changes := &changeSet{Plugins: []*pluginChange{}}
+	for _, p := range plugins {
+		pluginDir := filepath.Dir(p.Path)
+		entries, err := os.ReadDir(pluginDir)
+		if err != nil {
+			return nil, err
+		}
+		change := &pluginChange{
+			Plugin:  strings.TrimPrefix(p.Name, "buf.build/"),
+			Version: p.PluginVersion,
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasPrefix(entry.Name(), "Dockerfile") {
+				continue
+			}
+			dockerfile := filepath.Join(pluginDir, entry.Name())
+			content, err := os.ReadFile(dockerfile)
+			if err != nil {
+				return nil, err
+			}
+			updated, updates, err := docker.UpdateBaseImages(content, latestBaseImages)
+			if err != nil {
+				return nil, fmt.Errorf("update %s: %w", dockerfile, err)
+			}
+			if bytes.Equal(content, updated) {
+				continue
+			}
+			if write {
+				if err := os.WriteFile(dockerfile, updated, 0644); err != nil { //nolint:gosec
+					return nil, err
+				}
+			}
+			file := &fileChange{
+				Path:    filepath.ToSlash(filepath.Join(filepath.Dir(p.Relpath), entry.Name())),
+				Updates: []*baseImageUpdate{},
+			}
+			for _, update := range updates {
+				file.Updates = append(file.Updates, &baseImageUpdate{Name: update.Name, Old: update.Old, New: update.New})
+			}
+			change.Files = append(change.Files, file)
+		}
+		if len(change.Files) > 0 {
+			changes.Plugins = append(changes.Plugins, change)
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bufbuild/plugins/internal/docker"
+	"github.com/bufbuild/plugins/internal/plugin"
+)
+
+func TestRefreshBaseImages(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFile := func(path string, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+	}
+	writeFile("baseimages/Dockerfile.debian", "FROM debian:trixie-20260803\n")
+	writeFile("baseimages/Dockerfile.dockerfile", "FROM docker/dockerfile:1.26\n")
+	outdated := "# syntax=docker/dockerfile:1.24\nFROM debian:bookworm-20250101 AS build\nRUN echo build\nFROM scratch\nCOPY --from=build /bin/sh /\n"
+	for _, version := range []string{"v1.0.0", "v1.1.0"} {
+		writeFile("plugins/acme/outdated/"+version+"/buf.plugin.yaml", "version: v1\nname: buf.build/acme/outdated\nplugin_version: "+version+"\n")
+		writeFile("plugins/acme/outdated/"+version+"/Dockerfile", outdated)
+	}
+	upToDate := "# syntax=docker/dockerfile:1.26\nFROM debian:trixie-20260803\n"
+	writeFile("plugins/acme/current/v2.0.0/buf.plugin.yaml", "version: v1\nname: buf.build/acme/current\nplugin_version: v2.0.0\n")
+	writeFile("plugins/acme/current/v2.0.0/Dockerfile", upToDate)
+
+	latestBaseImages, err := docker.LoadLatestBaseImages(filepath.Join(dir, "baseimages"))
+	require.NoError(t, err)
+	plugins, err := plugin.FindAll(dir)
+	require.NoError(t, err)
+	changes, err := refreshBaseImages(latestPlugins(plugins), latestBaseImages, true)
+	require.NoError(t, err)
+	assert.Equal(t, &changeSet{Plugins: []*pluginChange{
+		{
+			Plugin:  "acme/outdated",
+			Version: "v1.1.0",
+			Files: []*fileChange{{
+				Path: "plugins/acme/outdated/v1.1.0/Dockerfile",
+				Updates: []*baseImageUpdate{
+					{Name: "docker/dockerfile", Old: "1.24", New: "1.26"},
+					{Name: "debian", Old: "bookworm-20250101", New: "trixie-20260803"},
+				},
+			}},
+		},
+	}}, changes)
+	content, err := os.ReadFile(filepath.Join(dir, "plugins/acme/outdated/v1.1.0/Dockerfile"))
+	require.NoError(t, err)
+	assert.Equal(t, "# syntax=docker/dockerfile:1.26\nFROM debian:trixie-20260803 AS build\nRUN echo build\nFROM scratch\nCOPY --from=build /bin/sh /\n", string(content))
+	// Only the latest version of a plugin is refreshed.
+	content, err = os.ReadFile(filepath.Join(dir, "plugins/acme/outdated/v1.0.0/Dockerfile"))
+	require.NoError(t, err)
+	assert.Equal(t, outdated, string(content))
+}
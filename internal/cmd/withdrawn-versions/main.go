@@ -0,0 +1,125 @@
+// Package main implements the "withdrawn-versions" command: it reports plugin
+// versions in the repository whose upstream release has since been withdrawn
+// (yanked, deprecated, or retracted).
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"buf.build/go/app/appcmd"
+	"buf.build/go/app/appext"
+	"github.com/spf13/pflag"
+
+	"github.com/bufbuild/plugins/internal/fetchclient"
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+func main() {
+	appcmd.Main(context.Background(), newRootCommand("withdrawn-versions"))
+}
+
+func newRootCommand(name string) *appcmd.Command {
+	builder := appext.NewBuilder(name)
+	f := &flags{}
+	return &appcmd.Command{
+		Use:   name,
+		Short: "Reports plugin versions whose upstream release was withdrawn.",
+		Args:  appcmd.NoArgs,
+		Run: builder.NewRunFunc(func(ctx context.Context, container appext.Container) error {
+			client, err := fetchclient.New(ctx)
+			if err != nil {
+				return err
+			}
+			return run(ctx, container, client, f)
+		}),
+		BindFlags:           f.Bind,
+		BindPersistentFlags: builder.BindRoot,
+	}
+}
+
+type flags struct {
+	dir string
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(&f.dir, "dir", ".", "directory path to plugins")
+}
+
+// versionLister lists the versions published by a source.
+type versionLister interface {
+	Versions(ctx context.Context, config *source.Config) ([]fetchclient.Version, error)
+}
+
+// withdrawnVersion is a plugin version whose upstream release was withdrawn.
+type withdrawnVersion struct {
+	// path is the plugin version directory relative to the plugins directory.
+	path   string
+	reason string
+}
+
+func run(ctx context.Context, container appext.Container, lister versionLister, f *flags) error {
+	withdrawn, err := findWithdrawnVersions(ctx, lister, f.dir)
+	if err != nil {
+		return err
+	}
+	for _, w := range withdrawn {
+		if _, err := fmt.Fprintf(container.Stdout(), "%s: %s\n", w.path, w.reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findWithdrawnVersions returns the existing plugin versions under dir whose upstream
+// version has been withdrawn, sorted by path.
+func findWithdrawnVersions(ctx context.Context, lister versionLister, dir string) ([]withdrawnVersion, error) {
+	configs, err := source.GatherConfigs(dir)
+	if err != nil {
+		return nil, err
+	}
+	versionsByCacheKey := make(map[string][]fetchclient.Version)
+	var withdrawn []withdrawnVersion
+	for _, config := range configs {
+		if config.Source.Disabled {
+			continue
+		}
+		versions, ok := versionsByCacheKey[config.CacheKey()]
+		if !ok {
+			versions, err = lister.Versions(ctx, config)
+			if err != nil {
+				return nil, fmt.Errorf("list versions for %s: %w", config.Filename, err)
+			}
+			versionsByCacheKey[config.CacheKey()] = versions
+		}
+		pluginDir := filepath.Dir(config.Filename)
+		for _, version := range versions {
+			if version.Withdrawn == "" {
+				continue
+			}
+			versionDir := filepath.Join(pluginDir, version.Version)
+			if _, err := os.Stat(versionDir); err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			relPath, err := filepath.Rel(dir, versionDir)
+			if err != nil {
+				return nil, err
+			}
+			withdrawn = append(withdrawn, withdrawnVersion{
+				path:   filepath.ToSlash(relPath),
+				reason: version.Withdrawn,
+			})
+		}
+	}
+	slices.SortFunc(withdrawn, func(a, b withdrawnVersion) int {
+		return strings.Compare(a.path, b.path)
+	})
+	return withdrawn, nil
+}
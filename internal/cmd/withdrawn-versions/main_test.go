@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bufbuild/plugins/internal/fetchclient"
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+func TestFindWithdrawnVersions(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	for _, path := range []string{"test/plugin/v1.0.0", "test/plugin/v1.1.0", "test/plugin/v1.2.0"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "plugins", path), 0755))
+	}
+	sourceYAML := "source:\n  crates:\n    crate_name: plugin\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "plugins", "test", "plugin", "source.yaml"), []byte(sourceYAML), 0644))
+
+	lister := &staticLister{versions: []fetchclient.Version{
+		{Version: "v1.0.0"},
+		{Version: "v1.1.0", Withdrawn: "yanked"},
+		{Version: "v1.2.0"},
+		// Not present in the repository.
+		{Version: "v1.3.0", Withdrawn: "yanked"},
+	}}
+	withdrawn, err := findWithdrawnVersions(t.Context(), lister, dir)
+	require.NoError(t, err)
+	assert.Equal(t, []withdrawnVersion{{path: "plugins/test/plugin/v1.1.0", reason: "yanked"}}, withdrawn)
+}
+
+type staticLister struct {
+	versions []fetchclient.Version
+}
+
+func (s *staticLister) Versions(context.Context, *source.Config) ([]fetchclient.Version, error) {
+	return s.versions, nil
+}
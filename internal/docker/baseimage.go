@@ -1,7 +1,6 @@
 package docker
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"os"
@@ -111,46 +110,26 @@ func LoadLatestBaseImages(baseImageDir string) (_ *BaseImages, retErr error) {
 	}, nil
 }
 
-func parseDockerfileBaseImageNameVersion(dockerfile string) (_ string, _ string, retErr error) {
-	f, err := os.Open(dockerfile)
+// parseDockerfileBaseImageNameVersion returns the name and version ("tag@digest") of
+// the base image of the first stage of a Dockerfile.
+func parseDockerfileBaseImageNameVersion(dockerfile string) (string, string, error) {
+	content, err := os.ReadFile(dockerfile)
 	if err != nil {
-		return "", "", nil
+		return "", "", err
 	}
-	defer func() {
-		retErr = errors.Join(retErr, f.Close())
-	}()
-	s := bufio.NewScanner(f)
-	for s.Scan() {
-		line := strings.TrimSpace(s.Text())
-		fields := strings.Fields(line)
-		if len(fields) < 2 {
-			continue
-		}
-		if !strings.EqualFold(fields[0], "from") {
-			continue
-		}
-		var image string
-		for i := 1; i < len(fields); i++ {
-			if strings.HasPrefix(fields[i], "--") {
-				// Ignore --platform and other args
-				continue
-			}
-			image = fields[i]
-			break
-		}
-		if image == "" {
-			return "", "", fmt.Errorf("missing image in FROM: %q", line)
-		}
-		imageName, version, found := strings.Cut(image, ":")
-		if !found {
-			return "", "", fmt.Errorf("invalid FROM line: %q", line)
-		}
-		return imageName, version, nil
+	parsed, err := ParseDockerfile(content)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse %s: %w", dockerfile, err)
 	}
-	if err := s.Err(); err != nil {
-		return "", "", err
+	stages := parsed.Stages()
+	if len(stages) == 0 {
+		return "", "", fmt.Errorf("failed to detect base image in %s", dockerfile)
+	}
+	imageName, tag, _ := SplitImage(stages[0].BaseImage)
+	if imageName == "" || tag == "" {
+		return "", "", fmt.Errorf("invalid FROM image %q in %s", stages[0].Image, dockerfile)
 	}
-	return "", "", fmt.Errorf("failed to detect base image in %s", dockerfile)
+	return imageName, imageVersion(stages[0].BaseImage), nil
 }
 
 // distrolessImageNameWithoutVersions returns a distroless image name without version numbers.
@@ -0,0 +1,280 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+const (
+	// DockerfileImageName is the image of the Dockerfile frontend referenced by syntax directives.
+	DockerfileImageName = "docker/dockerfile"
+)
+
+var (
+	// argReferencePattern matches a reference to an ARG ("$NAME" or "${NAME}").
+	argReferencePattern = regexp.MustCompile(`\$(?:([A-Za-z_][A-Za-z0-9_]*)|\{([A-Za-z_][A-Za-z0-9_]*)\})`)
+)
+
+// Dockerfile is a Dockerfile parsed with the BuildKit parser. It keeps the original
+// lines of the Dockerfile, so it can be rewritten without changing its formatting.
+type Dockerfile struct {
+	// lines are the lines of the Dockerfile, including their line endings.
+	lines        []string
+	syntax       string
+	syntaxLine   int
+	instructions []*Instruction
+	stages       []*Stage
+	// args are the ARGs declared before the first FROM, which FROM instructions can reference.
+	args map[string]*globalArg
+}
+
+// Instruction is an instruction of a Dockerfile.
+type Instruction struct {
+	// Keyword is the upper case instruction (such as "RUN").
+	Keyword string
+	// Original is the instruction with line continuations joined and comments removed.
+	Original string
+	// StartLine and EndLine are the first and last lines of the instruction (starting at 1).
+	StartLine int
+	EndLine   int
+}
+
+// Stage is a FROM instruction of a Dockerfile.
+type Stage struct {
+	// Image is the image of the FROM instruction as written, such as
+	// "debian:bookworm-20250101@sha256:..." or "${BASE_IMAGE}".
+	Image string
+	// BaseImage is the image with the ARGs declared before the first FROM expanded. It is
+	// empty if the image is an earlier stage, or references an ARG without a default.
+	BaseImage string
+	// Name is the name of the stage ("FROM image AS name"), if any.
+	Name        string
+	instruction *Instruction
+	// arg is the ARG whose default value is the image, for "FROM ${ARG}".
+	arg *globalArg
+}
+
+// globalArg is an ARG declared before the first FROM, with a default value.
+type globalArg struct {
+	value string
+	// token is the "NAME=value" token of the ARG instruction, with the value as written.
+	token       string
+	instruction *Instruction
+}
+
+// BaseImageUpdate is a base image (or Dockerfile frontend) updated in a Dockerfile.
+type BaseImageUpdate struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// ParseDockerfile parses a Dockerfile.
+func ParseDockerfile(content []byte) (*Dockerfile, error) {
+	result, err := parser.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	d := &Dockerfile{
+		lines: strings.SplitAfter(string(content), "\n"),
+		args:  make(map[string]*globalArg),
+	}
+	if syntax, _, ranges, ok := parser.DetectSyntax(content); ok && len(ranges) > 0 {
+		d.syntax, d.syntaxLine = syntax, ranges[0].Start.Line
+	}
+	stageNames := make(map[string]struct{})
+	for _, node := range result.AST.Children {
+		instruction := &Instruction{
+			Keyword:   strings.ToUpper(node.Value),
+			Original:  node.Original,
+			StartLine: node.StartLine,
+			EndLine:   node.EndLine,
+		}
+		d.instructions = append(d.instructions, instruction)
+		switch instruction.Keyword {
+		case "ARG":
+			if len(d.stages) > 0 {
+				continue
+			}
+			for next := node.Next; next != nil; next = next.Next {
+				name, value, ok := strings.Cut(next.Value, "=")
+				if !ok {
+					continue
+				}
+				d.args[name] = &globalArg{value: unquote(value), token: next.Value, instruction: instruction}
+			}
+		case "FROM":
+			if node.Next == nil {
+				return nil, fmt.Errorf("missing image in FROM on line %d", node.StartLine)
+			}
+			stage := &Stage{Image: node.Next.Value, instruction: instruction}
+			if as := node.Next.Next; as != nil && strings.EqualFold(as.Value, "AS") && as.Next != nil {
+				stage.Name = as.Next.Value
+			}
+			if _, ok := stageNames[strings.ToLower(stage.Image)]; !ok {
+				stage.BaseImage, stage.arg = d.expandArgs(stage.Image)
+			}
+			if stage.Name != "" {
+				stageNames[strings.ToLower(stage.Name)] = struct{}{}
+			}
+			d.stages = append(d.stages, stage)
+		}
+	}
+	return d, nil
+}
+
+// Instructions returns the instructions of the Dockerfile.
+func (d *Dockerfile) Instructions() []*Instruction {
+	return d.instructions
+}
+
+// Stages returns the FROM instructions of the Dockerfile.
+func (d *Dockerfile) Stages() []*Stage {
+	return d.stages
+}
+
+// UpdateBaseImages replaces the base images of each stage of the Dockerfile (or the
+// default value of the ARG they reference) with the latest base images, including their
+// digests, and its syntax directive with the latest Dockerfile frontend. It returns the
+// Dockerfile with only these images changed, and the base images which were updated.
+func (d *Dockerfile) UpdateBaseImages(latestBaseImages *BaseImages) ([]byte, []BaseImageUpdate, error) {
+	latestDockerfileVersion := latestBaseImages.ImageVersion(DockerfileImageName)
+	if latestDockerfileVersion == "" {
+		return nil, nil, fmt.Errorf("failed to find latest version for dockerfile image %q", DockerfileImageName)
+	}
+	lines := append([]string(nil), d.lines...)
+	var updates []BaseImageUpdate
+	if name, version, _ := strings.Cut(d.syntax, ":"); name == DockerfileImageName && version != latestDockerfileVersion {
+		newSyntax := DockerfileImageName + ":" + latestDockerfileVersion
+		if !replaceWord(lines, d.syntaxLine, d.syntaxLine, d.syntax, newSyntax) {
+			return nil, nil, fmt.Errorf("failed to find syntax directive %q on line %d", d.syntax, d.syntaxLine)
+		}
+		updates = append(updates, BaseImageUpdate{Name: DockerfileImageName, Old: version, New: latestDockerfileVersion})
+	}
+	updatedArgs := make(map[*globalArg]struct{})
+	for _, stage := range d.stages {
+		if stage.BaseImage == "" {
+			continue
+		}
+		if _, ok := updatedArgs[stage.arg]; ok {
+			continue
+		}
+		name, _, _ := SplitImage(stage.BaseImage)
+		newImage := latestBaseImages.ImageNameAndVersion(name)
+		if newImage == "" || newImage == stage.BaseImage {
+			continue
+		}
+		if stage.arg != nil {
+			// FROM ${ARG}: the image is the default value of the ARG.
+			updatedArgs[stage.arg] = struct{}{}
+			newToken := strings.Replace(stage.arg.token, stage.arg.value, newImage, 1)
+			if !replaceWord(lines, stage.arg.instruction.StartLine, stage.arg.instruction.EndLine, stage.arg.token, newToken) {
+				return nil, nil, fmt.Errorf("failed to find ARG %q on line %d", stage.arg.token, stage.arg.instruction.StartLine)
+			}
+		} else if !replaceWord(lines, stage.instruction.StartLine, stage.instruction.EndLine, stage.Image, newImage) {
+			return nil, nil, fmt.Errorf("failed to find image %q on line %d", stage.Image, stage.instruction.StartLine)
+		}
+		updates = append(updates, BaseImageUpdate{Name: name, Old: imageVersion(stage.BaseImage), New: latestBaseImages.ImageVersion(name)})
+	}
+	return []byte(strings.Join(lines, "")), updates, nil
+}
+
+// UpdateBaseImages parses the Dockerfile and updates its base images (see
+// Dockerfile.UpdateBaseImages).
+func UpdateBaseImages(content []byte, latestBaseImages *BaseImages) ([]byte, []BaseImageUpdate, error) {
+	dockerfile, err := ParseDockerfile(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dockerfile.UpdateBaseImages(latestBaseImages)
+}
+
+// SplitImage splits an image reference into its name, tag, and digest. For example,
+// "debian:bookworm@sha256:abc" returns "debian", "bookworm", and "sha256:abc".
+func SplitImage(image string) (string, string, string) {
+	name, digest, _ := strings.Cut(image, "@")
+	var tag string
+	// A colon before the last slash separates a registry host from its port.
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+	return name, tag, digest
+}
+
+// expandArgs returns the image with the global ARGs expanded, or an empty string if
+// it references an ARG without a default value. If the image is only a reference to
+// an ARG, it also returns the ARG.
+func (d *Dockerfile) expandArgs(image string) (string, *globalArg) {
+	if match := argReferencePattern.FindStringSubmatch(image); match != nil && match[0] == image {
+		arg, ok := d.args[match[1]+match[2]]
+		if !ok {
+			return "", nil
+		}
+		return arg.value, arg
+	}
+	expanded := true
+	image = argReferencePattern.ReplaceAllStringFunc(image, func(reference string) string {
+		match := argReferencePattern.FindStringSubmatch(reference)
+		arg, ok := d.args[match[1]+match[2]]
+		if !ok {
+			expanded = false
+			return reference
+		}
+		return arg.value
+	})
+	if !expanded {
+		return "", nil
+	}
+	return image, nil
+}
+
+// imageVersion returns the tag and digest of an image ("tag@digest").
+func imageVersion(image string) string {
+	_, tag, digest := SplitImage(image)
+	if digest != "" {
+		return tag + "@" + digest
+	}
+	return tag
+}
+
+// unquote removes the quotes around an ARG value.
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// replaceWord replaces the first occurrence of old delimited by whitespace (or a line
+// continuation) in lines[startLine-1:endLine] with replacement. It returns false if old
+// wasn't found.
+func replaceWord(lines []string, startLine int, endLine int, old string, replacement string) bool {
+	for i := startLine - 1; i < endLine && i < len(lines); i++ {
+		line := lines[i]
+		for offset := 0; ; {
+			index := strings.Index(line[offset:], old)
+			if index == -1 {
+				break
+			}
+			start, end := offset+index, offset+index+len(old)
+			if (start == 0 || isWordDelimiter(line[start-1])) && (end == len(line) || isWordDelimiter(line[end])) {
+				lines[i] = line[:start] + replacement + line[end:]
+				return true
+			}
+			offset = start + 1
+		}
+	}
+	return false
+}
+
+func isWordDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\\', '=':
+		return true
+	}
+	return false
+}
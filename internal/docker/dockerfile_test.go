@@ -0,0 +1,110 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateBaseImages(t *testing.T) {
+	t.Parallel()
+	latestBaseImages := &BaseImages{
+		latestVersions: map[string]string{
+			"docker/dockerfile":                 "1.26",
+			"debian":                            "trixie-20260803@sha256:34cd",
+			"golang":                            "1.26.6-trixie@sha256:b75d",
+			"gcr.io/distroless/static-debian13": "latest@sha256:9197",
+		},
+		latestDistrolessImageNames: map[string]string{
+			"gcr.io/distroless/static-debian": "gcr.io/distroless/static-debian13",
+		},
+	}
+	content := `#syntax=docker/dockerfile:1.24
+ARG BASE="debian:bookworm-20250101@sha256:aaaa"
+FROM --platform=$BUILDPLATFORM \
+     golang:1.25.5-bookworm@sha256:bbbb   AS build
+RUN go install example.com/cmd@v1.0.0
+from build as intermediate
+FROM ${BASE} AS runtime
+FROM $BASE
+FROM example.com:5000/golang:1
+FROM gcr.io/distroless/static-debian12:latest@sha256:cccc
+COPY --from=build /go/bin/cmd /cmd
+FROM scratch`
+	updated, updates, err := UpdateBaseImages([]byte(content), latestBaseImages)
+	require.NoError(t, err)
+	assert.Equal(t, `#syntax=docker/dockerfile:1.26
+ARG BASE="debian:trixie-20260803@sha256:34cd"
+FROM --platform=$BUILDPLATFORM \
+     golang:1.26.6-trixie@sha256:b75d   AS build
+RUN go install example.com/cmd@v1.0.0
+from build as intermediate
+FROM ${BASE} AS runtime
+FROM $BASE
+FROM example.com:5000/golang:1
+FROM gcr.io/distroless/static-debian13:latest@sha256:9197
+COPY --from=build /go/bin/cmd /cmd
+FROM scratch`, string(updated))
+	assert.Equal(t, []BaseImageUpdate{
+		{Name: "docker/dockerfile", Old: "1.24", New: "1.26"},
+		{Name: "golang", Old: "1.25.5-bookworm@sha256:bbbb", New: "1.26.6-trixie@sha256:b75d"},
+		{Name: "debian", Old: "bookworm-20250101@sha256:aaaa", New: "trixie-20260803@sha256:34cd"},
+		{Name: "gcr.io/distroless/static-debian12", Old: "latest@sha256:cccc", New: "latest@sha256:9197"},
+	}, updates)
+
+	// Only the digest changed.
+	updated, updates, err = UpdateBaseImages([]byte("FROM debian:trixie-20260803@sha256:0000\n"), latestBaseImages)
+	require.NoError(t, err)
+	assert.Equal(t, "FROM debian:trixie-20260803@sha256:34cd\n", string(updated))
+	assert.Equal(t, []BaseImageUpdate{{Name: "debian", Old: "trixie-20260803@sha256:0000", New: "trixie-20260803@sha256:34cd"}}, updates)
+
+	upToDate := "# syntax=docker/dockerfile:1.26\nFROM debian:trixie-20260803@sha256:34cd\n"
+	updated, updates, err = UpdateBaseImages([]byte(upToDate), latestBaseImages)
+	require.NoError(t, err)
+	assert.Equal(t, upToDate, string(updated))
+	assert.Empty(t, updates)
+}
+
+func TestParseDockerfile(t *testing.T) {
+	t.Parallel()
+	dockerfile, err := ParseDockerfile([]byte(`ARG IMAGE=node:24
+ARG VERSION
+FROM ${IMAGE} AS build
+RUN npm install \
+  # comment
+  example@1.0.0
+FROM build
+FROM python:${VERSION}
+`))
+	require.NoError(t, err)
+	var stages [][3]string
+	for _, stage := range dockerfile.Stages() {
+		stages = append(stages, [3]string{stage.Image, stage.BaseImage, stage.Name})
+	}
+	assert.Equal(t, [][3]string{
+		{"${IMAGE}", "node:24", "build"},
+		{"build", "", ""},
+		{"python:${VERSION}", "", ""},
+	}, stages)
+	instructions := dockerfile.Instructions()
+	require.Len(t, instructions, 6)
+	assert.Equal(t, &Instruction{Keyword: "RUN", Original: "RUN npm install   example@1.0.0", StartLine: 4, EndLine: 6}, instructions[3])
+
+	_, err = ParseDockerfile([]byte("# no instructions\n"))
+	require.Error(t, err)
+}
+
+func TestSplitImage(t *testing.T) {
+	t.Parallel()
+	verifySplit := func(image, expectedName, expectedTag, expectedDigest string) {
+		name, tag, digest := SplitImage(image)
+		assert.Equal(t, []string{expectedName, expectedTag, expectedDigest}, []string{name, tag, digest}, image)
+	}
+	verifySplit("debian", "debian", "", "")
+	verifySplit("debian:bookworm", "debian", "bookworm", "")
+	verifySplit("debian:bookworm@sha256:abc", "debian", "bookworm", "sha256:abc")
+	verifySplit("debian@sha256:abc", "debian", "", "sha256:abc")
+	verifySplit("example.com:5000/golang", "example.com:5000/golang", "", "")
+	verifySplit("example.com:5000/golang:1.25", "example.com:5000/golang", "1.25", "")
+}
@@ -0,0 +1,85 @@
+package fetchclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+// Changes links to the upstream changes between two versions, for reviewers of a new
+// plugin version.
+type Changes struct {
+	// PreviousUpstream and NewUpstream are the versions as published by the upstream
+	// source (the tags of GitHub sources). PreviousUpstream is empty if the previous
+	// version is no longer published.
+	PreviousUpstream string
+	NewUpstream      string
+	// CompareURL is the GitHub comparison of the tags, for GitHub sources.
+	CompareURL string
+	// ReleaseURL and ReleaseNotes are the GitHub release of the new tag, for GitHub
+	// sources with a release.
+	ReleaseURL   string
+	ReleaseNotes string
+	// RegistryURL is the page of the new version, for npm, Maven, PyPI and crates.io
+	// sources using the public registry.
+	RegistryURL string
+}
+
+// Changes resolves the upstream changes between the given semver versions (as returned
+// by Fetch) from the config's source.
+func (c *Client) Changes(ctx context.Context, config *source.Config, previousVersion string, newVersion string) (*Changes, error) {
+	changes, err := c.changes(ctx, config, previousVersion, newVersion)
+	if err != nil {
+		return nil, c.credentials.redactError(fmt.Errorf("%s: changes of %s: %w", config.Source.Name(), newVersion, err))
+	}
+	return changes, nil
+}
+
+func (c *Client) changes(ctx context.Context, config *source.Config, previousVersion string, newVersion string) (*Changes, error) {
+	versions, err := c.listVersions(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	upstream, err := findUpstreamVersion(versions, newVersion)
+	if err != nil {
+		return nil, err
+	}
+	changes := &Changes{NewUpstream: upstream.Upstream}
+	if previous, err := findUpstreamVersion(versions, previousVersion); err == nil {
+		changes.PreviousUpstream = previous.Upstream
+	} else if !errors.Is(err, errNoVersions) {
+		return nil, err
+	}
+	switch {
+	case config.Source.GitHub != nil:
+		owner, repository := config.Source.GitHub.Owner, config.Source.GitHub.Repository
+		if changes.PreviousUpstream != "" {
+			changes.CompareURL = fmt.Sprintf(
+				"https://github.com/%s/%s/compare/%s...%s",
+				owner, repository, url.PathEscape(changes.PreviousUpstream), url.PathEscape(changes.NewUpstream),
+			)
+		}
+		release, _, err := c.ghClient.Repositories.GetReleaseByTag(ctx, owner, repository, changes.NewUpstream)
+		if err != nil {
+			if IsGitHubNotFound(err) {
+				// Not every tag is released.
+				return changes, nil
+			}
+			return nil, err
+		}
+		changes.ReleaseURL = release.GetHTMLURL()
+		changes.ReleaseNotes = release.GetBody()
+	case config.Source.NPMRegistry != nil && config.Source.NPMRegistry.Registry == "":
+		changes.RegistryURL = fmt.Sprintf("https://www.npmjs.com/package/%s/v/%s", config.Source.NPMRegistry.Name, url.PathEscape(changes.NewUpstream))
+	case config.Source.Maven != nil && config.Source.Maven.Registry == "":
+		changes.RegistryURL = fmt.Sprintf("https://central.sonatype.com/artifact/%s/%s/%s", config.Source.Maven.Group, config.Source.Maven.Name, url.PathEscape(changes.NewUpstream))
+	case config.Source.PyPI != nil && config.Source.PyPI.Registry == "":
+		changes.RegistryURL = fmt.Sprintf("https://pypi.org/project/%s/%s/", config.Source.PyPI.Name, url.PathEscape(changes.NewUpstream))
+	case config.Source.Crates != nil:
+		changes.RegistryURL = fmt.Sprintf("https://crates.io/crates/%s/%s", config.Source.Crates.CrateName, url.PathEscape(changes.NewUpstream))
+	}
+	return changes, nil
+}
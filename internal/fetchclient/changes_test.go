@@ -0,0 +1,101 @@
+package fetchclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+func TestChanges(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/github/repos/acme/plugin/tags", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`[{"name":"v1.1.0","commit":{"sha":"bbb"}},{"name":"v1.0.0","commit":{"sha":"aaa"}}]`))
+	})
+	mux.HandleFunc("/github/repos/acme/plugin/releases/tags/v1.1.0", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"html_url":"https://github.com/acme/plugin/releases/tag/v1.1.0","body":"## What's Changed\n* Add a feature"}`))
+	})
+	mux.HandleFunc("/npm/@acme/protoc-gen-plugin", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"versions":{"2.2.0":{}}}`))
+	})
+	mux.HandleFunc("/pypi/mypy-protobuf/", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"versions": ["3.5.0", "3.6.0", "3.6.0.post1"]}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	ghClient := github.NewClient(srv.Client())
+	var err error
+	ghClient.BaseURL, err = url.Parse(srv.URL + "/github/")
+	require.NoError(t, err)
+	c := &Client{
+		httpClient:  srv.Client(),
+		ghClient:    ghClient,
+		npmBaseURL:  srv.URL + "/npm",
+		pypiBaseURL: srv.URL + "/pypi",
+	}
+
+	tests := []struct {
+		name            string
+		source          source.Source
+		previousVersion string
+		newVersion      string
+		want            *Changes
+	}{
+		{
+			name:            "github release",
+			source:          source.Source{GitHub: &source.GitHubConfig{Owner: "acme", Repository: "plugin"}},
+			previousVersion: "v1.0.0",
+			newVersion:      "v1.1.0",
+			want: &Changes{
+				PreviousUpstream: "v1.0.0",
+				NewUpstream:      "v1.1.0",
+				CompareURL:       "https://github.com/acme/plugin/compare/v1.0.0...v1.1.0",
+				ReleaseURL:       "https://github.com/acme/plugin/releases/tag/v1.1.0",
+				ReleaseNotes:     "## What's Changed\n* Add a feature",
+			},
+		},
+		{
+			name:            "github tag without release",
+			source:          source.Source{GitHub: &source.GitHubConfig{Owner: "acme", Repository: "plugin"}},
+			previousVersion: "v0.9.0",
+			newVersion:      "v1.0.0",
+			want:            &Changes{NewUpstream: "v1.0.0"},
+		},
+		{
+			name:            "npm",
+			source:          source.Source{NPMRegistry: &source.NPMRegistryConfig{Name: "@acme/protoc-gen-plugin"}},
+			previousVersion: "v2.1.0",
+			newVersion:      "v2.2.0",
+			want: &Changes{
+				NewUpstream: "2.2.0",
+				RegistryURL: "https://www.npmjs.com/package/@acme/protoc-gen-plugin/v/2.2.0",
+			},
+		},
+		{
+			name:            "pypi",
+			source:          source.Source{PyPI: &source.PyPIConfig{Name: "mypy-protobuf"}},
+			previousVersion: "v3.5.0",
+			newVersion:      "v3.6.0",
+			want: &Changes{
+				PreviousUpstream: "3.5.0",
+				NewUpstream:      "3.6.0",
+				RegistryURL:      "https://pypi.org/project/mypy-protobuf/3.6.0/",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			changes, err := c.Changes(t.Context(), &source.Config{Source: tt.source}, tt.previousVersion, tt.newVersion)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, changes)
+		})
+	}
+}
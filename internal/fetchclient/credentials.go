@@ -0,0 +1,469 @@
+package fetchclient
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// registryTokensEnv holds bearer tokens for private registries as whitespace or comma
+	// separated "prefix=token" pairs, where prefix is a registry URL (with or without scheme),
+	// e.g. "https://npm.example.com/private=abc123".
+	registryTokensEnv = "FETCH_REGISTRY_TOKENS"
+	// npmUserConfigEnv overrides the location of the user .npmrc file, as with npm.
+	npmUserConfigEnv = "NPM_CONFIG_USERCONFIG"
+	// mavenSettingsEnv overrides the location of the Maven settings.xml file.
+	mavenSettingsEnv = "MAVEN_SETTINGS"
+	// netrcEnv overrides the location of the .netrc file, as with curl and the go command.
+	netrcEnv = "NETRC"
+
+	redacted = "[REDACTED]"
+)
+
+var (
+	// githubHosts are the hosts the GitHub token is sent to.
+	githubHosts = []string{"api.github.com", "github.com"}
+	// npmrcEnvPattern matches environment variable references in .npmrc values.
+	npmrcEnvPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+	// mavenEnvPattern matches environment variable references in Maven settings.
+	mavenEnvPattern = regexp.MustCompile(`\$\{env\.([^}]+)\}`)
+)
+
+// credential is the Authorization header value for requests to URLs with a prefix.
+type credential struct {
+	// prefix is the host (without scheme) and optional path prefix, e.g.
+	// "npm.example.com/private/". A prefix without a path matches any port.
+	prefix        string
+	authorization string
+}
+
+// credentials are the credentials for private registries.
+type credentials struct {
+	// entries are sorted by descending prefix length so the most specific credential wins.
+	entries []credential
+	// secrets are redacted from errors returned by the client.
+	secrets []string
+}
+
+// loadCredentials loads credentials for private registries from (in order of
+// precedence when prefixes are equally specific) the FETCH_REGISTRY_TOKENS
+// environment variable, the user .npmrc file, the Maven settings.xml file, and the
+// .netrc file. Missing files are ignored.
+func loadCredentials(getenv func(string) string, homeDir string) (*credentials, error) {
+	creds := &credentials{}
+	if err := creds.addRegistryTokens(getenv(registryTokensEnv)); err != nil {
+		return nil, fmt.Errorf("%s: %w", registryTokensEnv, err)
+	}
+	sources := []struct {
+		path  string
+		parse func(creds *credentials, content []byte, getenv func(string) string) error
+	}{
+		{path: cmp.Or(getenv(npmUserConfigEnv), joinHome(homeDir, ".npmrc")), parse: (*credentials).addNPMRC},
+		{path: cmp.Or(getenv(mavenSettingsEnv), joinHome(homeDir, ".m2", "settings.xml")), parse: (*credentials).addMavenSettings},
+		{path: cmp.Or(getenv(netrcEnv), joinHome(homeDir, ".netrc")), parse: (*credentials).addNetrc},
+	}
+	for _, source := range sources {
+		if source.path == "" {
+			continue
+		}
+		content, err := os.ReadFile(source.path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		if err := source.parse(creds, content, getenv); err != nil {
+			return nil, fmt.Errorf("%s: %w", source.path, err)
+		}
+	}
+	slices.SortStableFunc(creds.entries, func(a, b credential) int {
+		return cmp.Compare(len(b.prefix), len(a.prefix))
+	})
+	return creds, nil
+}
+
+func joinHome(homeDir string, elem ...string) string {
+	if homeDir == "" {
+		return ""
+	}
+	return filepath.Join(append([]string{homeDir}, elem...)...)
+}
+
+// withGitHubToken returns a copy of the credentials which also authenticate requests to
+// the GitHub API and github.com with the token, unless a more specific credential is
+// configured for them.
+func (c *credentials) withGitHubToken(token string) *credentials {
+	creds := &credentials{
+		entries: slices.Clone(c.entries),
+		secrets: slices.Clone(c.secrets),
+	}
+	for _, host := range githubHosts {
+		creds.addBearer(host, token)
+	}
+	slices.SortStableFunc(creds.entries, func(a, b credential) int {
+		return cmp.Compare(len(b.prefix), len(a.prefix))
+	})
+	return creds
+}
+
+// add adds a credential for the URL prefix. The secrets used by the credential
+// (including the Authorization header value) are redacted from errors.
+func (c *credentials) add(prefix string, authorization string, secrets ...string) {
+	prefix = normalizePrefix(prefix)
+	if prefix == "" || authorization == "" {
+		return
+	}
+	c.entries = append(c.entries, credential{prefix: prefix, authorization: authorization})
+	c.addSecrets(append(secrets, authorization)...)
+}
+
+func (c *credentials) addBearer(prefix string, token string) {
+	c.add(prefix, "Bearer "+token, token)
+}
+
+func (c *credentials) addBasic(prefix string, username string, password string) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	c.add(prefix, "Basic "+encoded, password, encoded)
+}
+
+// addSecrets adds values to redact from errors.
+func (c *credentials) addSecrets(secrets ...string) {
+	for _, secret := range secrets {
+		// Very short values would redact unrelated text and can't be meaningfully protected.
+		if len(secret) >= 4 && !slices.Contains(c.secrets, secret) {
+			c.secrets = append(c.secrets, secret)
+		}
+	}
+	// Redact longer secrets first in case one contains another.
+	slices.SortFunc(c.secrets, func(a, b string) int {
+		return cmp.Compare(len(b), len(a))
+	})
+}
+
+// authorization returns the Authorization header value for the URL, if any.
+func (c *credentials) authorization(u *url.URL) (string, bool) {
+	target := strings.ToLower(u.Host) + u.EscapedPath()
+	for _, entry := range c.entries {
+		if matchesPrefix(target, entry.prefix) {
+			return entry.authorization, true
+		}
+	}
+	return "", false
+}
+
+// matchesPrefix reports whether the target ("host[:port]/path") has the prefix,
+// matching only on host, port, and path segment boundaries.
+func matchesPrefix(target string, prefix string) bool {
+	rest, ok := strings.CutPrefix(target, prefix)
+	if !ok {
+		return false
+	}
+	if rest == "" || strings.HasSuffix(prefix, "/") || rest[0] == '/' {
+		return true
+	}
+	// A host-only prefix matches any port.
+	return rest[0] == ':' && !strings.Contains(prefix, "/")
+}
+
+// normalizePrefix strips the scheme from a URL prefix and lowercases the host.
+func normalizePrefix(prefix string) string {
+	prefix = strings.TrimSpace(prefix)
+	if _, rest, ok := strings.Cut(prefix, "://"); ok {
+		prefix = rest
+	}
+	prefix = strings.TrimPrefix(prefix, "//")
+	host, path, _ := strings.Cut(prefix, "/")
+	if path == "" {
+		return strings.ToLower(host)
+	}
+	return strings.ToLower(host) + "/" + path
+}
+
+// redact replaces all secrets in s.
+func (c *credentials) redact(s string) string {
+	for _, secret := range c.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+// redactError returns err with all secrets redacted from its message. The returned
+// error still wraps err, so errors.Is and errors.As behave as before.
+func (c *credentials) redactError(err error) error {
+	if err == nil || c == nil {
+		return err
+	}
+	message := err.Error()
+	if redactedMessage := c.redact(message); redactedMessage != message {
+		return &redactedError{message: redactedMessage, err: err}
+	}
+	return err
+}
+
+type redactedError struct {
+	message string
+	err     error
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// addRegistryTokens parses bearer tokens in the FETCH_REGISTRY_TOKENS format.
+func (c *credentials) addRegistryTokens(value string) error {
+	for field := range strings.FieldsFuncSeq(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	}) {
+		prefix, token, ok := strings.Cut(field, "=")
+		if !ok || prefix == "" || token == "" {
+			// Don't include the field in the error as it likely contains a token.
+			return errors.New(`expected "prefix=token" pairs`)
+		}
+		c.addBearer(prefix, token)
+	}
+	return nil
+}
+
+// addNPMRC parses registry credentials from an .npmrc file. Supported keys are
+// "//registry/path/:_authToken", "//registry/path/:_auth", and the
+// "//registry/path/:username" and "//registry/path/:_password" (base64) pair.
+// Values may reference environment variables with "${NAME}".
+// See https://docs.npmjs.com/cli/configuring-npm/npmrc#auth-related-configuration.
+func (c *credentials) addNPMRC(content []byte, getenv func(string) string) error {
+	type basicAuth struct {
+		username string
+		password string
+	}
+	var prefixes []string
+	basicAuths := make(map[string]*basicAuth)
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if !strings.HasPrefix(key, "//") {
+			continue
+		}
+		idx := strings.LastIndex(key, ":")
+		if idx == -1 {
+			continue
+		}
+		prefix, field := key[:idx], key[idx+1:]
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		value = npmrcEnvPattern.ReplaceAllStringFunc(value, func(match string) string {
+			return getenv(npmrcEnvPattern.FindStringSubmatch(match)[1])
+		})
+		switch field {
+		case "_authToken":
+			c.addBearer(prefix, value)
+		case "_auth":
+			c.add(prefix, "Basic "+value, value)
+		case "username", "_password":
+			auth, ok := basicAuths[prefix]
+			if !ok {
+				auth = &basicAuth{}
+				basicAuths[prefix] = auth
+				prefixes = append(prefixes, prefix)
+			}
+			if field == "username" {
+				auth.username = value
+				continue
+			}
+			password, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				c.addSecrets(value)
+				return fmt.Errorf("invalid base64 _password for %s", prefix)
+			}
+			auth.password = string(password)
+			c.addSecrets(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for _, prefix := range prefixes {
+		if auth := basicAuths[prefix]; auth.username != "" && auth.password != "" {
+			c.addBasic(prefix, auth.username, auth.password)
+		}
+	}
+	return nil
+}
+
+// addMavenSettings parses server credentials from a Maven settings.xml file. Servers
+// are matched by id to the URLs of mirrors and of repositories declared in profiles.
+// Values may reference environment variables with "${env.NAME}". Encrypted passwords
+// are not supported. See https://maven.apache.org/settings.html#servers.
+func (c *credentials) addMavenSettings(content []byte, getenv func(string) string) error {
+	type repository struct {
+		ID  string `xml:"id"`
+		URL string `xml:"url"`
+	}
+	var settings struct {
+		Servers []struct {
+			ID       string `xml:"id"`
+			Username string `xml:"username"`
+			Password string `xml:"password"`
+		} `xml:"servers>server"`
+		Mirrors  []repository `xml:"mirrors>mirror"`
+		Profiles []struct {
+			Repositories []repository `xml:"repositories>repository"`
+		} `xml:"profiles>profile"`
+	}
+	if err := xml.Unmarshal(content, &settings); err != nil {
+		return err
+	}
+	expand := func(value string) string {
+		return mavenEnvPattern.ReplaceAllStringFunc(strings.TrimSpace(value), func(match string) string {
+			return getenv(mavenEnvPattern.FindStringSubmatch(match)[1])
+		})
+	}
+	urls := make(map[string][]string)
+	repositories := settings.Mirrors
+	for _, profile := range settings.Profiles {
+		repositories = append(repositories, profile.Repositories...)
+	}
+	for _, repository := range repositories {
+		urls[repository.ID] = append(urls[repository.ID], expand(repository.URL))
+	}
+	for _, server := range settings.Servers {
+		username, password := expand(server.Username), expand(server.Password)
+		if username == "" || password == "" {
+			continue
+		}
+		if strings.HasPrefix(password, "{") && strings.HasSuffix(password, "}") {
+			c.addSecrets(password)
+			return fmt.Errorf("server %q: encrypted passwords are not supported", server.ID)
+		}
+		for _, serverURL := range urls[server.ID] {
+			c.addBasic(serverURL, username, password)
+		}
+	}
+	return nil
+}
+
+// addNetrc parses machine credentials from a .netrc file. The "default" entry is
+// ignored so credentials are never sent to hosts which aren't explicitly listed.
+func (c *credentials) addNetrc(content []byte, _ func(string) string) error {
+	var (
+		machine, login, password string
+		inMacro                  bool
+	)
+	flush := func() {
+		if machine != "" && login != "" && password != "" {
+			c.addBasic(machine, login, password)
+		}
+		machine, login, password = "", "", ""
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			// Macro definitions end with an empty line.
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			next := func() string {
+				if i+1 < len(fields) {
+					i++
+					return fields[i]
+				}
+				return ""
+			}
+			switch fields[i] {
+			case "machine":
+				flush()
+				machine = next()
+			case "default":
+				flush()
+			case "login":
+				login = next()
+			case "password":
+				password = next()
+			case "account":
+				next()
+			case "macdef":
+				flush()
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	flush()
+	return nil
+}
+
+// authTransport sets the Authorization header of requests to private registries.
+type authTransport struct {
+	base        http.RoundTripper
+	credentials *credentials
+}
+
+var _ http.RoundTripper = (*authTransport)(nil)
+
+// newAuthTransport returns a transport which authenticates requests using the
+// credentials. Credentials override any Authorization header set by an outer
+// transport (e.g. the GitHub token), so they are applied per request URL
+// including redirects.
+func newAuthTransport(base http.RoundTripper, credentials *credentials) *authTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &authTransport{base: base, credentials: credentials}
+}
+
+func (t *authTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	// Credentials are never sent in cleartext, such as after a redirect to http.
+	if request.URL.Scheme != "https" {
+		return t.base.RoundTrip(request)
+	}
+	if authorization, ok := t.credentials.authorization(request.URL); ok {
+		request = request.Clone(request.Context())
+		request.Header.Set("Authorization", authorization)
+	}
+	return t.base.RoundTrip(request)
+}
+
+// validateRegistryURL validates a private registry URL from a source config.
+// Credentials must not be embedded in source configs, which are checked in, and are
+// only sent over TLS.
+func validateRegistryURL(registry string) error {
+	parsed, err := url.Parse(registry)
+	if err != nil {
+		// The parse error includes the URL, which may contain credentials.
+		return errors.New("invalid registry URL")
+	}
+	if parsed.User != nil {
+		return fmt.Errorf("registry URL %q must not contain credentials", parsed.Redacted())
+	}
+	if parsed.Scheme != "https" {
+		return fmt.Errorf("registry URL %q must use https", parsed.Redacted())
+	}
+	return nil
+}
@@ -1,22 +1,30 @@
 package fetchclient
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"buf.build/go/standard/xslices"
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/google/go-github/v72/github"
 	"github.com/hashicorp/go-retryablehttp"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"golang.org/x/mod/modfile"
 	"golang.org/x/mod/semver"
-	"golang.org/x/oauth2"
 
+	"github.com/bufbuild/plugins/internal/readiness"
 	"github.com/bufbuild/plugins/internal/source"
 )
 
@@ -25,8 +33,10 @@ const (
 	// docs: https://pub.dev/help/api
 	dartFlutterAPIURL = "https://pub.dev/api/packages"
 	goProxyURL        = "https://proxy.golang.org"
-	npmRegistryURL    = "https://registry.npmjs.org"
-	mavenURL          = "https://repo1.maven.org/maven2"
+	// docs: https://go.dev/ref/mod#checksum-database
+	goSumDBURL     = "https://sum.golang.org"
+	npmRegistryURL = "https://registry.npmjs.org"
+	mavenURL       = "https://repo1.maven.org/maven2"
 	// docs: https://packaging.python.org/en/latest/specifications/simple-repository-api/
 	pypiURL = "https://pypi.org/simple"
 )
@@ -34,56 +44,280 @@ const (
 var (
 	// ErrSemverPrerelease is returned when a version is a pre-release.
 	ErrSemverPrerelease = errors.New("pre-release versions are not supported")
+
+	errNoVersions = errors.New("no versions found")
+	errNotFound   = errors.New("not found")
+)
+
+// Version is a version published by an upstream source.
+type Version struct {
+	// Version is the valid semver version, guaranteed to contain a "v" prefix.
+	// For ecosystems which don't use semver, it is mapped from Upstream
+	// (see pep440ToSemver and mavenToSemver for the mapping rules).
+	Version string
+	// Upstream is the version as published by the upstream source.
+	Upstream string
+	// Withdrawn describes why the version was withdrawn upstream after being published
+	// (e.g. a yanked crate, a deprecated npm version, a retracted Go module version).
+	// It is empty if the version has not been withdrawn.
+	Withdrawn string
+	// Time is when the version was published, if known. For GitHub sources it is
+	// the date of the tagged commit.
+	Time time.Time
+	// commit is the SHA of the tagged commit for GitHub sources, if known.
+	commit string
+	// scheme determines how Upstream versions are ordered.
+	scheme versionScheme
+}
+
+// versionScheme is an ecosystem-specific version ordering.
+type versionScheme int
+
+const (
+	// schemeSemver orders versions by their semver Version.
+	schemeSemver versionScheme = iota
+	// schemePEP440 orders versions by their PEP 440 Upstream version.
+	schemePEP440
+	// schemeMaven orders versions by their Maven ComparableVersion Upstream version.
+	schemeMaven
 )
 
+// compareVersions compares two versions using their ecosystem's ordering, falling
+// back to semver ordering for versions from different ecosystems.
+func compareVersions(a, b Version) int {
+	if a.scheme == b.scheme {
+		switch a.scheme {
+		case schemePEP440:
+			parsedA, errA := parsePEP440(a.Upstream)
+			parsedB, errB := parsePEP440(b.Upstream)
+			if errA == nil && errB == nil {
+				return comparePEP440(parsedA, parsedB)
+			}
+		case schemeMaven:
+			return compareMavenVersions(a.Upstream, b.Upstream)
+		case schemeSemver:
+		}
+	}
+	return semver.Compare(a.Version, b.Version)
+}
+
 // Client is a client used to fetch latest package version.
 type Client struct {
-	httpClient  *http.Client
-	ghClient    *github.Client
-	pypiBaseURL string
+	httpClient *http.Client
+	// genericHTTPClient is used by http and oci sources, which may fetch from any
+	// host: it never sends the GitHub token.
+	genericHTTPClient *http.Client
+	ghClient          *github.Client
+	// githubGraphQLURL is the GitHub GraphQL endpoint, or empty if the REST API
+	// must be used (the GraphQL API requires authentication).
+	githubGraphQLURL   string
+	cratesBaseURL      string
+	dartFlutterBaseURL string
+	goProxyBaseURL     string
+	goSumDBBaseURL     string
+	npmBaseURL         string
+	mavenBaseURL       string
+	pypiBaseURL        string
+	// credentials authenticate requests to private registries, and their secrets
+	// are redacted from returned errors.
+	credentials *credentials
+	// goSumDBKey is the verifier key of the Go checksum database.
+	goSumDBKey string
+	// sigstoreRoot verifies npm and GitHub attestations, if configured.
+	sigstoreRoot root.TrustedMaterial
+	// mavenKeyring verifies Maven artifact signatures, if configured.
+	mavenKeyring openpgp.EntityList
+
+	mu sync.Mutex
+	// listedVersions caches the versions listed per source cache key, so they are
+	// listed once per run rather than again for each created plugin version.
+	listedVersions map[string][]Version
+}
+
+// ClientOption is an option for New.
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	sigstoreTrustedRootPath string
+	mavenKeyringPath        string
+}
+
+// WithSigstoreTrustedRoot sets the path of the Sigstore trusted_root.json file used
+// to verify attestations (see Client.Verify). It can be obtained with
+// "cosign trusted-root create" or from https://github.com/sigstore/root-signing.
+func WithSigstoreTrustedRoot(path string) ClientOption {
+	return func(options *clientOptions) {
+		options.sigstoreTrustedRootPath = path
+	}
+}
+
+// WithMavenKeyring sets the path of the armored PGP keyring pinning the keys trusted
+// to sign Maven artifacts (see Client.Verify).
+func WithMavenKeyring(path string) ClientOption {
+	return func(options *clientOptions) {
+		options.mavenKeyringPath = path
+	}
 }
 
 // New returns a new client.
-func New(ctx context.Context) *Client {
-	var client *http.Client
+//
+// The returned client is safe for concurrent use. Requests are limited per host
+// so that concurrent fetches respect registry crawling policies.
+//
+// Requests to private registries are authenticated with credentials loaded from
+// the environment and the user's .npmrc, Maven settings.xml, and .netrc files
+// (see loadCredentials). Secrets are redacted from errors returned by the client.
+func New(_ context.Context, opts ...ClientOption) (*Client, error) {
+	var options clientOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	var sigstoreRoot root.TrustedMaterial
+	if options.sigstoreTrustedRootPath != "" {
+		content, err := os.ReadFile(options.sigstoreTrustedRootPath)
+		if err != nil {
+			return nil, err
+		}
+		sigstoreRoot, err = parseSigstoreTrustedRoot(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", options.sigstoreTrustedRootPath, err)
+		}
+	}
+	var mavenKeyring openpgp.EntityList
+	if options.mavenKeyringPath != "" {
+		content, err := os.ReadFile(options.mavenKeyringPath)
+		if err != nil {
+			return nil, err
+		}
+		mavenKeyring, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", options.mavenKeyringPath, err)
+		}
+	}
+	homeDir, _ := os.UserHomeDir()
+	creds, err := loadCredentials(os.Getenv, homeDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load registry credentials: %w", err)
+	}
+	genericClient := newRetryableClient(creds)
+	client := genericClient
+	var graphQLURL string
 	if ghToken := os.Getenv("GITHUB_TOKEN"); ghToken != "" {
-		ts := oauth2.StaticTokenSource(
-			&oauth2.Token{AccessToken: ghToken},
-		)
-		client = oauth2.NewClient(ctx, ts)
-	} else {
-		retryableClient := retryablehttp.NewClient()
-		retryableClient.Logger = nil
-		client = retryableClient.StandardClient()
+		// The token is only sent to GitHub: registries without credentials never receive it.
+		creds = creds.withGitHubToken(ghToken)
+		client = newRetryableClient(creds)
+		graphQLURL = githubGraphQLURL
 	}
 	return &Client{
-		httpClient:  client,
-		ghClient:    github.NewClient(client),
-		pypiBaseURL: pypiURL,
-	}
+		httpClient:         client,
+		genericHTTPClient:  genericClient,
+		ghClient:           github.NewClient(client),
+		githubGraphQLURL:   graphQLURL,
+		cratesBaseURL:      cratesURL,
+		dartFlutterBaseURL: dartFlutterAPIURL,
+		goProxyBaseURL:     goProxyURL,
+		goSumDBBaseURL:     goSumDBURL,
+		npmBaseURL:         npmRegistryURL,
+		mavenBaseURL:       mavenURL,
+		pypiBaseURL:        pypiURL,
+		credentials:        creds,
+		goSumDBKey:         goSumDBKey,
+		sigstoreRoot:       sigstoreRoot,
+		mavenKeyring:       mavenKeyring,
+	}, nil
+}
+
+// newRetryableClient returns a client which retries failed requests, limited per host
+// and authenticated with the credentials.
+func newRetryableClient(creds *credentials) *http.Client {
+	retryableClient := retryablehttp.NewClient()
+	retryableClient.Logger = nil
+	// Limit the underlying transport so that each retry attempt also counts against the host limits.
+	retryableClient.HTTPClient.Transport = newAuthTransport(
+		newHostLimitTransport(retryableClient.HTTPClient.Transport, defaultHostLimit, hostLimits),
+		creds,
+	)
+	return retryableClient.StandardClient()
 }
 
 // Fetch fetches new versions based on the given config and returns a valid semver version
 // that can be used with the Go semver package. The version is guaranteed to contain a "v" prefix.
+// Versions which have been withdrawn upstream are never returned.
 func (c *Client) Fetch(ctx context.Context, config *source.Config) (string, error) {
-	version, err := c.fetch(ctx, config)
+	version, _, err := c.FetchVersions(ctx, config)
+	return version, err
+}
+
+// FetchVersions is like Fetch, but also returns the versions observed upstream in
+// ascending order, including the ignored and withdrawn ones. The observed versions
+// are returned along with the error if the latest version isn't valid.
+func (c *Client) FetchVersions(ctx context.Context, config *source.Config) (string, []string, error) {
+	version, versions, err := c.fetch(ctx, config)
+	seen := observedVersions(versions)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", config.Source.Name(), err)
+		return "", seen, c.credentials.redactError(fmt.Errorf("%s: %w", config.Source.Name(), err))
 	}
 	// We must ensure that the version is prefixed with "v" for the semver package.
 	if !strings.HasPrefix(version, "v") {
 		version = "v" + version
 	}
 	if !semver.IsValid(version) {
-		return "", fmt.Errorf("%s: invalid semver: %s", config.Source.Name(), version)
+		return "", seen, fmt.Errorf("%s: invalid semver: %s", config.Source.Name(), version)
 	}
 	if semver.Prerelease(version) != "" {
-		return "", fmt.Errorf("%s: %w: %s", config.Source.Name(), ErrSemverPrerelease, version)
+		return "", seen, fmt.Errorf("%s: %w: %s", config.Source.Name(), ErrSemverPrerelease, version)
+	}
+	return version, seen, nil
+}
+
+// observedVersions returns the semver versions of the listed versions, in ascending order.
+func observedVersions(versions []Version) []string {
+	if len(versions) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(versions)
+	slices.SortStableFunc(sorted, compareVersions)
+	seen := make([]string, len(sorted))
+	for i, version := range sorted {
+		seen[i] = version.Version
+	}
+	return seen
+}
+
+// Versions returns all valid, non-prerelease versions published by the config's source,
+// including versions which have since been withdrawn.
+func (c *Client) Versions(ctx context.Context, config *source.Config) ([]Version, error) {
+	versions, err := c.listVersions(ctx, config)
+	if err != nil {
+		return nil, c.credentials.redactError(fmt.Errorf("%s: %w", config.Source.Name(), err))
+	}
+	return versions, nil
+}
+
+// Prober returns a readiness prober for the artifacts of the config's plugin versions.
+// It probes through the client, so requests count against the host limits and are
+// authenticated with the registry credentials, and probes the source's artifacts at
+// its registry.
+func (c *Client) Prober(config *source.Config) (*readiness.Prober, error) {
+	prober := readiness.NewProber(c.httpClient)
+	baseURL, err := c.registryURL(config)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case config.Source.GoProxy != nil:
+		prober = prober.WithRegistry(readiness.EcosystemGo, baseURL)
+	case config.Source.NPMRegistry != nil:
+		prober = prober.WithRegistry(readiness.EcosystemNPM, baseURL)
+	case config.Source.Maven != nil:
+		prober = prober.WithRegistry(readiness.EcosystemMaven, baseURL)
+	case config.Source.PyPI != nil:
+		prober = prober.WithRegistry(readiness.EcosystemPyPI, baseURL)
 	}
-	return version, nil
+	return prober, nil
 }
 
-func (c *Client) fetch(ctx context.Context, config *source.Config) (string, error) {
+func (c *Client) fetch(ctx context.Context, config *source.Config) (string, []Version, error) {
 	ignoreVersions := xslices.ToStructMap(config.Source.IgnoreVersions)
 	maxVersion := config.Source.MaxVersion
 	if maxVersion != "" {
@@ -91,110 +325,160 @@ func (c *Client) fetch(ctx context.Context, config *source.Config) (string, erro
 			maxVersion = "v" + maxVersion
 		}
 		if !semver.IsValid(maxVersion) {
-			return "", fmt.Errorf("%s: max_version is not a valid semver: %s", config.Filename, config.Source.MaxVersion)
+			return "", nil, fmt.Errorf("%s: max_version is not a valid semver: %s", config.Filename, config.Source.MaxVersion)
 		}
 	}
+	versions, err := c.listVersions(ctx, config)
+	if err != nil {
+		return "", nil, err
+	}
+	version, err := latestVersion(versions, ignoreVersions, maxVersion)
+	return version, versions, err
+}
+
+// listVersions returns the versions published by the config's source. The versions
+// are listed once per source for the lifetime of the client.
+func (c *Client) listVersions(ctx context.Context, config *source.Config) ([]Version, error) {
+	cacheKey := config.CacheKey()
+	c.mu.Lock()
+	versions, ok := c.listedVersions[cacheKey]
+	c.mu.Unlock()
+	if ok {
+		return slices.Clone(versions), nil
+	}
+	versions, err := c.listSourceVersions(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.listedVersions == nil {
+		c.listedVersions = make(map[string][]Version)
+	}
+	c.listedVersions[cacheKey] = versions
+	return slices.Clone(versions), nil
+}
+
+func (c *Client) listSourceVersions(ctx context.Context, config *source.Config) ([]Version, error) {
+	baseURL, err := c.registryURL(config)
+	if err != nil {
+		return nil, err
+	}
 	switch {
 	case config.Source.GitHub != nil:
-		return c.fetchGithub(ctx, config.Source.GitHub.Owner, config.Source.GitHub.Repository, ignoreVersions, maxVersion)
+		return c.listGithub(ctx, config.Source.GitHub.Owner, config.Source.GitHub.Repository, config.Source.GitHub.RequireRelease)
 	case config.Source.DartFlutter != nil:
-		return c.fetchDartFlutter(ctx, config.Source.DartFlutter.Name, ignoreVersions, maxVersion)
+		return c.listDartFlutter(ctx, config.Source.DartFlutter.Name)
 	case config.Source.GoProxy != nil:
-		return c.fetchGoProxy(ctx, config.Source.GoProxy.Name, ignoreVersions, maxVersion)
+		return c.listGoProxy(ctx, baseURL, config.Source.GoProxy.Name)
 	case config.Source.NPMRegistry != nil:
-		return c.fetchNPMRegistry(ctx, config.Source.NPMRegistry.Name, ignoreVersions, maxVersion)
+		return c.listNPMRegistry(ctx, baseURL, config.Source.NPMRegistry.Name)
 	case config.Source.Maven != nil:
-		return c.fetchMaven(ctx, config.Source.Maven.Group, config.Source.Maven.Name, ignoreVersions, maxVersion)
+		return c.listMaven(ctx, baseURL, config.Source.Maven.Group, config.Source.Maven.Name, config.Source.Maven.Qualifier)
 	case config.Source.Crates != nil:
-		return c.fetchCrate(ctx, config.Source.Crates.CrateName, ignoreVersions, maxVersion)
+		return c.listCrate(ctx, config.Source.Crates.CrateName)
 	case config.Source.PyPI != nil:
-		return c.fetchPyPI(ctx, config.Source.PyPI.Name, ignoreVersions, maxVersion)
+		return c.listPyPI(ctx, baseURL, config.Source.PyPI.Name)
+	case config.Source.HTTP != nil:
+		return c.listHTTP(ctx, config.Source.HTTP)
+	case config.Source.OCI != nil:
+		return c.listOCI(ctx, config.Source.OCI.Image)
 	}
-	return "", errors.New("failed to match a source")
+	return nil, errors.New("failed to match a source")
 }
 
-func (c *Client) fetchDartFlutter(
-	ctx context.Context,
-	name string,
-	ignoreVersions map[string]struct{},
-	maxVersion string,
-) (string, error) {
-	request, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodGet,
-		fmt.Sprintf("%s/%s", dartFlutterAPIURL, strings.TrimPrefix(name, "/")),
-		nil,
-	)
-	if err != nil {
-		return "", err
+// registryURL returns the base URL of the registry for sources which support private
+// registries: the configured registry if set, otherwise the public registry.
+// It returns an empty string for other sources.
+func (c *Client) registryURL(config *source.Config) (string, error) {
+	var registry, defaultURL string
+	switch {
+	case config.Source.GoProxy != nil:
+		registry, defaultURL = config.Source.GoProxy.Registry, c.goProxyBaseURL
+	case config.Source.NPMRegistry != nil:
+		registry, defaultURL = config.Source.NPMRegistry.Registry, c.npmBaseURL
+	case config.Source.Maven != nil:
+		registry, defaultURL = config.Source.Maven.Registry, c.mavenBaseURL
+	case config.Source.PyPI != nil:
+		registry, defaultURL = config.Source.PyPI.Registry, c.pypiBaseURL
+	default:
+		return "", nil
 	}
-	response, err := c.httpClient.Do(request)
+	if registry == "" {
+		return defaultURL, nil
+	}
+	if err := validateRegistryURL(registry); err != nil {
+		return "", fmt.Errorf("%s: %w", config.Filename, err)
+	}
+	return strings.TrimSuffix(registry, "/"), nil
+}
+
+// latestVersion returns the latest version which hasn't been withdrawn, isn't ignored,
+// and is below the (exclusive) max version if set.
+func latestVersion(versions []Version, ignoreVersions map[string]struct{}, maxVersion string) (string, error) {
+	var latest *Version
+	for i, version := range versions {
+		if version.Withdrawn != "" {
+			continue
+		}
+		if _, ok := ignoreVersions[version.Version]; ok {
+			continue
+		}
+		if maxVersion != "" && semver.Compare(version.Version, maxVersion) >= 0 {
+			continue
+		}
+		if latest == nil || compareVersions(*latest, version) < 0 {
+			latest = &versions[i]
+		}
+	}
+	if latest == nil {
+		return "", errNoVersions
+	}
+	return latest.Version, nil
+}
+
+func (c *Client) listDartFlutter(ctx context.Context, name string) ([]Version, error) {
+	response, err := c.get(ctx, fmt.Sprintf("%s/%s", c.dartFlutterBaseURL, strings.TrimPrefix(name, "/")), nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer response.Body.Close()
-	if response.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("received status code %d retrieving %q", response.StatusCode, request.URL.String())
-	}
 
 	var data struct {
-		Latest struct {
-			Version string `json:"version"`
-		} `json:"latest"`
 		Versions []struct {
 			Version string `json:"version"`
+			// A retracted version is still available but will not be selected by
+			// the pub client. See https://dart.dev/tools/pub/publishing#retract.
+			Retracted bool `json:"retracted"`
 		} `json:"versions"`
 	}
 	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
-		return "", err
+		return nil, err
 	}
-	if len(ignoreVersions) == 0 && maxVersion == "" {
-		return data.Latest.Version, nil
-	}
-	var latestVersion string
+	versions := make([]Version, 0, len(data.Versions))
 	for _, version := range data.Versions {
-		version, ok := ensureSemverPrefix(version.Version)
+		v, ok := ensureSemverPrefix(version.Version)
 		if !ok {
 			continue
 		}
-		if _, ok := ignoreVersions[version]; ok {
-			continue
-		}
-		if maxVersion != "" && semver.Compare(version, maxVersion) >= 0 {
-			continue
+		var withdrawn string
+		if version.Retracted {
+			withdrawn = "retracted"
 		}
-		if latestVersion == "" || semver.Compare(latestVersion, version) < 0 {
-			latestVersion = version
-		}
-	}
-	// Shouldn't be possible unless we've ignored all versions
-	if latestVersion == "" {
-		return "", fmt.Errorf("failed to calculate latest version for dart source %s", name)
+		versions = append(versions, Version{Version: v, Upstream: version.Version, Withdrawn: withdrawn})
 	}
-	return latestVersion, nil
+	return versions, nil
 }
 
-func (c *Client) fetchCrate(ctx context.Context, name string, ignoreVersions map[string]struct{}, maxVersion string) (string, error) {
-	request, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodGet,
-		fmt.Sprintf("%s/crates/%s", cratesURL, strings.TrimPrefix(name, "/")),
-		nil,
-	)
-	if err != nil {
-		return "", err
-	}
+func (c *Client) listCrate(ctx context.Context, name string) ([]Version, error) {
 	// See https://github.com/bufbuild/plugins/issues/252 for more information.
-	// We must be careful with this API and respect the crawling policy.
-	request.Header.Set("User-Agent", "bufbuild (github.com/bufbuild/plugins)")
-	response, err := c.httpClient.Do(request)
+	// We must be careful with this API and respect the crawling policy (also enforced by hostLimits).
+	header := http.Header{"User-Agent": []string{"bufbuild (github.com/bufbuild/plugins)"}}
+	response, err := c.get(ctx, fmt.Sprintf("%s/crates/%s", c.cratesBaseURL, strings.TrimPrefix(name, "/")), header)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer response.Body.Close()
-	if response.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("received status code %d retrieving %q", response.StatusCode, request.URL.String())
-	}
 
 	var data struct {
 		Versions []struct {
@@ -203,139 +487,152 @@ func (c *Client) fetchCrate(ctx context.Context, name string, ignoreVersions map
 		} `json:"versions"`
 	}
 	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
-		return "", err
+		return nil, err
 	}
-	versions := make([]string, 0, len(data.Versions))
+	versions := make([]Version, 0, len(data.Versions))
 	for _, version := range data.Versions {
-		if version.Yanked {
-			// A yanked version a is a published crate's version that has been removed
-			// from the server's index.
-			continue
-		}
 		v, ok := ensureSemverPrefix(version.Num)
 		if !ok {
 			continue
 		}
-		if _, ok := ignoreVersions[v]; ok {
-			continue
-		}
-		if maxVersion != "" && semver.Compare(v, maxVersion) >= 0 {
-			continue
+		var withdrawn string
+		if version.Yanked {
+			// A yanked version a is a published crate's version that has been removed
+			// from the server's index.
+			withdrawn = "yanked"
 		}
-		versions = append(versions, v)
+		versions = append(versions, Version{Version: v, Upstream: version.Num, Withdrawn: withdrawn})
 	}
-	if len(versions) == 0 {
-		return "", errors.New("no versions found")
-	}
-	semver.Sort(versions)
-	return versions[len(versions)-1], nil
+	return versions, nil
 }
 
-func (c *Client) fetchGoProxy(ctx context.Context, name string, ignoreVersions map[string]struct{}, maxVersion string) (string, error) {
-	if len(ignoreVersions) > 0 {
-		return "", errors.New("ignore_versions not supported yet for go sources")
+func (c *Client) listGoProxy(ctx context.Context, proxyURL string, name string) ([]Version, error) {
+	modulePath := strings.TrimPrefix(name, "/")
+	latestResponse, err := c.get(ctx, fmt.Sprintf("%s/%s/@latest", proxyURL, modulePath), nil)
+	if err != nil {
+		return nil, err
 	}
-	if maxVersion != "" {
-		return "", errors.New("max_version not supported yet for go sources")
+	defer latestResponse.Body.Close()
+	var latest struct {
+		Version string `json:"Version"` //nolint:tagliatelle
 	}
-	request, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodGet,
-		fmt.Sprintf("%s/%s/@latest", goProxyURL, strings.TrimPrefix(name, "/")),
-		nil,
-	)
+	if err := json.NewDecoder(latestResponse.Body).Decode(&latest); err != nil {
+		return nil, err
+	}
+	listResponse, err := c.get(ctx, fmt.Sprintf("%s/%s/@v/list", proxyURL, modulePath), nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	response, err := c.httpClient.Do(request)
+	defer listResponse.Body.Close()
+	listBody, err := io.ReadAll(listResponse.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer response.Body.Close()
-	if response.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("received status code %d retrieving %q", response.StatusCode, request.URL.String())
+	rawVersions := strings.Fields(string(listBody))
+	if !semver.IsValid(latest.Version) || semver.Prerelease(latest.Version) != "" {
+		if len(rawVersions) == 0 {
+			// The module has no tagged releases, only pseudo-versions.
+			return nil, fmt.Errorf("%w: %s", ErrSemverPrerelease, latest.Version)
+		}
+	} else {
+		rawVersions = append(rawVersions, latest.Version)
 	}
-
-	var data struct {
-		Version string `json:"Version"` //nolint:tagliatelle
+	var versions []Version
+	seen := make(map[string]struct{}, len(rawVersions))
+	for _, rawVersion := range rawVersions {
+		v, ok := ensureSemverPrefix(rawVersion)
+		if !ok {
+			continue
+		}
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		versions = append(versions, Version{Version: v, Upstream: rawVersion})
 	}
-	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
-		return "", err
+	if len(versions) == 0 {
+		return nil, nil
+	}
+	// Retractions are declared in the go.mod of the latest version of the module.
+	// See https://go.dev/ref/mod#go-mod-file-retract.
+	retractions, err := c.goModRetractions(ctx, proxyURL, modulePath, maxVersion(versions))
+	if err != nil {
+		return nil, err
+	}
+	for i, version := range versions {
+		for _, retract := range retractions {
+			if semver.Compare(version.Version, retract.Low) >= 0 && semver.Compare(version.Version, retract.High) <= 0 {
+				versions[i].Withdrawn = "retracted"
+				if retract.Rationale != "" {
+					versions[i].Withdrawn += ": " + retract.Rationale
+				}
+				break
+			}
+		}
 	}
-	return data.Version, nil
+	return versions, nil
 }
 
-func (c *Client) fetchNPMRegistry(ctx context.Context, name string, ignoreVersions map[string]struct{}, maxVersion string) (string, error) {
-	request, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodGet,
-		fmt.Sprintf("%s/%s", npmRegistryURL, strings.TrimPrefix(name, "/")),
-		nil,
-	)
+func (c *Client) goModRetractions(ctx context.Context, proxyURL string, modulePath string, version string) ([]*modfile.Retract, error) {
+	response, err := c.get(ctx, fmt.Sprintf("%s/%s/@v/%s.mod", proxyURL, modulePath, version), nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	response, err := c.httpClient.Do(request)
+	defer response.Body.Close()
+	content, err := io.ReadAll(response.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer response.Body.Close()
-	if response.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("received status code %d retrieving %q", response.StatusCode, request.URL.String())
+	modFile, err := modfile.ParseLax("go.mod", content, nil)
+	if err != nil {
+		return nil, err
 	}
+	return modFile.Retract, nil
+}
+
+func (c *Client) listNPMRegistry(ctx context.Context, registryURL string, name string) ([]Version, error) {
+	response, err := c.get(ctx, fmt.Sprintf("%s/%s", registryURL, strings.TrimPrefix(name, "/")), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
 
 	var data struct {
-		Versions map[string]any `json:"versions"`
+		Versions map[string]struct {
+			// Deprecated is set to the deprecation message by "npm deprecate".
+			// It is usually a string, but older packages may use other types.
+			Deprecated any `json:"deprecated"`
+		} `json:"versions"`
 	}
 	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
-		return "", err
+		return nil, err
 	}
-	latestVersion := ""
-	for version := range data.Versions {
-		semverVersion, ok := ensureSemverPrefix(version)
+	versions := make([]Version, 0, len(data.Versions))
+	for version, metadata := range data.Versions {
+		v, ok := ensureSemverPrefix(version)
 		if !ok {
 			continue
 		}
-		if _, ignored := ignoreVersions[semverVersion]; ignored {
-			continue
-		}
-		if maxVersion != "" && semver.Compare(semverVersion, maxVersion) >= 0 {
-			continue
-		}
-		if latestVersion == "" || semver.Compare(latestVersion, semverVersion) < 0 {
-			latestVersion = semverVersion
+		var withdrawn string
+		if message, ok := metadata.Deprecated.(string); ok && message != "" {
+			withdrawn = "deprecated: " + message
 		}
+		versions = append(versions, Version{Version: v, Upstream: version, Withdrawn: withdrawn})
 	}
-	if latestVersion == "" {
-		return "", errors.New("no versions found")
-	}
-	return latestVersion, nil
+	return versions, nil
 }
 
-func (c *Client) fetchMaven(
-	ctx context.Context,
-	group string,
-	name string,
-	ignoreVersions map[string]struct{},
-	maxVersion string,
-) (string, error) {
+func (c *Client) listMaven(ctx context.Context, repositoryURL string, group string, name string, qualifier string) ([]Version, error) {
 	groupComponents := strings.Split(group, ".")
-	targetURL, err := url.JoinPath(mavenURL, append(groupComponents, name, "maven-metadata.xml")...)
+	targetURL, err := url.JoinPath(repositoryURL, append(groupComponents, name, "maven-metadata.xml")...)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	request, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
-	if err != nil {
-		return "", err
-	}
-	response, err := c.httpClient.Do(request)
+	response, err := c.get(ctx, targetURL, nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer response.Body.Close()
-	if response.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("received status code %d retrieving %q", response.StatusCode, request.URL.String())
-	}
 	var metadata struct {
 		GroupID    string `xml:"groupId"`
 		ArtifactID string `xml:"artifactId"`
@@ -347,127 +644,135 @@ func (c *Client) fetchMaven(
 		} `xml:"versioning"`
 	}
 	if err := xml.NewDecoder(response.Body).Decode(&metadata); err != nil {
-		return "", err
+		return nil, err
 	}
-	latestVersion := ""
+	// Maven Central is immutable and has no concept of withdrawn versions.
+	versions := make([]Version, 0, len(metadata.Versioning.Versions))
 	for _, version := range metadata.Versioning.Versions {
-		v, ok := ensureSemverPrefix(version)
+		v, ok := mavenToSemver(version, qualifier)
 		if !ok {
 			continue
 		}
-		v = semver.Canonical(v)
-		if _, ok := ignoreVersions[v]; ok {
+		versions = append(versions, Version{Version: v, Upstream: version, scheme: schemeMaven})
+	}
+	return versions, nil
+}
+
+func (c *Client) listPyPI(ctx context.Context, indexURL string, name string) ([]Version, error) {
+	header := http.Header{"Accept": []string{"application/vnd.pypi.simple.v1+json"}}
+	response, err := c.get(ctx, fmt.Sprintf("%s/%s/", indexURL, strings.TrimPrefix(name, "/")), header)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var data struct {
+		Versions []string `json:"versions"`
+		Files    []struct {
+			Filename string `json:"filename"`
+			// Yanked is either a boolean or a string containing the reason (PEP 592).
+			Yanked any `json:"yanked"`
+		} `json:"files"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	// A release is yanked when all of its files are yanked.
+	yankedReasons := make(map[string]string)
+	notYanked := make(map[string]struct{})
+	for _, file := range data.Files {
+		version := pypiFileVersion(file.Filename)
+		if version == "" {
 			continue
 		}
-		if maxVersion != "" && semver.Compare(v, maxVersion) >= 0 {
+		switch yanked := file.Yanked.(type) {
+		case string:
+			yankedReasons[version] = "yanked: " + yanked
+		case bool:
+			if !yanked {
+				notYanked[version] = struct{}{}
+			} else if _, ok := yankedReasons[version]; !ok {
+				yankedReasons[version] = "yanked"
+			}
+		default:
+			notYanked[version] = struct{}{}
+		}
+	}
+	versions := make([]Version, 0, len(data.Versions))
+	for _, version := range data.Versions {
+		v, ok := pep440ToSemver(version)
+		if !ok {
 			continue
 		}
-		if latestVersion == "" || semver.Compare(latestVersion, v) < 0 {
-			latestVersion = v
+		var withdrawn string
+		if _, ok := notYanked[version]; !ok {
+			withdrawn = yankedReasons[version]
 		}
+		versions = append(versions, Version{Version: v, Upstream: version, Withdrawn: withdrawn, scheme: schemePEP440})
 	}
-	if latestVersion == "" {
-		return "", errors.New("failed to determine latest version from metadata")
-	}
-	return latestVersion, nil
+	return versions, nil
 }
 
-func (c *Client) fetchGithub(
-	ctx context.Context,
-	owner string,
-	repository string,
-	ignoreVersions map[string]struct{},
-	maxVersion string,
-) (string, error) {
-	// With the GitHub API we have a few options:
-	//
-	// ✅ 1. list all git tags
-	// 		https://docs.github.com/en/rest/repos/repos#list-repository-tags
-	// ❌ 2. get latest by release only (does not include prereleases)
-	// 		https://docs.github.com/en/rest/releases/releases#get-the-latest-release
-	// ❌ 3. list all releases (does not include regular Git tags that have not been associated with a release)
-	// 		https://docs.github.com/en/rest/releases/releases#list-releases
-	var page int
-	var versions []string
-	for {
-		tags, response, err := c.ghClient.Repositories.ListTags(ctx, owner, repository, &github.ListOptions{
-			Page:    page,
-			PerPage: 100,
-		})
-		if err != nil {
-			return "", err
+// pypiFileVersion returns the version from a PyPI distribution filename.
+// Wheels are named {name}-{version}(-{build})?-{python}-{abi}-{platform}.whl and
+// source distributions are named {name}-{version}.tar.gz (or .zip).
+// Returns an empty string if the filename is not recognized.
+func pypiFileVersion(filename string) string {
+	if base, ok := strings.CutSuffix(filename, ".whl"); ok {
+		parts := strings.Split(base, "-")
+		if len(parts) < 5 {
+			return ""
 		}
-		for _, tag := range tags {
-			if tag.Name == nil {
-				continue
-			}
-			if v, ok := ensureSemverPrefix(*tag.Name); ok {
-				if _, ok := ignoreVersions[v]; ok {
-					continue
-				}
-				if maxVersion != "" && semver.Compare(v, maxVersion) >= 0 {
-					continue
-				}
-				versions = append(versions, v)
+		return parts[1]
+	}
+	for _, suffix := range []string{".tar.gz", ".zip"} {
+		if base, ok := strings.CutSuffix(filename, suffix); ok {
+			idx := strings.LastIndex(base, "-")
+			if idx == -1 {
+				return ""
 			}
-		}
-		page = response.NextPage
-		if page == 0 {
-			break
+			return base[idx+1:]
 		}
 	}
-	if len(versions) == 0 {
-		return "", errors.New("no versions found")
-	}
-	semver.Sort(versions)
-	return versions[len(versions)-1], nil
+	return ""
 }
 
-func (c *Client) fetchPyPI(ctx context.Context, name string, ignoreVersions map[string]struct{}, maxVersion string) (string, error) {
-	request, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodGet,
-		fmt.Sprintf("%s/%s/", c.pypiBaseURL, strings.TrimPrefix(name, "/")),
-		nil,
-	)
+// get issues a GET request to the target URL and returns the response if it has
+// a 200 status code. A 404 status code returns an error wrapping errNotFound. The caller is responsible for closing the response body.
+func (c *Client) get(ctx context.Context, targetURL string, header http.Header) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	for key, values := range header {
+		for _, value := range values {
+			request.Header.Add(key, value)
+		}
 	}
-	request.Header.Set("Accept", "application/vnd.pypi.simple.v1+json")
 	response, err := c.httpClient.Do(request)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, fmt.Errorf("%w: %q", errNotFound, request.URL.Redacted())
 	}
-	defer response.Body.Close()
 	if response.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("received status code %d retrieving %q", response.StatusCode, request.URL.String())
+		response.Body.Close()
+		return nil, fmt.Errorf("received status code %d retrieving %q", response.StatusCode, request.URL.Redacted())
 	}
+	return response, nil
+}
 
-	var data struct {
-		Versions []string `json:"versions"`
-	}
-	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
-		return "", err
-	}
-	var versions []string
-	for _, version := range data.Versions {
-		v, ok := ensureSemverPrefix(version)
-		if !ok {
-			continue
+// maxVersion returns the highest version in the slice.
+func maxVersion(versions []Version) string {
+	var highest string
+	for _, version := range versions {
+		if highest == "" || semver.Compare(highest, version.Version) < 0 {
+			highest = version.Version
 		}
-		if _, ok := ignoreVersions[v]; ok {
-			continue
-		}
-		if maxVersion != "" && semver.Compare(v, maxVersion) >= 0 {
-			continue
-		}
-		versions = append(versions, v)
-	}
-	if len(versions) == 0 {
-		return "", errors.New("no versions found")
 	}
-	semver.Sort(versions)
-	return versions[len(versions)-1], nil
+	return highest
 }
 
 // ensureSemverPrefix checks if the given version is valid semver, optionally
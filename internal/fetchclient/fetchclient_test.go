@@ -4,10 +4,15 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/mod/semver"
+
+	"github.com/bufbuild/plugins/internal/source"
 )
 
 func TestFetchPyPI(t *testing.T) {
@@ -43,6 +48,21 @@ func TestFetchPyPI(t *testing.T) {
 			maxVersion:  "v5.0.0",
 			wantVersion: "v3.6.0",
 		},
+		{
+			name:        "skips post-releases",
+			versions:    []string{"1.2.0", "1.3", "1.3.post1"},
+			wantVersion: "v1.3",
+		},
+		{
+			name:        "skips post-releases of newer releases",
+			versions:    []string{"1.2.0", "1.3.post1"},
+			wantVersion: "v1.2.0",
+		},
+		{
+			name:        "skips versions with an epoch",
+			versions:    []string{"2!1.0", "1.5.0"},
+			wantVersion: "v1.5.0",
+		},
 		{
 			name:     "error when no valid versions remain",
 			versions: []string{"2.0.0b7", "2.0.0rc1"},
@@ -71,7 +91,9 @@ func TestFetchPyPI(t *testing.T) {
 			if ignoreVersions == nil {
 				ignoreVersions = map[string]struct{}{}
 			}
-			got, err := c.fetchPyPI(t.Context(), "mypy-protobuf", ignoreVersions, tt.maxVersion)
+			versions, err := c.listPyPI(t.Context(), srv.URL, "mypy-protobuf")
+			require.NoError(t, err)
+			got, err := latestVersion(versions, ignoreVersions, tt.maxVersion)
 			if tt.wantErr != "" {
 				require.ErrorContains(t, err, tt.wantErr)
 				return
@@ -81,3 +103,142 @@ func TestFetchPyPI(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchExcludesWithdrawnVersions(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/crates/prost", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"versions":[{"num":"0.14.1","yanked":false},{"num":"0.14.2","yanked":true}]}`))
+	})
+	mux.HandleFunc("/dart/protoc_plugin", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"versions":[{"version":"21.1.0"},{"version":"21.1.1","retracted":true}]}`))
+	})
+	mux.HandleFunc("/npm/@bufbuild/protoc-gen-es", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"versions":{"2.2.0":{},"2.2.1":{"deprecated":"broken release"},"2.1.0":{"deprecated":false}}}`))
+	})
+	mux.HandleFunc("/pypi/mypy-protobuf/", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{
+  "versions": ["3.5.0", "3.6.0", "3.7.0"],
+  "files": [
+    {"filename": "mypy_protobuf-3.5.0-py3-none-any.whl", "yanked": false},
+    {"filename": "mypy-protobuf-3.6.0.tar.gz", "yanked": false},
+    {"filename": "mypy_protobuf-3.6.0-py3-none-any.whl", "yanked": "bad wheel"},
+    {"filename": "mypy-protobuf-3.7.0.tar.gz", "yanked": "broken"},
+    {"filename": "mypy_protobuf-3.7.0-py3-none-any.whl", "yanked": true}
+  ]
+}`))
+	})
+	mux.HandleFunc("/go/example.com/mod/@latest", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"Version":"v1.3.0"}`))
+	})
+	mux.HandleFunc("/go/example.com/mod/@v/list", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("v1.0.0\nv1.1.0\nv1.2.0\nv1.3.0\n"))
+	})
+	mux.HandleFunc("/go/example.com/mod/@v/v1.3.0.mod", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("module example.com/mod\n\ngo 1.24\n\nretract v1.3.0 // published accidentally\n\nretract [v1.1.0, v1.2.0]\n"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	c := &Client{
+		httpClient:         srv.Client(),
+		cratesBaseURL:      srv.URL,
+		dartFlutterBaseURL: srv.URL + "/dart",
+		goProxyBaseURL:     srv.URL + "/go",
+		npmBaseURL:         srv.URL + "/npm",
+		pypiBaseURL:        srv.URL + "/pypi",
+	}
+
+	tests := []struct {
+		name          string
+		source        source.Source
+		wantVersion   string
+		wantWithdrawn map[string]string
+	}{
+		{
+			name:          "crates yanked",
+			source:        source.Source{Crates: &source.CratesConfig{CrateName: "prost"}},
+			wantVersion:   "v0.14.1",
+			wantWithdrawn: map[string]string{"v0.14.2": "yanked"},
+		},
+		{
+			name:          "dart retracted",
+			source:        source.Source{DartFlutter: &source.DartFlutterConfig{Name: "protoc_plugin"}},
+			wantVersion:   "v21.1.0",
+			wantWithdrawn: map[string]string{"v21.1.1": "retracted"},
+		},
+		{
+			name:          "npm deprecated",
+			source:        source.Source{NPMRegistry: &source.NPMRegistryConfig{Name: "@bufbuild/protoc-gen-es"}},
+			wantVersion:   "v2.2.0",
+			wantWithdrawn: map[string]string{"v2.2.1": "deprecated: broken release"},
+		},
+		{
+			name:          "pypi yanked",
+			source:        source.Source{PyPI: &source.PyPIConfig{Name: "mypy-protobuf"}},
+			wantVersion:   "v3.6.0",
+			wantWithdrawn: map[string]string{"v3.7.0": "yanked: broken"},
+		},
+		{
+			name:        "go retracted",
+			source:      source.Source{GoProxy: &source.GoProxyConfig{Name: "example.com/mod"}},
+			wantVersion: "v1.0.0",
+			wantWithdrawn: map[string]string{
+				"v1.1.0": "retracted",
+				"v1.2.0": "retracted",
+				"v1.3.0": "retracted: published accidentally",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			config := &source.Config{Source: tt.source}
+			got, err := c.Fetch(t.Context(), config)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVersion, got)
+			// The withdrawn versions are observed, though never fetched.
+			_, seen, err := c.FetchVersions(t.Context(), config)
+			require.NoError(t, err)
+			assert.Contains(t, seen, tt.wantVersion)
+			for version := range tt.wantWithdrawn {
+				assert.Contains(t, seen, version)
+			}
+			assert.True(t, slices.IsSortedFunc(seen, semver.Compare), seen)
+			versions, err := c.Versions(t.Context(), config)
+			require.NoError(t, err)
+			withdrawn := make(map[string]string)
+			for _, version := range versions {
+				if version.Withdrawn != "" {
+					withdrawn[version.Version] = version.Withdrawn
+				}
+			}
+			assert.Equal(t, tt.wantWithdrawn, withdrawn)
+		})
+	}
+}
+
+func TestListVersionsOnce(t *testing.T) {
+	t.Parallel()
+	var listed atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/npm/@acme/protoc-gen-plugin", func(w http.ResponseWriter, _ *http.Request) {
+		listed.Add(1)
+		_, _ = w.Write([]byte(`{"versions":{"2.1.0":{},"2.2.0":{}}}`))
+	})
+	mux.HandleFunc("/npm/@acme/protoc-gen-plugin/2.2.0", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"dist":{"tarball":"https://registry.npmjs.org/@acme/protoc-gen-plugin/-/protoc-gen-plugin-2.2.0.tgz","integrity":"sha512-xyz=="}}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	c := &Client{httpClient: srv.Client(), npmBaseURL: srv.URL + "/npm"}
+	config := &source.Config{Source: source.Source{NPMRegistry: &source.NPMRegistryConfig{Name: "@acme/protoc-gen-plugin"}}}
+
+	version, err := c.Fetch(t.Context(), config)
+	require.NoError(t, err)
+	assert.Equal(t, "v2.2.0", version)
+	provenance, err := c.Provenance(t.Context(), config, version)
+	require.NoError(t, err)
+	assert.Equal(t, "2.2.0", provenance.Version)
+	// The versions are listed once, when fetching the latest version.
+	assert.Equal(t, int32(1), listed.Load())
+}
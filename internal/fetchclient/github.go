@@ -0,0 +1,285 @@
+package fetchclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v72/github"
+)
+
+// githubGraphQLURL is the GitHub GraphQL API endpoint. The GraphQL API requires
+// authentication, so it is only used when a GITHUB_TOKEN is available.
+const githubGraphQLURL = "https://api.github.com/graphql"
+
+// githubTagsQuery lists tags with their commit dates and (optionally) releases.
+// Both connections are paginated independently using the same query: once a
+// connection has no more pages it is excluded using the @include directive.
+const githubTagsQuery = `query($owner: String!, $name: String!, $tagsCursor: String, $releasesCursor: String, $withTags: Boolean!, $withReleases: Boolean!) {
+  repository(owner: $owner, name: $name) {
+    refs(refPrefix: "refs/tags/", first: 100, after: $tagsCursor) @include(if: $withTags) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        name
+        target {
+          ... on Commit { oid committedDate }
+          ... on Tag { target { ... on Commit { oid committedDate } } }
+        }
+      }
+    }
+    releases(first: 100, after: $releasesCursor) @include(if: $withReleases) {
+      pageInfo { hasNextPage endCursor }
+      nodes { tagName isDraft isPrerelease }
+    }
+  }
+}`
+
+// githubTag is a git tag in a GitHub repository.
+type githubTag struct {
+	name string
+	// commit is the SHA of the commit the tag points to, if known.
+	commit string
+	// time is the commit date, if known.
+	time time.Time
+}
+
+// githubRelease is a GitHub release.
+type githubRelease struct {
+	tagName    string
+	draft      bool
+	prerelease bool
+}
+
+func (c *Client) listGithub(ctx context.Context, owner string, repository string, requireRelease bool) ([]Version, error) {
+	// With the GitHub API we have a few options:
+	//
+	// ✅ 1. list all git tags
+	// 		https://docs.github.com/en/rest/repos/repos#list-repository-tags
+	// ❌ 2. get latest by release only (does not include prereleases)
+	// 		https://docs.github.com/en/rest/releases/releases#get-the-latest-release
+	// ❌ 3. list all releases (does not include regular Git tags that have not been associated with a release)
+	// 		https://docs.github.com/en/rest/releases/releases#list-releases
+	//
+	// When requireRelease is set, tags are cross-referenced with releases to exclude
+	// tags without a published, non-prerelease release.
+	// Git tags have no concept of withdrawn versions.
+	var (
+		tags     []githubTag
+		releases []githubRelease
+		err      error
+	)
+	if c.githubGraphQLURL != "" {
+		tags, releases, err = c.queryGithubTags(ctx, owner, repository, requireRelease)
+	} else {
+		tags, releases, err = c.listGithubTagsREST(ctx, owner, repository, requireRelease)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var publishedReleases map[string]struct{}
+	if requireRelease {
+		publishedReleases = make(map[string]struct{}, len(releases))
+		for _, release := range releases {
+			if release.draft || release.prerelease {
+				continue
+			}
+			publishedReleases[release.tagName] = struct{}{}
+		}
+	}
+	var versions []Version
+	for _, tag := range tags {
+		if requireRelease {
+			if _, ok := publishedReleases[tag.name]; !ok {
+				continue
+			}
+		}
+		if v, ok := ensureSemverPrefix(tag.name); ok {
+			versions = append(versions, Version{Version: v, Upstream: tag.name, Time: tag.time, commit: tag.commit})
+		}
+	}
+	return versions, nil
+}
+
+// queryGithubTags lists tags (with commit dates) and, if withReleases is set, releases
+// using the GraphQL API.
+func (c *Client) queryGithubTags(ctx context.Context, owner string, repository string, withReleases bool) ([]githubTag, []githubRelease, error) {
+	type pageInfo struct {
+		HasNextPage bool   `json:"hasNextPage"` //nolint:tagliatelle
+		EndCursor   string `json:"endCursor"`   //nolint:tagliatelle
+	}
+	type commit struct {
+		OID           string    `json:"oid"`
+		CommittedDate time.Time `json:"committedDate"` //nolint:tagliatelle
+	}
+	var response struct {
+		Data struct {
+			Repository *struct {
+				Refs *struct {
+					PageInfo pageInfo `json:"pageInfo"` //nolint:tagliatelle
+					Nodes    []struct {
+						Name   string `json:"name"`
+						Target struct {
+							commit
+							// Target is set for annotated tags.
+							Target *commit `json:"target"`
+						} `json:"target"`
+					} `json:"nodes"`
+				} `json:"refs"`
+				Releases *struct {
+					PageInfo pageInfo `json:"pageInfo"` //nolint:tagliatelle
+					Nodes    []struct {
+						TagName      string `json:"tagName"`      //nolint:tagliatelle
+						IsDraft      bool   `json:"isDraft"`      //nolint:tagliatelle
+						IsPrerelease bool   `json:"isPrerelease"` //nolint:tagliatelle
+					} `json:"nodes"`
+				} `json:"releases"`
+			} `json:"repository"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	var (
+		tags           []githubTag
+		releases       []githubRelease
+		tagsCursor     *string
+		releasesCursor *string
+		withTags       = true
+	)
+	for withTags || withReleases {
+		body, err := json.Marshal(map[string]any{
+			"query": githubTagsQuery,
+			"variables": map[string]any{
+				"owner":          owner,
+				"name":           repository,
+				"tagsCursor":     tagsCursor,
+				"releasesCursor": releasesCursor,
+				"withTags":       withTags,
+				"withReleases":   withReleases,
+			},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.githubGraphQLURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, nil, err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		httpResponse, err := c.httpClient.Do(request)
+		if err != nil {
+			return nil, nil, err
+		}
+		if httpResponse.StatusCode != http.StatusOK {
+			httpResponse.Body.Close()
+			return nil, nil, fmt.Errorf("received status code %d retrieving %q", httpResponse.StatusCode, request.URL.Redacted())
+		}
+		response.Data.Repository = nil
+		response.Errors = nil
+		err = json.NewDecoder(httpResponse.Body).Decode(&response)
+		httpResponse.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(response.Errors) > 0 {
+			messages := make([]string, len(response.Errors))
+			for i, graphQLErr := range response.Errors {
+				messages[i] = graphQLErr.Message
+			}
+			return nil, nil, fmt.Errorf("github graphql: %s", strings.Join(messages, "; "))
+		}
+		repo := response.Data.Repository
+		if repo == nil {
+			return nil, nil, fmt.Errorf("github repository %s/%s not found", owner, repository)
+		}
+		if withTags {
+			if repo.Refs == nil {
+				return nil, nil, errors.New("github graphql: missing refs in response")
+			}
+			for _, node := range repo.Refs.Nodes {
+				target := node.Target.commit
+				if node.Target.Target != nil {
+					target = *node.Target.Target
+				}
+				tags = append(tags, githubTag{name: node.Name, commit: target.OID, time: target.CommittedDate})
+			}
+			withTags = repo.Refs.PageInfo.HasNextPage
+			cursor := repo.Refs.PageInfo.EndCursor
+			tagsCursor = &cursor
+		}
+		if withReleases {
+			if repo.Releases == nil {
+				return nil, nil, errors.New("github graphql: missing releases in response")
+			}
+			for _, node := range repo.Releases.Nodes {
+				releases = append(releases, githubRelease{tagName: node.TagName, draft: node.IsDraft, prerelease: node.IsPrerelease})
+			}
+			withReleases = repo.Releases.PageInfo.HasNextPage
+			cursor := repo.Releases.PageInfo.EndCursor
+			releasesCursor = &cursor
+		}
+	}
+	return tags, releases, nil
+}
+
+// listGithubTagsREST lists tags and, if withReleases is set, releases using the REST API.
+// It is used when no GITHUB_TOKEN is available for the GraphQL API. Tags listed this
+// way don't include commit dates.
+func (c *Client) listGithubTagsREST(ctx context.Context, owner string, repository string, withReleases bool) ([]githubTag, []githubRelease, error) {
+	var tags []githubTag
+	for page := 0; ; {
+		repoTags, response, err := c.ghClient.Repositories.ListTags(ctx, owner, repository, &github.ListOptions{
+			Page:    page,
+			PerPage: 100,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, tag := range repoTags {
+			if tag.Name == nil {
+				continue
+			}
+			tags = append(tags, githubTag{name: tag.GetName(), commit: tag.GetCommit().GetSHA()})
+		}
+		page = response.NextPage
+		if page == 0 {
+			break
+		}
+	}
+	if !withReleases {
+		return tags, nil, nil
+	}
+	var releases []githubRelease
+	for page := 0; ; {
+		repoReleases, response, err := c.ghClient.Repositories.ListReleases(ctx, owner, repository, &github.ListOptions{
+			Page:    page,
+			PerPage: 100,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, release := range repoReleases {
+			releases = append(releases, githubRelease{
+				tagName:    release.GetTagName(),
+				draft:      release.GetDraft(),
+				prerelease: release.GetPrerelease(),
+			})
+		}
+		page = response.NextPage
+		if page == 0 {
+			break
+		}
+	}
+	return tags, releases, nil
+}
+
+// IsGitHubNotFound returns true if err is a GitHub API response with status 404 Not Found.
+func IsGitHubNotFound(err error) bool {
+	var errorResponse *github.ErrorResponse
+	return errors.As(err, &errorResponse) && errorResponse.Response != nil && errorResponse.Response.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,96 @@
+package fetchclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListGithubGraphQL(t *testing.T) {
+	t.Parallel()
+	// Two pages of tags, one page of releases.
+	tagPages := map[string]string{
+		"": `{"pageInfo":{"hasNextPage":true,"endCursor":"page2"},"nodes":[
+			{"name":"v1.0.0","target":{"oid":"aaa","committedDate":"2024-01-01T00:00:00Z"}},
+			{"name":"v1.1.0","target":{"target":{"oid":"bbb","committedDate":"2024-02-01T00:00:00Z"}}},
+			{"name":"v1.2.0","target":{"oid":"ccc","committedDate":"2024-03-01T00:00:00Z"}}
+		]}`,
+		"page2": `{"pageInfo":{"hasNextPage":false,"endCursor":"end"},"nodes":[
+			{"name":"v1.3.0","target":{"oid":"ddd","committedDate":"2024-04-01T00:00:00Z"}},
+			{"name":"v2.0.0-rc.1","target":{"oid":"eee","committedDate":"2024-05-01T00:00:00Z"}}
+		]}`,
+	}
+	releases := `{"pageInfo":{"hasNextPage":false,"endCursor":"end"},"nodes":[
+		{"tagName":"v1.0.0","isDraft":false,"isPrerelease":false},
+		{"tagName":"v1.1.0","isDraft":false,"isPrerelease":false},
+		{"tagName":"v1.2.0","isDraft":false,"isPrerelease":true},
+		{"tagName":"v1.3.0","isDraft":true,"isPrerelease":false}
+	]}`
+	var numRequests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numRequests++
+		var request struct {
+			Variables struct {
+				Owner        string  `json:"owner"`
+				Name         string  `json:"name"`
+				TagsCursor   *string `json:"tagsCursor"`   //nolint:tagliatelle
+				WithTags     bool    `json:"withTags"`     //nolint:tagliatelle
+				WithReleases bool    `json:"withReleases"` //nolint:tagliatelle
+			} `json:"variables"`
+		}
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&request)) {
+			return
+		}
+		assert.Equal(t, "bufbuild", request.Variables.Owner)
+		assert.Equal(t, "protoc-gen-validate", request.Variables.Name)
+		repository := map[string]json.RawMessage{}
+		if request.Variables.WithTags {
+			var cursor string
+			if request.Variables.TagsCursor != nil {
+				cursor = *request.Variables.TagsCursor
+			}
+			repository["refs"] = json.RawMessage(tagPages[cursor])
+		}
+		if request.Variables.WithReleases {
+			repository["releases"] = json.RawMessage(releases)
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"repository": repository}}))
+	}))
+	t.Cleanup(srv.Close)
+	c := &Client{httpClient: srv.Client(), githubGraphQLURL: srv.URL}
+
+	versions, err := c.listGithub(t.Context(), "bufbuild", "protoc-gen-validate", false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, numRequests)
+	assert.Equal(t, []Version{
+		{Version: "v1.0.0", Upstream: "v1.0.0", Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), commit: "aaa"},
+		{Version: "v1.1.0", Upstream: "v1.1.0", Time: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), commit: "bbb"},
+		{Version: "v1.2.0", Upstream: "v1.2.0", Time: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), commit: "ccc"},
+		{Version: "v1.3.0", Upstream: "v1.3.0", Time: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), commit: "ddd"},
+	}, versions)
+
+	// Only tags with a published, non-prerelease release are considered.
+	numRequests = 0
+	versions, err = c.listGithub(t.Context(), "bufbuild", "protoc-gen-validate", true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, numRequests)
+	latest, err := latestVersion(versions, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0", latest)
+}
+
+func TestListGithubGraphQLErrors(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"repository":null},"errors":[{"message":"Could not resolve to a Repository"}]}`))
+	}))
+	t.Cleanup(srv.Close)
+	c := &Client{httpClient: srv.Client(), githubGraphQLURL: srv.URL}
+	_, err := c.listGithub(t.Context(), "bufbuild", "missing", false)
+	require.ErrorContains(t, err, "Could not resolve to a Repository")
+}
@@ -0,0 +1,146 @@
+package fetchclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// hostLimit configures how many requests may be in flight to a single host and
+// how frequently new requests to the host may be started.
+type hostLimit struct {
+	// maxConcurrent is the maximum number of in-flight requests to the host.
+	maxConcurrent int
+	// interval is the minimum duration between the start of consecutive requests to the host.
+	// A zero interval disables rate limiting.
+	interval time.Duration
+}
+
+var (
+	// defaultHostLimit applies to any host without an entry in hostLimits.
+	defaultHostLimit = hostLimit{maxConcurrent: 4}
+	// hostLimits overrides defaultHostLimit for specific hosts.
+	hostLimits = map[string]hostLimit{
+		// The crates.io crawling policy allows a maximum of 1 request per second.
+		// See https://crates.io/data-access#api and https://github.com/bufbuild/plugins/issues/252.
+		"crates.io": {maxConcurrent: 1, interval: time.Second},
+	}
+)
+
+// hostLimitTransport is an http.RoundTripper which limits the concurrency and
+// request rate per host. It is safe for concurrent use.
+type hostLimitTransport struct {
+	base         http.RoundTripper
+	limits       map[string]hostLimit
+	defaultLimit hostLimit
+
+	mu       sync.Mutex
+	limiters map[string]*hostLimiter
+}
+
+var _ http.RoundTripper = (*hostLimitTransport)(nil)
+
+func newHostLimitTransport(base http.RoundTripper, defaultLimit hostLimit, limits map[string]hostLimit) *hostLimitTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &hostLimitTransport{
+		base:         base,
+		limits:       limits,
+		defaultLimit: defaultLimit,
+		limiters:     make(map[string]*hostLimiter),
+	}
+}
+
+func (t *hostLimitTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	limiter := t.limiter(request.URL.Hostname())
+	if err := limiter.acquire(request.Context()); err != nil {
+		return nil, err
+	}
+	response, err := t.base.RoundTrip(request)
+	if err != nil {
+		limiter.release()
+		return nil, err
+	}
+	// Hold the slot until the caller is done reading the body.
+	response.Body = &releaseOnCloseBody{ReadCloser: response.Body, release: limiter.release}
+	return response, nil
+}
+
+func (t *hostLimitTransport) limiter(host string) *hostLimiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if limiter, ok := t.limiters[host]; ok {
+		return limiter
+	}
+	limit, ok := t.limits[host]
+	if !ok {
+		limit = t.defaultLimit
+	}
+	limiter := &hostLimiter{
+		slots:    make(chan struct{}, max(limit.maxConcurrent, 1)),
+		interval: limit.interval,
+	}
+	t.limiters[host] = limiter
+	return limiter
+}
+
+type hostLimiter struct {
+	slots    chan struct{}
+	interval time.Duration
+
+	mu sync.Mutex
+	// next is the earliest time the next request may start.
+	next time.Time
+}
+
+func (l *hostLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if l.interval <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	start := now
+	if l.next.After(now) {
+		start = l.next
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+	wait := start.Sub(now)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.release()
+		return ctx.Err()
+	}
+}
+
+func (l *hostLimiter) release() {
+	<-l.slots
+}
+
+// releaseOnCloseBody releases a host slot exactly once when the body is closed.
+type releaseOnCloseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,120 @@
+package fetchclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostLimitTransport(t *testing.T) {
+	t.Parallel()
+
+	t.Run("limits concurrent requests per host", func(t *testing.T) {
+		t.Parallel()
+		var inFlight, maxInFlight atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				previous := maxInFlight.Load()
+				if current <= previous || maxInFlight.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(srv.Close)
+
+		client := &http.Client{
+			Transport: newHostLimitTransport(srv.Client().Transport, hostLimit{maxConcurrent: 2}, nil),
+		}
+		doRequests(t, client, srv.URL, 8)
+		assert.Equal(t, int32(2), maxInFlight.Load())
+	})
+
+	t.Run("enforces interval between requests", func(t *testing.T) {
+		t.Parallel()
+		var (
+			mu     sync.Mutex
+			starts []time.Time
+		)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			mu.Lock()
+			starts = append(starts, time.Now())
+			mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(srv.Close)
+
+		const interval = 50 * time.Millisecond
+		client := &http.Client{
+			Transport: newHostLimitTransport(
+				srv.Client().Transport,
+				defaultHostLimit,
+				map[string]hostLimit{"127.0.0.1": {maxConcurrent: 4, interval: interval}},
+			),
+		}
+		doRequests(t, client, srv.URL, 4)
+		require.Len(t, starts, 4)
+		first, last := starts[0], starts[0]
+		for _, start := range starts {
+			if start.Before(first) {
+				first = start
+			}
+			if start.After(last) {
+				last = start
+			}
+		}
+		// Allow some leeway for scheduling; three intervals must separate four requests.
+		assert.GreaterOrEqual(t, last.Sub(first), 3*interval-10*time.Millisecond)
+	})
+
+	t.Run("releases slot when context is canceled while waiting", func(t *testing.T) {
+		t.Parallel()
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(srv.Close)
+
+		transport := newHostLimitTransport(srv.Client().Transport, hostLimit{maxConcurrent: 1, interval: time.Hour}, nil)
+		client := &http.Client{Transport: transport}
+		// The first request starts immediately, the second must wait an hour.
+		doRequests(t, client, srv.URL, 1)
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+		_, err = client.Do(request) //nolint:bodyclose // request is expected to fail
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Empty(t, transport.limiter("127.0.0.1").slots)
+	})
+}
+
+func doRequests(t *testing.T, client *http.Client, url string, n int) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for range n {
+		wg.Go(func() {
+			request, err := http.NewRequestWithContext(t.Context(), http.MethodGet, url, nil)
+			if !assert.NoError(t, err) {
+				return
+			}
+			response, err := client.Do(request)
+			if !assert.NoError(t, err) {
+				return
+			}
+			_, _ = io.Copy(io.Discard, response.Body)
+			assert.NoError(t, response.Body.Close())
+		})
+	}
+	wg.Wait()
+}
@@ -0,0 +1,112 @@
+package fetchclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+// maxHTTPSourceSize is the maximum size of a document fetched by an http source.
+const maxHTTPSourceSize = 10 << 20
+
+// listHTTP lists the versions extracted from the document at the config's URL.
+func (c *Client) listHTTP(ctx context.Context, config *source.HTTPConfig) ([]Version, error) {
+	if (config.JSONPath == "") == (config.Regex == "") {
+		return nil, errors.New("exactly one of json_path or regex must be set")
+	}
+	// The URL is fetched without the GitHub token, so it may be any host, but
+	// like private registry URLs it must not embed credentials.
+	if err := validateRegistryURL(config.URL); err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.genericHTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received status code %d retrieving %q", response.StatusCode, request.URL.Redacted())
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxHTTPSourceSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxHTTPSourceSize {
+		return nil, fmt.Errorf("%q is larger than %d bytes", request.URL.Redacted(), maxHTTPSourceSize)
+	}
+	var rawVersions []string
+	if config.JSONPath != "" {
+		rawVersions, err = extractJSONPathVersions(body, config.JSONPath)
+	} else {
+		rawVersions, err = extractRegexVersions(body, config.Regex)
+	}
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]Version, 0, len(rawVersions))
+	seen := make(map[string]struct{}, len(rawVersions))
+	for _, rawVersion := range rawVersions {
+		v, ok := ensureSemverPrefix(rawVersion)
+		if !ok {
+			continue
+		}
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		versions = append(versions, Version{Version: v, Upstream: rawVersion})
+	}
+	return versions, nil
+}
+
+// extractJSONPathVersions returns the string values selected by the JSONPath.
+func extractJSONPathVersions(body []byte, path string) ([]string, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	var document any
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, value := range evaluateJSONPath(steps, document) {
+		if version, ok := value.(string); ok {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+// extractRegexVersions returns the versions matched by the regular expression: the
+// group named "version", the first group, or the entire match if there are no groups.
+func extractRegexVersions(body []byte, expression string) ([]string, error) {
+	pattern, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %w", err)
+	}
+	group := 0
+	if pattern.NumSubexp() > 0 {
+		group = 1
+		if index := pattern.SubexpIndex("version"); index != -1 {
+			group = index
+		}
+	}
+	var versions []string
+	for _, match := range pattern.FindAllSubmatch(body, -1) {
+		if len(match[group]) > 0 {
+			versions = append(versions, string(match[group]))
+		}
+	}
+	return versions, nil
+}
@@ -0,0 +1,86 @@
+package fetchclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+func TestFetchHTTP(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/releases.json", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			// The GitHub token must never be sent to http sources.
+			http.Error(w, "unexpected authorization", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"releases":[{"tag":"v1.0.0"},{"tag":"v1.1.0"},{"tag":"v1.2.0-rc.1"},{"tag":"v2.0.0"},{"tag":"nightly"}]}`))
+	})
+	mux.HandleFunc("/VERSION", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("# Released versions\nrelease-1.4.0\nrelease-1.5.0\nrelease-1.6.0\n"))
+	})
+	srv := httptest.NewTLSServer(mux)
+	t.Cleanup(srv.Close)
+	c := &Client{
+		httpClient: &http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			panic("http sources must use the generic client")
+		})},
+		genericHTTPClient: srv.Client(),
+	}
+
+	tests := []struct {
+		name    string
+		source  source.Source
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "json path",
+			source: source.Source{HTTP: &source.HTTPConfig{URL: srv.URL + "/releases.json", JSONPath: "$.releases[*].tag"}},
+			want:   "v2.0.0",
+		},
+		{
+			name:   "json path with max version",
+			source: source.Source{MaxVersion: "2.0.0", HTTP: &source.HTTPConfig{URL: srv.URL + "/releases.json", JSONPath: "$.releases[*].tag"}},
+			want:   "v1.1.0",
+		},
+		{
+			name:   "regex with capture group",
+			source: source.Source{IgnoreVersions: []string{"v1.6.0"}, HTTP: &source.HTTPConfig{URL: srv.URL + "/VERSION", Regex: `release-(\d+\.\d+\.\d+)`}},
+			want:   "v1.5.0",
+		},
+		{
+			name:   "regex with named group",
+			source: source.Source{HTTP: &source.HTTPConfig{URL: srv.URL + "/VERSION", Regex: `(release)-(?P<version>\S+)`}},
+			want:   "v1.6.0",
+		},
+		{
+			name:    "no extraction",
+			source:  source.Source{HTTP: &source.HTTPConfig{URL: srv.URL + "/VERSION"}},
+			wantErr: "exactly one of json_path or regex must be set",
+		},
+		{
+			name:    "not found",
+			source:  source.Source{HTTP: &source.HTTPConfig{URL: srv.URL + "/missing", Regex: `.+`}},
+			wantErr: "received status code 404",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			version, err := c.Fetch(t.Context(), &source.Config{Source: tt.source})
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, version)
+		})
+	}
+}
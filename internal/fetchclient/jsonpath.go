@@ -0,0 +1,168 @@
+package fetchclient
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// jsonPathStep is a step of a JSONPath expression.
+type jsonPathStep struct {
+	// name is the member name selected, if not a wildcard or index step.
+	name string
+	// index is the array index selected, if isIndex is set. Negative indexes
+	// count from the end of the array.
+	index   int
+	isIndex bool
+	// wildcard selects all members or elements.
+	wildcard bool
+	// recursive selects matching descendants at any depth ("..").
+	recursive bool
+}
+
+// parseJSONPath parses the subset of JSONPath (RFC 9535) used to select versions:
+// member names (".name" or "['name']"), array indexes ("[0]", "[-1]"), wildcards
+// (".*" or "[*]"), and descendant segments ("..name").
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("invalid JSONPath %q: must start with $", path)
+	}
+	var steps []jsonPathStep
+	for rest != "" {
+		var step jsonPathStep
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			step.recursive = true
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				break
+			}
+			fallthrough
+		case strings.HasPrefix(rest, "."):
+			rest = strings.TrimPrefix(rest, ".")
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			rest = rest[end:]
+			switch name {
+			case "":
+				return nil, fmt.Errorf("invalid JSONPath %q: empty member name", path)
+			case "*":
+				step.wildcard = true
+			default:
+				if strings.IndexFunc(name, func(r rune) bool {
+					return r != '_' && r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+				}) != -1 {
+					return nil, fmt.Errorf("invalid JSONPath %q: use [%q] for member name %q", path, name, name)
+				}
+				step.name = name
+			}
+			steps = append(steps, step)
+			continue
+		}
+		if !strings.HasPrefix(rest, "[") {
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", path, rest)
+		}
+		end := strings.Index(rest, "]")
+		if end == -1 {
+			return nil, fmt.Errorf("invalid JSONPath %q: unterminated [", path)
+		}
+		selector := strings.TrimSpace(rest[1:end])
+		rest = rest[end+1:]
+		switch {
+		case selector == "*":
+			step.wildcard = true
+		case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+			step.name = selector[1 : len(selector)-1]
+		default:
+			index, err := strconv.Atoi(selector)
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSONPath %q: unsupported selector [%s]", path, selector)
+			}
+			step.index, step.isIndex = index, true
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// evaluateJSONPath returns the values selected by the steps in a decoded JSON document.
+func evaluateJSONPath(steps []jsonPathStep, document any) []any {
+	nodes := []any{document}
+	for _, step := range steps {
+		var next []any
+		for _, node := range nodes {
+			if step.recursive {
+				for _, descendant := range jsonDescendants(node) {
+					next = append(next, step.selectFrom(descendant)...)
+				}
+				continue
+			}
+			next = append(next, step.selectFrom(node)...)
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+func (s jsonPathStep) selectFrom(node any) []any {
+	switch node := node.(type) {
+	case map[string]any:
+		if s.wildcard {
+			// Members are unordered: sort by name for deterministic results.
+			names := make([]string, 0, len(node))
+			for name := range node {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			values := make([]any, 0, len(names))
+			for _, name := range names {
+				values = append(values, node[name])
+			}
+			return values
+		}
+		if value, ok := node[s.name]; ok && !s.isIndex {
+			return []any{value}
+		}
+	case []any:
+		if s.wildcard {
+			return node
+		}
+		if s.isIndex {
+			index := s.index
+			if index < 0 {
+				index += len(node)
+			}
+			if index >= 0 && index < len(node) {
+				return []any{node[index]}
+			}
+		}
+	}
+	return nil
+}
+
+// jsonDescendants returns the node and all of its descendants.
+func jsonDescendants(node any) []any {
+	descendants := []any{node}
+	switch node := node.(type) {
+	case map[string]any:
+		names := make([]string, 0, len(node))
+		for name := range node {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			descendants = append(descendants, jsonDescendants(node[name])...)
+		}
+	case []any:
+		for _, value := range node {
+			descendants = append(descendants, jsonDescendants(value)...)
+		}
+	}
+	return descendants
+}
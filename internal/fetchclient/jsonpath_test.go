@@ -0,0 +1,47 @@
+package fetchclient
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateJSONPath(t *testing.T) {
+	t.Parallel()
+	var document any
+	require.NoError(t, json.Unmarshal([]byte(`{
+  "latest": {"version": "1.2.0"},
+  "releases": [{"version": "1.0.0"}, {"version": "1.1.0"}, {"version": "1.2.0", "files": [{"version": "nested"}]}],
+  "channels": {"stable": "1.2.0", "beta": "1.3.0-beta.1"},
+  "odd key": ["x"]
+}`), &document))
+	tests := []struct {
+		path string
+		want []any
+	}{
+		{path: "$.latest.version", want: []any{"1.2.0"}},
+		{path: "$['latest'][\"version\"]", want: []any{"1.2.0"}},
+		{path: "$.releases[*].version", want: []any{"1.0.0", "1.1.0", "1.2.0"}},
+		{path: "$.releases[-1].version", want: []any{"1.2.0"}},
+		{path: "$.releases[5].version"},
+		{path: "$.channels.*", want: []any{"1.3.0-beta.1", "1.2.0"}},
+		{path: "$['odd key'][0]", want: []any{"x"}},
+		{path: "$.releases..version", want: []any{"1.0.0", "1.1.0", "1.2.0", "nested"}},
+		{path: "$.missing.version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+			steps, err := parseJSONPath(tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, evaluateJSONPath(steps, document))
+		})
+	}
+
+	for _, path := range []string{"releases", "$.", "$[", "$[?(@.version)]", "$.a b"} {
+		_, err := parseJSONPath(path)
+		assert.Error(t, err, path)
+	}
+}
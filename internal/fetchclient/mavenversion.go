@@ -0,0 +1,307 @@
+package fetchclient
+
+import (
+	"cmp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	// mavenQualifiers are the well-known Maven qualifiers in order. The empty string
+	// represents a release; unknown qualifiers sort after all well-known ones.
+	mavenQualifiers = []string{"alpha", "beta", "milestone", "rc", "snapshot", "", "sp"}
+	// mavenQualifierAliases maps alternate qualifier spellings to the well-known qualifiers.
+	mavenQualifierAliases = map[string]string{
+		"ga":      "",
+		"final":   "",
+		"release": "",
+		"cr":      "rc",
+	}
+	// mavenReleaseQualifierIndex is the comparable form of the release (empty) qualifier.
+	mavenReleaseQualifierIndex = strconv.Itoa(len(mavenQualifiers) - 2)
+	// mavenPreReleaseQualifiers are the qualifiers which denote a pre-release.
+	mavenPreReleaseQualifiers = map[string]struct{}{
+		"alpha":     {},
+		"beta":      {},
+		"milestone": {},
+		"rc":        {},
+		"snapshot":  {},
+		"preview":   {},
+		"ea":        {},
+	}
+)
+
+type mavenItemKind int
+
+const (
+	mavenItemInt mavenItemKind = iota + 1
+	mavenItemString
+	mavenItemList
+)
+
+// mavenItem is an item of a parsed Maven version, mirroring the items of Maven's
+// ComparableVersion: integers, qualifier strings, and sub-lists started by '-'
+// or by a transition between digits and letters.
+type mavenItem struct {
+	kind mavenItemKind
+	// value is the integer (without leading zeros) or the aliased qualifier.
+	value string
+	items []*mavenItem
+}
+
+// parseMavenVersion parses a Maven version following the semantics of Maven's
+// ComparableVersion. See https://maven.apache.org/pom.html#version-order-specification.
+func parseMavenVersion(version string) *mavenItem {
+	version = strings.ToLower(version)
+	root := &mavenItem{kind: mavenItemList}
+	list := root
+	stack := []*mavenItem{root}
+	pushList := func() {
+		sub := &mavenItem{kind: mavenItemList}
+		list.items = append(list.items, sub)
+		list = sub
+		stack = append(stack, sub)
+	}
+	isDigit := false
+	start := 0
+	for i, c := range version {
+		switch {
+		case c == '.':
+			if i == start {
+				list.items = append(list.items, newMavenIntItem("0"))
+			} else {
+				list.items = append(list.items, newMavenItem(isDigit, false, version[start:i]))
+			}
+			start = i + 1
+		case c == '-':
+			if i == start {
+				list.items = append(list.items, newMavenIntItem("0"))
+			} else {
+				list.items = append(list.items, newMavenItem(isDigit, false, version[start:i]))
+			}
+			start = i + 1
+			pushList()
+		case unicode.IsDigit(c):
+			if !isDigit && i > start {
+				list.items = append(list.items, newMavenItem(false, true, version[start:i]))
+				start = i
+				pushList()
+			}
+			isDigit = true
+		default:
+			if isDigit && i > start {
+				list.items = append(list.items, newMavenItem(true, false, version[start:i]))
+				start = i
+				pushList()
+			}
+			isDigit = false
+		}
+	}
+	if len(version) > start {
+		list.items = append(list.items, newMavenItem(isDigit, false, version[start:]))
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		stack[i].normalize()
+	}
+	return root
+}
+
+func newMavenItem(isDigit bool, followedByDigit bool, value string) *mavenItem {
+	if isDigit {
+		return newMavenIntItem(value)
+	}
+	if followedByDigit && len(value) == 1 {
+		switch value {
+		case "a":
+			value = "alpha"
+		case "b":
+			value = "beta"
+		case "m":
+			value = "milestone"
+		}
+	}
+	if alias, ok := mavenQualifierAliases[value]; ok {
+		value = alias
+	}
+	return &mavenItem{kind: mavenItemString, value: value}
+}
+
+func newMavenIntItem(value string) *mavenItem {
+	value = strings.TrimLeft(value, "0")
+	if value == "" {
+		value = "0"
+	}
+	return &mavenItem{kind: mavenItemInt, value: value}
+}
+
+func (m *mavenItem) isNull() bool {
+	switch m.kind {
+	case mavenItemInt:
+		return m.value == "0"
+	case mavenItemString:
+		return m.value == ""
+	default:
+		return len(m.items) == 0
+	}
+}
+
+// normalize removes trailing null items (zeros, release qualifiers, and empty lists).
+func (m *mavenItem) normalize() {
+	for i := len(m.items) - 1; i >= 0; i-- {
+		item := m.items[i]
+		if item.isNull() {
+			m.items = append(m.items[:i], m.items[i+1:]...)
+		} else if item.kind != mavenItemList {
+			break
+		}
+	}
+}
+
+// compareMavenItems compares two Maven version items, where a nil item represents
+// padding for the shorter of two lists.
+func compareMavenItems(a, b *mavenItem) int {
+	if a == nil {
+		if b == nil {
+			return 0
+		}
+		return -compareMavenItems(b, nil)
+	}
+	switch a.kind {
+	case mavenItemInt:
+		switch {
+		case b == nil:
+			if a.value == "0" {
+				return 0
+			}
+			return 1
+		case b.kind == mavenItemInt:
+			if c := cmp.Compare(len(a.value), len(b.value)); c != 0 {
+				return c
+			}
+			return strings.Compare(a.value, b.value)
+		default:
+			return 1
+		}
+	case mavenItemString:
+		switch {
+		case b == nil:
+			return strings.Compare(comparableMavenQualifier(a.value), mavenReleaseQualifierIndex)
+		case b.kind == mavenItemString:
+			return strings.Compare(comparableMavenQualifier(a.value), comparableMavenQualifier(b.value))
+		default:
+			return -1
+		}
+	default:
+		switch {
+		case b == nil:
+			if len(a.items) == 0 {
+				return 0
+			}
+			return compareMavenItems(a.items[0], nil)
+		case b.kind == mavenItemInt:
+			return -1
+		case b.kind == mavenItemString:
+			return 1
+		}
+		for i := range max(len(a.items), len(b.items)) {
+			var left, right *mavenItem
+			if i < len(a.items) {
+				left = a.items[i]
+			}
+			if i < len(b.items) {
+				right = b.items[i]
+			}
+			if c := compareMavenItems(left, right); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+}
+
+// comparableMavenQualifier returns a string which sorts qualifiers in Maven order:
+// well-known qualifiers by their index, then unknown qualifiers lexically.
+func comparableMavenQualifier(qualifier string) string {
+	for i, known := range mavenQualifiers {
+		if qualifier == known {
+			return strconv.Itoa(i)
+		}
+	}
+	return strconv.Itoa(len(mavenQualifiers)) + "-" + qualifier
+}
+
+// compareMavenVersions compares two Maven versions, returning -1, 0 or +1.
+func compareMavenVersions(a, b string) int {
+	return compareMavenItems(parseMavenVersion(a), parseMavenVersion(b))
+}
+
+// mavenToSemver maps a Maven version to the semver version used for plugin
+// version directories. The version is split into a numeric release prefix
+// (dot-separated numbers) and a qualifier (the remainder). The mapping rules are:
+//
+//   - Without a qualifier, or with a release qualifier ("Final", "GA", "RELEASE"),
+//     the release prefix is used ("1.0.0.Final" maps to "v1.0.0").
+//   - Pre-release qualifiers ("alpha", "beta", "milestone", "rc", "cr", "snapshot",
+//     "preview", "ea" and the "a1", "b1", "m1" shorthands) are not mapped.
+//   - Any other qualifier (e.g. "jre" in "33.0.0-jre") denotes a variant of the
+//     release. It is only mapped, to the release prefix, if it matches the configured
+//     qualifier (case-insensitive). This allows tracking a single variant of an artifact.
+//   - The release prefix is canonicalized to three components ("1.2" maps to "v1.2.0").
+//     Trailing zero components beyond the third are dropped; any other release with more
+//     than three components is not mapped.
+//
+// Returns false if the version cannot be mapped.
+func mavenToSemver(version string, qualifier string) (string, bool) {
+	end := 0
+	for end < len(version) && (unicode.IsDigit(rune(version[end])) || version[end] == '.') {
+		end++
+	}
+	releasePart := strings.TrimRight(version[:end], ".")
+	versionQualifier := strings.ToLower(strings.TrimLeft(version[len(releasePart):], ".-_"))
+	if releasePart == "" {
+		return "", false
+	}
+	var release []int
+	for component := range strings.SplitSeq(releasePart, ".") {
+		n, err := strconv.Atoi(component)
+		if err != nil {
+			return "", false
+		}
+		release = append(release, n)
+	}
+	if versionQualifier != "" {
+		if !strings.EqualFold(versionQualifier, qualifier) {
+			if isMavenPreRelease(versionQualifier) {
+				return "", false
+			}
+			// A release alias ("final", "ga", "release") is the only other qualifier allowed.
+			if alias, ok := mavenQualifierAliases[versionQualifier]; !ok || alias != "" {
+				return "", false
+			}
+		}
+	}
+	for len(release) < 3 {
+		release = append(release, 0)
+	}
+	return releaseToSemver(release)
+}
+
+// isMavenPreRelease reports whether the qualifier denotes a pre-release (e.g. "rc1", "beta-2", "M3").
+func isMavenPreRelease(qualifier string) bool {
+	word := strings.TrimRightFunc(qualifier, func(r rune) bool {
+		return unicode.IsDigit(r) || r == '-' || r == '.' || r == '_'
+	})
+	if len(word) == 1 && len(word) < len(qualifier) {
+		// Single letter shorthands are only pre-releases when followed by a number.
+		switch word {
+		case "a", "b", "m":
+			return true
+		}
+	}
+	if alias, ok := mavenQualifierAliases[word]; ok {
+		word = alias
+	}
+	_, ok := mavenPreReleaseQualifiers[word]
+	return ok
+}
@@ -0,0 +1,85 @@
+package fetchclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareMavenVersions(t *testing.T) {
+	t.Parallel()
+	// Ordered from lowest to highest (from Maven's ComparableVersionTest).
+	ordered := []string{
+		"1-alpha-1",
+		"1",
+		"1.1-alpha-2",
+		"1.1-beta-1",
+		"1.1-beta-2",
+		"1.1-milestone-1",
+		"1.1-rc-1",
+		"1.1-snapshot",
+		"1.1",
+		"1.1-sp",
+		"1.1-whatever",
+		"1.1.1",
+		"1.2",
+		"1.10",
+		"2.0.1",
+		"33.0.0-android",
+		"33.0.0-jre",
+		"33.1.0-android",
+	}
+	for i := range len(ordered) - 1 {
+		a, b := ordered[i], ordered[i+1]
+		assert.Equal(t, -1, compareMavenVersions(a, b), "%s < %s", a, b)
+		assert.Equal(t, 1, compareMavenVersions(b, a), "%s > %s", b, a)
+	}
+	for _, pair := range [][2]string{
+		{"1", "1.0.0"},
+		{"1.0.0.Final", "1.0.0"},
+		{"1-ga", "1"},
+		{"1-cr1", "1-rc1"},
+		{"1a1", "1-alpha-1"},
+		{"1.0.RELEASE", "1"},
+	} {
+		assert.Equal(t, 0, compareMavenVersions(pair[0], pair[1]), "%s == %s", pair[0], pair[1])
+	}
+	assert.Equal(t, -1, compareMavenVersions("1.0.0-rc1", "1.0.0"))
+	assert.Equal(t, -1, compareMavenVersions("1.9", "1.10"))
+}
+
+func TestMavenToSemver(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		version   string
+		qualifier string
+		want      string
+	}{
+		{version: "1.66.0", want: "v1.66.0"},
+		{version: "1.2", want: "v1.2.0"},
+		{version: "1.0.0.Final", want: "v1.0.0"},
+		{version: "4.1.100.Final", want: "v4.1.100"},
+		{version: "1.0.0-GA", want: "v1.0.0"},
+		{version: "2.0.0.RELEASE", want: "v2.0.0"},
+		{version: "33.0.0-jre", qualifier: "jre", want: "v33.0.0"},
+		{version: "33.0.0-jre", qualifier: "JRE", want: "v33.0.0"},
+		{version: "33.0.0-android", qualifier: "jre"},
+		{version: "33.0.0-jre"},
+		{version: "1.0.0-rc1"},
+		{version: "1.0.0-RC-1", qualifier: "jre"},
+		{version: "1.0.0-M3"},
+		{version: "1.0.0-beta2"},
+		{version: "1.0.0-SNAPSHOT"},
+		{version: "1.0.0.0", want: "v1.0.0"},
+		{version: "1.0.0.1"},
+		{version: "final"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version+"/"+tt.qualifier, func(t *testing.T) {
+			t.Parallel()
+			got, ok := mavenToSemver(tt.version, tt.qualifier)
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package fetchclient
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// listOCI lists the versions tagged in an image repository. Registries are
+// authenticated with the Docker credentials of the user (e.g. "docker login").
+func (c *Client) listOCI(ctx context.Context, image string) ([]Version, error) {
+	repository, err := name.NewRepository(image)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := remote.List(repository, c.ociOptions(ctx)...)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]Version, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		v, ok := ensureSemverPrefix(tag)
+		if !ok {
+			continue
+		}
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		versions = append(versions, Version{Version: v, Upstream: tag})
+	}
+	return versions, nil
+}
+
+// ociArtifacts returns the digest of the manifest tagged with the version.
+func (c *Client) ociArtifacts(ctx context.Context, image string, tag string) ([]Artifact, error) {
+	repository, err := name.NewRepository(image)
+	if err != nil {
+		return nil, err
+	}
+	descriptor, err := remote.Head(repository.Tag(tag), c.ociOptions(ctx)...)
+	if err != nil {
+		return nil, err
+	}
+	return []Artifact{{
+		Name:    repository.Tag(tag).String(),
+		Digests: map[string]string{descriptor.Digest.Algorithm: descriptor.Digest.Hex},
+	}}, nil
+}
+
+func (c *Client) ociOptions(ctx context.Context) []remote.Option {
+	return []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithTransport(c.genericHTTPClient.Transport),
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+	}
+}
@@ -0,0 +1,56 @@
+package fetchclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+func TestFetchOCI(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(registry.New())
+	t.Cleanup(srv.Close)
+	image := strings.TrimPrefix(srv.URL, "http://") + "/acme/protoc-gen-foo"
+	repository, err := name.NewRepository(image)
+	require.NoError(t, err)
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	for _, tag := range []string{"1.0.0", "v1.1.0", "1.2.0-alpine", "2.0.0-rc.1", "latest"} {
+		require.NoError(t, remote.Write(repository.Tag(tag), img, remote.WithContext(t.Context())))
+	}
+	c := &Client{httpClient: http.DefaultClient, genericHTTPClient: srv.Client()}
+	config := &source.Config{Source: source.Source{OCI: &source.OCIConfig{Image: image}}}
+
+	version, err := c.Fetch(t.Context(), config)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0", version)
+
+	config.Source.IgnoreVersions = []string{"v1.1.0"}
+	version, err = c.Fetch(t.Context(), config)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", version)
+
+	// The provenance records the digest of the tagged manifest.
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	provenance, err := c.Provenance(t.Context(), config, "v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, &Provenance{
+		Source:  "oci",
+		Version: "1.0.0",
+		Artifacts: []Artifact{{
+			Name:    image + ":1.0.0",
+			Digests: map[string]string{"sha256": digest.Hex},
+		}},
+	}, provenance)
+}
@@ -0,0 +1,190 @@
+package fetchclient
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// pep440Pattern is the canonical PEP 440 version pattern.
+// See https://packaging.python.org/en/latest/specifications/version-specifiers/#appendix-parsing-version-strings-with-regular-expressions.
+var pep440Pattern = regexp.MustCompile(`(?i)^v?` +
+	`(?:(?P<epoch>[0-9]+)!)?` +
+	`(?P<release>[0-9]+(?:\.[0-9]+)*)` +
+	`(?P<pre>[-_.]?(?P<pre_l>alpha|a|beta|b|preview|pre|c|rc)[-_.]?(?P<pre_n>[0-9]+)?)?` +
+	`(?P<post>(?:-(?P<post_n1>[0-9]+))|(?:[-_.]?(?P<post_l>post|rev|r)[-_.]?(?P<post_n2>[0-9]+)?))?` +
+	`(?P<dev>[-_.]?(?P<dev_l>dev)[-_.]?(?P<dev_n>[0-9]+)?)?` +
+	`(?:\+(?P<local>[a-z0-9]+(?:[-_.][a-z0-9]+)*))?$`)
+
+// pep440PreReleaseRanks orders the normalized pre-release labels.
+var pep440PreReleaseRanks = map[string]int{"a": 0, "b": 1, "rc": 2}
+
+// pep440Version is a parsed PEP 440 version.
+type pep440Version struct {
+	epoch   int
+	release []int
+	// preLabel is the normalized pre-release label ("a", "b" or "rc"), or empty if not a pre-release.
+	preLabel  string
+	preNumber int
+	// post is the post-release number, or -1 if not a post-release.
+	post int
+	// dev is the development release number, or -1 if not a development release.
+	dev   int
+	local string
+}
+
+// parsePEP440 parses a PyPI version according to PEP 440.
+func parsePEP440(version string) (*pep440Version, error) {
+	match := pep440Pattern.FindStringSubmatch(strings.TrimSpace(version))
+	if match == nil {
+		return nil, fmt.Errorf("invalid PEP 440 version: %q", version)
+	}
+	group := func(name string) string {
+		return match[pep440Pattern.SubexpIndex(name)]
+	}
+	atoi := func(s string) (int, error) {
+		if s == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid PEP 440 version %q: %w", version, err)
+		}
+		return n, nil
+	}
+	parsed := &pep440Version{post: -1, dev: -1, local: strings.ToLower(group("local"))}
+	var err error
+	if parsed.epoch, err = atoi(group("epoch")); err != nil {
+		return nil, err
+	}
+	for component := range strings.SplitSeq(group("release"), ".") {
+		n, err := atoi(component)
+		if err != nil {
+			return nil, err
+		}
+		parsed.release = append(parsed.release, n)
+	}
+	if group("pre") != "" {
+		switch label := strings.ToLower(group("pre_l")); label {
+		case "alpha", "a":
+			parsed.preLabel = "a"
+		case "beta", "b":
+			parsed.preLabel = "b"
+		default: // c, pre, preview, rc
+			parsed.preLabel = "rc"
+		}
+		if parsed.preNumber, err = atoi(group("pre_n")); err != nil {
+			return nil, err
+		}
+	}
+	if group("post") != "" {
+		if parsed.post, err = atoi(group("post_n1") + group("post_n2")); err != nil {
+			return nil, err
+		}
+	}
+	if group("dev") != "" {
+		if parsed.dev, err = atoi(group("dev_n")); err != nil {
+			return nil, err
+		}
+	}
+	return parsed, nil
+}
+
+// comparePEP440 compares two PEP 440 versions, returning -1, 0 or +1.
+// Local version labels are compared lexically after all other segments.
+func comparePEP440(a, b *pep440Version) int {
+	if c := cmp.Compare(a.epoch, b.epoch); c != 0 {
+		return c
+	}
+	length := max(len(a.release), len(b.release))
+	for i := range length {
+		if c := cmp.Compare(pep440Component(a.release, i), pep440Component(b.release, i)); c != 0 {
+			return c
+		}
+	}
+	if c := slices.Compare(a.preKey(), b.preKey()); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.post, b.post); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.devKey(), b.devKey()); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.local, b.local)
+}
+
+// preKey returns a sort key for the pre-release segment. Development releases
+// without a pre-release sort before pre-releases of the same release, and final
+// releases (including post-releases) sort after them.
+func (v *pep440Version) preKey() []int {
+	switch {
+	case v.preLabel != "":
+		return []int{1, pep440PreReleaseRanks[v.preLabel], v.preNumber}
+	case v.post == -1 && v.dev != -1:
+		return []int{0}
+	default:
+		return []int{2}
+	}
+}
+
+// devKey returns a sort key for the development release segment; non-development
+// releases sort after development releases.
+func (v *pep440Version) devKey() int {
+	if v.dev == -1 {
+		return int(^uint(0) >> 1)
+	}
+	return v.dev
+}
+
+// pep440ToSemver maps a PyPI version to the semver version used for plugin
+// version directories. The mapping rules are:
+//
+//   - Pre-releases (a, b, rc and their alternate spellings) and development releases
+//     are not mapped, consistent with semver pre-releases being ignored.
+//   - Versions with a local version label (e.g. "1.0+cpu") are not mapped.
+//   - Post-releases (e.g. "1.2.post1") are not mapped. Semver has no equivalent which
+//     sorts after the base release and is a valid Docker tag, so the base release is used.
+//   - Versions with an epoch (e.g. "2!1.0") are not mapped, as semver cannot order them
+//     above versions without one. Such plugin versions must be created by hand.
+//   - The release segment keeps up to three components ("1.0" maps to "v1.0"). Trailing
+//     zero components beyond the third are dropped ("1.2.3.0" maps to "v1.2.3"); any other
+//     release with more than three components is not mapped.
+//
+// Returns false if the version cannot be mapped.
+func pep440ToSemver(version string) (string, bool) {
+	parsed, err := parsePEP440(version)
+	if err != nil {
+		return "", false
+	}
+	if parsed.preLabel != "" || parsed.dev != -1 || parsed.local != "" || parsed.post != -1 || parsed.epoch != 0 {
+		return "", false
+	}
+	return releaseToSemver(parsed.release)
+}
+
+// releaseToSemver formats numeric release components as a semver version.
+// See pep440ToSemver for the rules regarding the number of components.
+func releaseToSemver(release []int) (string, bool) {
+	for len(release) > 3 && release[len(release)-1] == 0 {
+		release = release[:len(release)-1]
+	}
+	if len(release) == 0 || len(release) > 3 {
+		return "", false
+	}
+	components := make([]string, len(release))
+	for i, component := range release {
+		components[i] = strconv.Itoa(component)
+	}
+	return ensureSemverPrefix(strings.Join(components, "."))
+}
+
+func pep440Component(release []int, i int) int {
+	if i < len(release) {
+		return release[i]
+	}
+	return 0
+}
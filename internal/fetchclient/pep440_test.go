@@ -0,0 +1,85 @@
+package fetchclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComparePEP440(t *testing.T) {
+	t.Parallel()
+	// Ordered from lowest to highest (from the examples in PEP 440).
+	ordered := []string{
+		"1.0.dev456",
+		"1.0a1",
+		"1.0a2.dev456",
+		"1.0a12.dev456",
+		"1.0a12",
+		"1.0b1.dev456",
+		"1.0b2",
+		"1.0b2.post345.dev456",
+		"1.0b2.post345",
+		"1.0rc1.dev456",
+		"1.0rc1",
+		"1.0",
+		"1.0+abc.5",
+		"1.0.post456.dev34",
+		"1.0.post456",
+		"1.0.15",
+		"1.1.dev1",
+		"2!0.1",
+	}
+	for i := range len(ordered) - 1 {
+		a, err := parsePEP440(ordered[i])
+		require.NoError(t, err)
+		b, err := parsePEP440(ordered[i+1])
+		require.NoError(t, err)
+		assert.Equal(t, -1, comparePEP440(a, b), "%s < %s", ordered[i], ordered[i+1])
+		assert.Equal(t, 1, comparePEP440(b, a), "%s > %s", ordered[i+1], ordered[i])
+	}
+	// Alternate spellings normalize to the same version.
+	for _, pair := range [][2]string{
+		{"1.0", "1.0.0"},
+		{"1.0-1", "1.0.post1"},
+		{"1.0.alpha.1", "1.0a1"},
+		{"1.0-preview2", "1.0rc2"},
+		{"v1.0.REV3", "1.0.post3"},
+	} {
+		a, err := parsePEP440(pair[0])
+		require.NoError(t, err)
+		b, err := parsePEP440(pair[1])
+		require.NoError(t, err)
+		assert.Equal(t, 0, comparePEP440(a, b), "%s == %s", pair[0], pair[1])
+	}
+	_, err := parsePEP440("1.0-foo")
+	require.Error(t, err)
+}
+
+func TestPEP440ToSemver(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{version: "3.6.0", want: "v3.6.0"},
+		{version: "1.0", want: "v1.0"},
+		{version: "1.2.post1"},
+		{version: "2!1.0"},
+		{version: "1.2.3.0", want: "v1.2.3"},
+		{version: "01.02.03", want: "v1.2.3"},
+		{version: "1.0rc1"},
+		{version: "1.0.dev1"},
+		{version: "1.0+local"},
+		{version: "1.2.3.4"},
+		{version: "not-a-version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			t.Parallel()
+			got, ok := pep440ToSemver(tt.version)
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
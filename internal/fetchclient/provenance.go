@@ -0,0 +1,428 @@
+package fetchclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb/dirhash"
+
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+// Provenance records the upstream origin of a version: the tagged commit for
+// GitHub sources, and the checksums published by the registry for its artifacts.
+// It allows a plugin version to be audited against what upstream published.
+type Provenance struct {
+	// Source is the name of the source type (e.g. "github", "npm_registry").
+	Source string `json:"source"`
+	// Version is the version as published by the upstream source.
+	Version string `json:"version"`
+	// Commit is the SHA of the tagged commit for GitHub sources.
+	Commit string `json:"commit,omitempty"`
+	// Artifacts are the artifacts published by the registry for the version.
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+	// Verified describes the upstream signatures, attestations and checksum
+	// database entries verified before the version was created (see Client.Verify).
+	Verified []string `json:"verified,omitempty"`
+}
+
+// Artifact is a file published by a registry along with its checksums.
+type Artifact struct {
+	// Name is the artifact's file name (or module path for Go modules).
+	Name string `json:"name"`
+	// URL is where the artifact can be downloaded, if known.
+	URL string `json:"url,omitempty"`
+	// Digests maps the digest algorithm to the digest as published by the registry.
+	// Algorithms include "sha1", "sha256" and "sha512" (hex encoded), "integrity"
+	// (an npm subresource integrity string), and "h1" (a Go module hash).
+	Digests map[string]string `json:"digests"`
+}
+
+// Provenance resolves the provenance of the given semver version (as returned by Fetch)
+// from the config's source.
+func (c *Client) Provenance(ctx context.Context, config *source.Config, version string) (*Provenance, error) {
+	provenance, err := c.provenance(ctx, config, version)
+	if err != nil {
+		return nil, c.credentials.redactError(fmt.Errorf("%s: provenance of %s: %w", config.Source.Name(), version, err))
+	}
+	return provenance, nil
+}
+
+func (c *Client) provenance(ctx context.Context, config *source.Config, version string) (*Provenance, error) {
+	versions, err := c.listVersions(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	upstream, err := findUpstreamVersion(versions, version)
+	if err != nil {
+		return nil, err
+	}
+	baseURL, err := c.registryURL(config)
+	if err != nil {
+		return nil, err
+	}
+	provenance := &Provenance{
+		Source:  config.Source.Name(),
+		Version: upstream.Upstream,
+	}
+	switch {
+	case config.Source.GitHub != nil:
+		if upstream.commit == "" {
+			return nil, fmt.Errorf("no commit found for tag %s", upstream.Upstream)
+		}
+		provenance.Commit = upstream.commit
+		return provenance, nil
+	case config.Source.DartFlutter != nil:
+		provenance.Artifacts, err = c.dartFlutterArtifacts(ctx, config.Source.DartFlutter.Name, upstream.Upstream)
+	case config.Source.GoProxy != nil:
+		if config.Source.GoProxy.Registry != "" {
+			// Private modules aren't in the public checksum database.
+			provenance.Artifacts, err = c.hashGoModuleArtifacts(ctx, baseURL, config.Source.GoProxy.Name, upstream.Upstream)
+		} else {
+			provenance.Artifacts, err = c.goModuleArtifacts(ctx, baseURL, config.Source.GoProxy.Name, upstream.Upstream)
+		}
+	case config.Source.NPMRegistry != nil:
+		provenance.Artifacts, err = c.npmArtifacts(ctx, baseURL, config.Source.NPMRegistry.Name, upstream.Upstream)
+	case config.Source.Maven != nil:
+		provenance.Artifacts, err = c.mavenArtifacts(ctx, baseURL, config.Source.Maven.Group, config.Source.Maven.Name, upstream.Upstream)
+	case config.Source.Crates != nil:
+		provenance.Artifacts, err = c.crateArtifacts(ctx, config.Source.Crates.CrateName, upstream.Upstream)
+	case config.Source.PyPI != nil:
+		provenance.Artifacts, err = c.pypiArtifacts(ctx, baseURL, config.Source.PyPI.Name, upstream.Upstream)
+	case config.Source.HTTP != nil:
+		// The document only publishes versions.
+		return provenance, nil
+	case config.Source.OCI != nil:
+		provenance.Artifacts, err = c.ociArtifacts(ctx, config.Source.OCI.Image, upstream.Upstream)
+	default:
+		return nil, errors.New("failed to match a source")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(provenance.Artifacts) == 0 {
+		return nil, fmt.Errorf("no artifacts found for %s", upstream.Upstream)
+	}
+	return provenance, nil
+}
+
+// findUpstreamVersion returns the upstream version which maps to the given semver version.
+// If several upstream versions map to it (e.g. PyPI "1.2.3" and "1.2.3.0"), the latest version
+// which hasn't been withdrawn is returned.
+func findUpstreamVersion(versions []Version, version string) (Version, error) {
+	var found *Version
+	for i, candidate := range versions {
+		if candidate.Version != version || candidate.Withdrawn != "" {
+			continue
+		}
+		if found == nil || compareVersions(*found, candidate) < 0 {
+			found = &versions[i]
+		}
+	}
+	if found == nil {
+		return Version{}, fmt.Errorf("%w: %s", errNoVersions, version)
+	}
+	return *found, nil
+}
+
+func (c *Client) dartFlutterArtifacts(ctx context.Context, name string, version string) ([]Artifact, error) {
+	response, err := c.get(ctx, fmt.Sprintf("%s/%s/versions/%s", c.dartFlutterBaseURL, strings.TrimPrefix(name, "/"), version), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var data struct {
+		ArchiveURL    string `json:"archive_url"`
+		ArchiveSHA256 string `json:"archive_sha256"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if data.ArchiveSHA256 == "" {
+		return nil, nil
+	}
+	return []Artifact{{
+		Name:    artifactName(data.ArchiveURL),
+		URL:     data.ArchiveURL,
+		Digests: map[string]string{"sha256": data.ArchiveSHA256},
+	}}, nil
+}
+
+// goModuleArtifacts returns the hashes of the module zip and go.mod file recorded
+// in the Go checksum database.
+func (c *Client) goModuleArtifacts(ctx context.Context, proxyURL string, name string, version string) ([]Artifact, error) {
+	modulePath := strings.TrimPrefix(name, "/")
+	escapedPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		return nil, err
+	}
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.get(ctx, fmt.Sprintf("%s/lookup/%s@%s", c.goSumDBBaseURL, escapedPath, escapedVersion), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	// The response is the record ID followed by go.sum lines, a blank line, and
+	// the signed tree head. See https://go.dev/design/25530-sumdb#checksum-database.
+	var artifacts []Artifact
+	for line := range strings.Lines(string(body)) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			break
+		}
+		if len(fields) != 3 || fields[0] != modulePath {
+			continue
+		}
+		hash, ok := strings.CutPrefix(fields[2], "h1:")
+		if !ok {
+			continue
+		}
+		artifactVersion := fields[1]
+		var artifactURL string
+		switch artifactVersion {
+		case version:
+			artifactURL = fmt.Sprintf("%s/%s/@v/%s.zip", proxyURL, escapedPath, escapedVersion)
+		case version + "/go.mod":
+			artifactURL = fmt.Sprintf("%s/%s/@v/%s.mod", proxyURL, escapedPath, escapedVersion)
+		default:
+			continue
+		}
+		artifacts = append(artifacts, Artifact{
+			Name:    modulePath + "@" + artifactVersion,
+			URL:     artifactURL,
+			Digests: map[string]string{"h1": hash},
+		})
+	}
+	return artifacts, nil
+}
+
+// hashGoModuleArtifacts downloads the module zip and go.mod file from the proxy and
+// computes their hashes, as recorded in go.sum files.
+func (c *Client) hashGoModuleArtifacts(ctx context.Context, proxyURL string, name string, version string) (_ []Artifact, retErr error) {
+	modulePath := strings.TrimPrefix(name, "/")
+	escapedPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		return nil, err
+	}
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	modURL := fmt.Sprintf("%s/%s/@v/%s.mod", proxyURL, escapedPath, escapedVersion)
+	modContent, err := c.download(ctx, modURL)
+	if err != nil {
+		return nil, err
+	}
+	modHash, err := dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(modContent)), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	zipURL := fmt.Sprintf("%s/%s/@v/%s.zip", proxyURL, escapedPath, escapedVersion)
+	zipContent, err := c.download(ctx, zipURL)
+	if err != nil {
+		return nil, err
+	}
+	// dirhash.HashZip requires a file.
+	zipFile, err := os.CreateTemp("", "fetchclient-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		retErr = errors.Join(retErr, os.Remove(zipFile.Name()))
+	}()
+	_, err = zipFile.Write(zipContent)
+	if closeErr := zipFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	zipHash, err := dirhash.HashZip(zipFile.Name(), dirhash.Hash1)
+	if err != nil {
+		return nil, err
+	}
+	return []Artifact{
+		{
+			Name:    modulePath + "@" + version,
+			URL:     zipURL,
+			Digests: map[string]string{"h1": strings.TrimPrefix(zipHash, "h1:")},
+		},
+		{
+			Name:    modulePath + "@" + version + "/go.mod",
+			URL:     modURL,
+			Digests: map[string]string{"h1": strings.TrimPrefix(modHash, "h1:")},
+		},
+	}, nil
+}
+
+// download returns the content at the target URL.
+func (c *Client) download(ctx context.Context, targetURL string) ([]byte, error) {
+	response, err := c.get(ctx, targetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	return io.ReadAll(response.Body)
+}
+
+func (c *Client) npmArtifacts(ctx context.Context, registryURL string, name string, version string) ([]Artifact, error) {
+	response, err := c.get(ctx, fmt.Sprintf("%s/%s/%s", registryURL, strings.TrimPrefix(name, "/"), version), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var data struct {
+		Dist struct {
+			Tarball   string `json:"tarball"`
+			Shasum    string `json:"shasum"`
+			Integrity string `json:"integrity"`
+		} `json:"dist"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	digests := make(map[string]string)
+	if data.Dist.Integrity != "" {
+		digests["integrity"] = data.Dist.Integrity
+	}
+	if data.Dist.Shasum != "" {
+		digests["sha1"] = data.Dist.Shasum
+	}
+	if len(digests) == 0 {
+		return nil, nil
+	}
+	return []Artifact{{
+		Name:    artifactName(data.Dist.Tarball),
+		URL:     data.Dist.Tarball,
+		Digests: digests,
+	}}, nil
+}
+
+// mavenArtifacts returns the checksums of the POM and, if published, the JAR. Maven
+// repositories publish checksums alongside each file; SHA-256 checksums are only
+// available for some artifacts.
+func (c *Client) mavenArtifacts(ctx context.Context, repositoryURL string, group string, name string, version string) ([]Artifact, error) {
+	versionDir := append(strings.Split(group, "."), name, version)
+	var artifacts []Artifact
+	for _, extension := range []string{"pom", "jar"} {
+		filename := fmt.Sprintf("%s-%s.%s", name, version, extension)
+		artifactURL, err := url.JoinPath(repositoryURL, append(versionDir, filename)...)
+		if err != nil {
+			return nil, err
+		}
+		digests := make(map[string]string)
+		for _, algorithm := range []string{"sha1", "sha256"} {
+			checksum, err := c.mavenChecksum(ctx, artifactURL+"."+algorithm)
+			if err != nil {
+				if errors.Is(err, errNotFound) {
+					continue
+				}
+				return nil, err
+			}
+			digests[algorithm] = checksum
+		}
+		if len(digests) == 0 {
+			continue
+		}
+		artifacts = append(artifacts, Artifact{Name: filename, URL: artifactURL, Digests: digests})
+	}
+	return artifacts, nil
+}
+
+// mavenChecksum returns the checksum in a Maven checksum file. Checksum files
+// contain the hex encoded checksum, optionally followed by the file name.
+func (c *Client) mavenChecksum(ctx context.Context, checksumURL string) (string, error) {
+	response, err := c.get(ctx, checksumURL, nil)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file %q", checksumURL)
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+func (c *Client) crateArtifacts(ctx context.Context, name string, version string) ([]Artifact, error) {
+	header := http.Header{"User-Agent": []string{"bufbuild (github.com/bufbuild/plugins)"}}
+	crateName := strings.TrimPrefix(name, "/")
+	response, err := c.get(ctx, fmt.Sprintf("%s/crates/%s/%s", c.cratesBaseURL, crateName, version), header)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var data struct {
+		Version struct {
+			Checksum string `json:"checksum"`
+		} `json:"version"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if data.Version.Checksum == "" {
+		return nil, nil
+	}
+	return []Artifact{{
+		Name:    fmt.Sprintf("%s-%s.crate", crateName, version),
+		URL:     fmt.Sprintf("%s/crates/%s/%s/download", c.cratesBaseURL, crateName, version),
+		Digests: map[string]string{"sha256": data.Version.Checksum},
+	}}, nil
+}
+
+func (c *Client) pypiArtifacts(ctx context.Context, indexURL string, name string, version string) ([]Artifact, error) {
+	header := http.Header{"Accept": []string{"application/vnd.pypi.simple.v1+json"}}
+	response, err := c.get(ctx, fmt.Sprintf("%s/%s/", indexURL, strings.TrimPrefix(name, "/")), header)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var data struct {
+		Files []struct {
+			Filename string            `json:"filename"`
+			URL      string            `json:"url"`
+			Hashes   map[string]string `json:"hashes"`
+		} `json:"files"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	var artifacts []Artifact
+	for _, file := range data.Files {
+		if pypiFileVersion(file.Filename) != version || len(file.Hashes) == 0 {
+			continue
+		}
+		artifacts = append(artifacts, Artifact{Name: file.Filename, URL: file.URL, Digests: file.Hashes})
+	}
+	return artifacts, nil
+}
+
+// artifactName returns the last path element of an artifact URL.
+func artifactName(artifactURL string) string {
+	if parsed, err := url.Parse(artifactURL); err == nil && parsed.Path != "" {
+		artifactURL = parsed.Path
+	}
+	return artifactURL[strings.LastIndex(artifactURL, "/")+1:]
+}
@@ -0,0 +1,167 @@
+package fetchclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+func TestProvenance(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/crates/prost", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"versions":[{"num":"0.14.1"}]}`))
+	})
+	mux.HandleFunc("/crates/prost/0.14.1", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"version":{"num":"0.14.1","checksum":"abc123"}}`))
+	})
+	mux.HandleFunc("/dart/protoc_plugin", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"versions":[{"version":"21.1.0"}]}`))
+	})
+	mux.HandleFunc("/dart/protoc_plugin/versions/21.1.0", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"archive_url":"https://pub.dev/packages/protoc_plugin/versions/21.1.0.tar.gz","archive_sha256":"def456"}`))
+	})
+	mux.HandleFunc("/npm/@bufbuild/protoc-gen-es", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"versions":{"2.2.0":{}}}`))
+	})
+	mux.HandleFunc("/npm/@bufbuild/protoc-gen-es/2.2.0", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"dist":{"tarball":"https://registry.npmjs.org/@bufbuild/protoc-gen-es/-/protoc-gen-es-2.2.0.tgz","shasum":"0a1b","integrity":"sha512-xyz=="}}`))
+	})
+	mux.HandleFunc("/pypi/mypy-protobuf/", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{
+  "versions": ["3.5.0", "3.6.0", "3.6.0.post1"],
+  "files": [
+    {"filename": "mypy-protobuf-3.5.0.tar.gz", "url": "https://files/3.5.0.tar.gz", "hashes": {"sha256": "old"}},
+    {"filename": "mypy-protobuf-3.6.0.tar.gz", "url": "https://files/3.6.0.tar.gz", "hashes": {"sha256": "base"}},
+    {"filename": "mypy_protobuf-3.6.0.post1-py3-none-any.whl", "url": "https://files/3.6.0.post1.whl", "hashes": {"sha256": "post"}}
+  ]
+}`))
+	})
+	mux.HandleFunc("/go/example.com/mod/@latest", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"Version":"v1.0.0"}`))
+	})
+	mux.HandleFunc("/go/example.com/mod/@v/list", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("v1.0.0\n"))
+	})
+	mux.HandleFunc("/go/example.com/mod/@v/v1.0.0.mod", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("module example.com/mod\n"))
+	})
+	mux.HandleFunc("/sumdb/lookup/example.com/mod@v1.0.0", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("1234\nexample.com/mod v1.0.0 h1:zip=\nexample.com/mod v1.0.0/go.mod h1:mod=\n\ngo.sum database tree\n5678\nroot=\n"))
+	})
+	mux.HandleFunc("/maven/io/grpc/protoc-gen-grpc-java/maven-metadata.xml", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`<metadata><versioning><versions><version>1.66.0</version></versions></versioning></metadata>`))
+	})
+	mux.HandleFunc("/maven/io/grpc/protoc-gen-grpc-java/1.66.0/protoc-gen-grpc-java-1.66.0.pom.sha1", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("AA11  protoc-gen-grpc-java-1.66.0.pom\n"))
+	})
+	mux.HandleFunc("/maven/io/grpc/protoc-gen-grpc-java/1.66.0/protoc-gen-grpc-java-1.66.0.pom.sha256", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("bb22\n"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	c := &Client{
+		httpClient:         srv.Client(),
+		cratesBaseURL:      srv.URL,
+		dartFlutterBaseURL: srv.URL + "/dart",
+		goProxyBaseURL:     srv.URL + "/go",
+		goSumDBBaseURL:     srv.URL + "/sumdb",
+		mavenBaseURL:       srv.URL + "/maven",
+		npmBaseURL:         srv.URL + "/npm",
+		pypiBaseURL:        srv.URL + "/pypi",
+	}
+
+	tests := []struct {
+		name    string
+		source  source.Source
+		version string
+		want    *Provenance
+	}{
+		{
+			name:    "crates",
+			source:  source.Source{Crates: &source.CratesConfig{CrateName: "prost"}},
+			version: "v0.14.1",
+			want: &Provenance{Source: "crates", Version: "0.14.1", Artifacts: []Artifact{{
+				Name:    "prost-0.14.1.crate",
+				URL:     srv.URL + "/crates/prost/0.14.1/download",
+				Digests: map[string]string{"sha256": "abc123"},
+			}}},
+		},
+		{
+			name:    "dart",
+			source:  source.Source{DartFlutter: &source.DartFlutterConfig{Name: "protoc_plugin"}},
+			version: "v21.1.0",
+			want: &Provenance{Source: "dart_flutter", Version: "21.1.0", Artifacts: []Artifact{{
+				Name:    "21.1.0.tar.gz",
+				URL:     "https://pub.dev/packages/protoc_plugin/versions/21.1.0.tar.gz",
+				Digests: map[string]string{"sha256": "def456"},
+			}}},
+		},
+		{
+			name:    "npm",
+			source:  source.Source{NPMRegistry: &source.NPMRegistryConfig{Name: "@bufbuild/protoc-gen-es"}},
+			version: "v2.2.0",
+			want: &Provenance{Source: "npm_registry", Version: "2.2.0", Artifacts: []Artifact{{
+				Name:    "protoc-gen-es-2.2.0.tgz",
+				URL:     "https://registry.npmjs.org/@bufbuild/protoc-gen-es/-/protoc-gen-es-2.2.0.tgz",
+				Digests: map[string]string{"integrity": "sha512-xyz==", "sha1": "0a1b"},
+			}}},
+		},
+		{
+			name:    "pypi ignores post-releases",
+			source:  source.Source{PyPI: &source.PyPIConfig{Name: "mypy-protobuf"}},
+			version: "v3.6.0",
+			want: &Provenance{Source: "pypi", Version: "3.6.0", Artifacts: []Artifact{{
+				Name:    "mypy-protobuf-3.6.0.tar.gz",
+				URL:     "https://files/3.6.0.tar.gz",
+				Digests: map[string]string{"sha256": "base"},
+			}}},
+		},
+		{
+			name:    "go",
+			source:  source.Source{GoProxy: &source.GoProxyConfig{Name: "example.com/mod"}},
+			version: "v1.0.0",
+			want: &Provenance{Source: "go_proxy", Version: "v1.0.0", Artifacts: []Artifact{
+				{
+					Name:    "example.com/mod@v1.0.0",
+					URL:     srv.URL + "/go/example.com/mod/@v/v1.0.0.zip",
+					Digests: map[string]string{"h1": "zip="},
+				},
+				{
+					Name:    "example.com/mod@v1.0.0/go.mod",
+					URL:     srv.URL + "/go/example.com/mod/@v/v1.0.0.mod",
+					Digests: map[string]string{"h1": "mod="},
+				},
+			}},
+		},
+		{
+			name:    "maven skips missing jar",
+			source:  source.Source{Maven: &source.MavenConfig{Group: "io.grpc", Name: "protoc-gen-grpc-java"}},
+			version: "v1.66.0",
+			want: &Provenance{Source: "maven", Version: "1.66.0", Artifacts: []Artifact{{
+				Name:    "protoc-gen-grpc-java-1.66.0.pom",
+				URL:     srv.URL + "/maven/io/grpc/protoc-gen-grpc-java/1.66.0/protoc-gen-grpc-java-1.66.0.pom",
+				Digests: map[string]string{"sha1": "aa11", "sha256": "bb22"},
+			}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := c.Provenance(t.Context(), &source.Config{Source: tt.source}, tt.version)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("unknown version", func(t *testing.T) {
+		t.Parallel()
+		_, err := c.Provenance(t.Context(), &source.Config{Source: source.Source{Crates: &source.CratesConfig{CrateName: "prost"}}}, "v9.9.9")
+		require.ErrorIs(t, err, errNoVersions)
+	})
+}
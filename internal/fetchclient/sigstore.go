@@ -0,0 +1,112 @@
+package fetchclient
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/fulcio/certificate"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/verify"
+)
+
+const (
+	// slsaProvenancePrefix prefixes the predicate types of all SLSA provenance versions.
+	slsaProvenancePrefix = "https://slsa.dev/provenance/"
+	// githubActionsIssuer is the OIDC issuer of the GitHub Actions workflows signing
+	// npm provenance and GitHub artifact attestations.
+	githubActionsIssuer = "https://token.actions.githubusercontent.com"
+)
+
+// githubWorkflowSANPattern matches the subject alternative name of certificates issued
+// to GitHub Actions workflows: the URI of the workflow file at its ref. Reusable workflows
+// may live in another repository, so the repository is pinned with the source repository
+// certificate extension instead.
+var githubWorkflowSANPattern = regexp.MustCompile(`^https://github\.com/`)
+
+// sigstoreIdentity is the verified identity of a Sigstore bundle's signer.
+type sigstoreIdentity struct {
+	// SourceRepositoryURI is the repository the signing workflow ran for.
+	SourceRepositoryURI string
+	// PredicateType is the in-toto predicate type of the attestation.
+	PredicateType string
+}
+
+// parseSigstoreTrustedRoot parses a Sigstore trusted_root.json file.
+// See https://github.com/sigstore/protobuf-specs/blob/main/protos/sigstore_trustroot.proto.
+func parseSigstoreTrustedRoot(content []byte) (root.TrustedMaterial, error) {
+	trustedRoot, err := root.NewTrustedRootFromJSON(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trusted root: %w", err)
+	}
+	return trustedRoot, nil
+}
+
+// verifySigstoreBundle verifies that the bundle is an SLSA provenance attestation for
+// a subject with the given digest (algorithm and hex encoded value), signed by a GitHub
+// Actions workflow of the source repository (e.g. "https://github.com/owner/repo").
+//
+// The signing certificate must be issued by the trusted root and valid when the signature
+// was recorded in a trusted transparency log. The log entry must be for the envelope's
+// signature and certificate, and its signed entry timestamp and (for bundles since v0.2)
+// inclusion proof must verify.
+func verifySigstoreBundle(
+	trustedMaterial root.TrustedMaterial,
+	content []byte,
+	digestAlgorithm string,
+	digest string,
+	sourceRepositoryURI string,
+) (*sigstoreIdentity, error) {
+	var signedBundle bundle.Bundle
+	if err := signedBundle.UnmarshalJSON(content); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle: %w", err)
+	}
+	if _, err := signedBundle.Envelope(); err != nil {
+		return nil, errors.New("bundle has no signed envelope")
+	}
+	digestBytes, err := hex.DecodeString(digest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest %q: %w", digest, err)
+	}
+	verifier, err := verify.NewVerifier(
+		trustedMaterial,
+		verify.WithTransparencyLog(1),
+		verify.WithObserverTimestamps(1),
+	)
+	if err != nil {
+		return nil, err
+	}
+	sanMatcher, err := verify.NewSANMatcher("", githubWorkflowSANPattern.String())
+	if err != nil {
+		return nil, err
+	}
+	issuerMatcher, err := verify.NewIssuerMatcher(githubActionsIssuer, "")
+	if err != nil {
+		return nil, err
+	}
+	identity, err := verify.NewCertificateIdentity(
+		sanMatcher,
+		issuerMatcher,
+		certificate.Extensions{SourceRepositoryURI: sourceRepositoryURI},
+	)
+	if err != nil {
+		return nil, err
+	}
+	result, err := verifier.Verify(&signedBundle, verify.NewPolicy(
+		verify.WithArtifactDigest(digestAlgorithm, digestBytes),
+		verify.WithCertificateIdentity(identity),
+	))
+	if err != nil {
+		return nil, err
+	}
+	if result.Statement == nil || !strings.HasPrefix(result.Statement.GetPredicateType(), slsaProvenancePrefix) {
+		return nil, fmt.Errorf("unexpected predicate type %q", result.Statement.GetPredicateType())
+	}
+	return &sigstoreIdentity{
+		SourceRepositoryURI: result.Signature.Certificate.SourceRepositoryURI,
+		PredicateType:       result.Statement.GetPredicateType(),
+	}, nil
+}
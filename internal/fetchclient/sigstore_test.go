@@ -0,0 +1,322 @@
+package fetchclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protodsse "github.com/sigstore/protobuf-specs/gen/pb-go/dsse"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/fulcio/certificate"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/testing/ca"
+	"github.com/sigstore/sigstore-go/pkg/tlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySigstoreBundle(t *testing.T) {
+	t.Parallel()
+	signer := newTestSigstore(t)
+	digest := hex.EncodeToString(make([]byte, 32))
+	const repository = "https://github.com/acme/plugin"
+
+	identity, err := verifySigstoreBundle(signer.root, signer.bundle(t, testBundleOptions{digest: digest}), "sha256", digest, repository)
+	require.NoError(t, err)
+	assert.Equal(t, &sigstoreIdentity{
+		SourceRepositoryURI: repository,
+		PredicateType:       "https://slsa.dev/provenance/v1",
+	}, identity)
+
+	tests := []struct {
+		name       string
+		options    testBundleOptions
+		digest     string
+		repository string
+		wantErr    string
+	}{
+		{
+			name:    "digest mismatch",
+			options: testBundleOptions{digest: digest},
+			digest:  hex.EncodeToString(make([]byte, 31)),
+			wantErr: "provided artifact digest does not match",
+		},
+		{
+			name:       "other repository",
+			options:    testBundleOptions{digest: digest, sourceRepository: "https://github.com/evil/plugin"},
+			digest:     digest,
+			repository: repository,
+			wantErr:    `expected SourceRepositoryURI to be "https://github.com/acme/plugin", got "https://github.com/evil/plugin"`,
+		},
+		{
+			name:    "other issuer",
+			options: testBundleOptions{digest: digest, issuer: "https://accounts.example.com"},
+			digest:  digest,
+			wantErr: "issuer",
+		},
+		{
+			name:    "tampered payload",
+			options: testBundleOptions{digest: digest, tamperPayload: true},
+			digest:  digest,
+			wantErr: "failed to verify signature",
+		},
+		{
+			name:    "not provenance",
+			options: testBundleOptions{digest: digest, predicateType: "https://spdx.dev/Document/v2.3"},
+			digest:  digest,
+			wantErr: "unexpected predicate type",
+		},
+		{
+			name:    "untrusted certificate authority",
+			options: testBundleOptions{digest: digest, untrustedCA: true},
+			digest:  digest,
+			wantErr: "leaf certificate verification failed",
+		},
+		{
+			name:    "certificate expired when logged",
+			options: testBundleOptions{digest: digest, loggedAfterExpiry: true},
+			digest:  digest,
+			wantErr: "integrated time outside certificate validity",
+		},
+		{
+			name:    "invalid signed entry timestamp",
+			options: testBundleOptions{digest: digest, tamperTimestamp: true},
+			digest:  digest,
+			wantErr: "not enough verified log entries",
+		},
+		{
+			name:    "invalid inclusion proof",
+			options: testBundleOptions{digest: digest, tamperInclusionProof: true},
+			digest:  digest,
+			wantErr: "inclusion",
+		},
+		{
+			name:    "log entry for another signature",
+			options: testBundleOptions{digest: digest, otherLogEntry: true},
+			digest:  digest,
+			wantErr: "transparency log signature does not match",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if tt.repository == "" {
+				tt.repository = repository
+			}
+			_, err := verifySigstoreBundle(signer.root, signer.bundle(t, tt.options), "sha256", tt.digest, tt.repository)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+// testSigstore issues Sigstore bundles from a test certificate authority and a
+// virtual transparency log, which make up its trusted root.
+type testSigstore struct {
+	root  root.TrustedMaterial
+	caKey *ecdsa.PrivateKey
+	ca    *x509.Certificate
+	rekor *ca.VirtualSigstore
+}
+
+type testBundleOptions struct {
+	digest               string
+	predicateType        string
+	sourceRepository     string
+	issuer               string
+	tamperPayload        bool
+	untrustedCA          bool
+	loggedAfterExpiry    bool
+	tamperTimestamp      bool
+	tamperInclusionProof bool
+	otherLogEntry        bool
+}
+
+func newTestSigstore(t *testing.T) *testSigstore {
+	t.Helper()
+	caKey, certificateAuthority := newTestCertificateAuthority(t)
+	rekor, err := ca.NewVirtualSigstore()
+	require.NoError(t, err)
+	rekorLogs := rekor.RekorLogs()
+	for _, rekorLog := range rekorLogs {
+		// Bundles are logged at a fixed time.
+		rekorLog.ValidityPeriodStart = certificateAuthority.NotBefore
+		rekorLog.ValidityPeriodEnd = time.Time{}
+	}
+	trustedRoot, err := root.NewTrustedRoot(
+		root.TrustedRootMediaType01,
+		[]root.CertificateAuthority{&root.FulcioCertificateAuthority{
+			Root:                certificateAuthority,
+			ValidityPeriodStart: certificateAuthority.NotBefore,
+			ValidityPeriodEnd:   certificateAuthority.NotAfter,
+		}},
+		nil,
+		nil,
+		rekorLogs,
+	)
+	require.NoError(t, err)
+	return &testSigstore{root: trustedRoot, caKey: caKey, ca: certificateAuthority, rekor: rekor}
+}
+
+func newTestCertificateAuthority(t *testing.T) (*ecdsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test fulcio"},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(raw)
+	require.NoError(t, err)
+	return key, certificate
+}
+
+// bundle returns a Sigstore bundle attesting an in-toto statement about a subject
+// with the given digest, for both its sha256 and sha512 algorithms, signed by a
+// GitHub Actions workflow of the source repository.
+func (s *testSigstore) bundle(t *testing.T, options testBundleOptions) []byte {
+	t.Helper()
+	if options.predicateType == "" {
+		options.predicateType = "https://slsa.dev/provenance/v1"
+	}
+	if options.sourceRepository == "" {
+		options.sourceRepository = "https://github.com/acme/plugin"
+	}
+	if options.issuer == "" {
+		options.issuer = githubActionsIssuer
+	}
+	caKey, certificateAuthority := s.caKey, s.ca
+	if options.untrustedCA {
+		caKey, certificateAuthority = newTestCertificateAuthority(t)
+	}
+	signedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	workflowURI, err := url.Parse(options.sourceRepository + "/.github/workflows/release.yml@refs/tags/v1.0.0")
+	require.NoError(t, err)
+	issuer, err := asn1.MarshalWithParams(options.issuer, "utf8")
+	require.NoError(t, err)
+	sourceRepository, err := asn1.MarshalWithParams(options.sourceRepository, "utf8")
+	require.NoError(t, err)
+	leafRaw, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    signedAt.Add(-time.Minute),
+		NotAfter:     signedAt.Add(10 * time.Minute),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		URIs:         []*url.URL{workflowURI},
+		ExtraExtensions: []pkix.Extension{
+			{Id: certificate.OIDIssuerV2, Value: issuer},
+			{Id: certificate.OIDSourceRepositoryURI, Value: sourceRepository},
+		},
+	}, certificateAuthority, &leafKey.PublicKey, caKey)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(leafRaw)
+	require.NoError(t, err)
+
+	payload := testStatement(t, options.digest, options.predicateType)
+	envelope := testEnvelope(t, leafKey, payload)
+	loggedEnvelope := envelope
+	if options.otherLogEntry {
+		loggedEnvelope = testEnvelope(t, leafKey, testStatement(t, options.digest, "https://slsa.dev/provenance/v0.2"))
+	}
+	if options.tamperPayload {
+		payload = append(payload, ' ')
+	}
+
+	integratedTime := signedAt
+	if options.loggedAfterExpiry {
+		integratedTime = signedAt.Add(time.Hour)
+	}
+	loggedSignature, err := base64.StdEncoding.DecodeString(loggedEnvelope.Signatures[0].Sig)
+	require.NoError(t, err)
+	entry, err := s.rekor.GenerateTlogEntry(leaf, loggedEnvelope, loggedSignature, integratedTime.Unix(), true)
+	require.NoError(t, err)
+	logEntry := entry.TransparencyLogEntry()
+	logEntry.KindVersion = &protorekor.KindVersion{Kind: "intoto", Version: "0.0.2"}
+	signedEntryTimestamp, err := s.rekor.RekorSignPayload(tlog.RekorPayload{
+		Body:           base64.StdEncoding.EncodeToString(logEntry.GetCanonicalizedBody()),
+		IntegratedTime: logEntry.GetIntegratedTime(),
+		LogIndex:       logEntry.GetLogIndex(),
+		LogID:          hex.EncodeToString(logEntry.GetLogId().GetKeyId()),
+	})
+	require.NoError(t, err)
+	logEntry.InclusionPromise = &protorekor.InclusionPromise{SignedEntryTimestamp: signedEntryTimestamp}
+	if options.tamperTimestamp {
+		logEntry.IntegratedTime--
+	}
+	if options.tamperInclusionProof {
+		logEntry.InclusionProof.RootHash = make([]byte, len(logEntry.GetInclusionProof().GetRootHash()))
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(envelope.Signatures[0].Sig)
+	require.NoError(t, err)
+	signedBundle, err := bundle.NewBundle(&protobundle.Bundle{
+		MediaType: "application/vnd.dev.sigstore.bundle.v0.3+json",
+		VerificationMaterial: &protobundle.VerificationMaterial{
+			Content:     &protobundle.VerificationMaterial_Certificate{Certificate: &protocommon.X509Certificate{RawBytes: leafRaw}},
+			TlogEntries: []*protorekor.TransparencyLogEntry{logEntry},
+		},
+		Content: &protobundle.Bundle_DsseEnvelope{DsseEnvelope: &protodsse.Envelope{
+			Payload:     payload,
+			PayloadType: envelope.PayloadType,
+			Signatures:  []*protodsse.Signature{{Sig: signature}},
+		}},
+	})
+	require.NoError(t, err)
+	content, err := signedBundle.MarshalJSON()
+	require.NoError(t, err)
+	return content
+}
+
+// testStatement returns an in-toto statement about a subject with the digest.
+func testStatement(t *testing.T, digest string, predicateType string) []byte {
+	t.Helper()
+	statement, err := json.Marshal(map[string]any{
+		"_type": "https://in-toto.io/Statement/v1",
+		"subject": []any{map[string]any{
+			"name":   "artifact",
+			"digest": map[string]string{"sha256": digest, "sha512": digest},
+		}},
+		"predicateType": predicateType,
+		"predicate":     map[string]any{},
+	})
+	require.NoError(t, err)
+	return statement
+}
+
+// testEnvelope returns a DSSE envelope of the in-toto statement signed with the key.
+func testEnvelope(t *testing.T, key *ecdsa.PrivateKey, payload []byte) *dsse.Envelope {
+	t.Helper()
+	const payloadType = "application/vnd.in-toto+json"
+	digest := sha256.Sum256([]byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload)))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	return &dsse.Envelope{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []dsse.Signature{{Sig: base64.StdEncoding.EncodeToString(signature)}},
+	}
+}
@@ -0,0 +1,354 @@
+package fetchclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"golang.org/x/mod/sumdb"
+
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+// goSumDBKey is the verifier key of the Go checksum database.
+// See https://go.dev/ref/mod#checksum-database.
+const goSumDBKey = "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ko0ZlnWcKx7eqbn"
+
+// errNoProvenancePublished is returned when the upstream source doesn't publish any
+// provenance which can be verified for a version.
+var errNoProvenancePublished = errors.New("no verifiable provenance is published")
+
+// Verify verifies the upstream provenance of the given semver version (as returned by
+// Fetch) at the verification level of the config's source:
+//
+//   - npm packages: the SLSA provenance attestation published to the registry.
+//   - Go modules: the module and go.mod hashes are included in the Go checksum database.
+//   - Maven artifacts: the PGP signatures published alongside the POM and JAR,
+//     against the pinned keyring (see WithMavenKeyring).
+//   - GitHub sources: the artifact attestations of the release assets.
+//
+// Attestations are verified against the Sigstore trusted root (see WithSigstoreTrustedRoot).
+// It returns a description of each verified item, or an error if the version must
+// not be adopted. Other sources don't publish verifiable provenance.
+func (c *Client) Verify(ctx context.Context, config *source.Config, version string) ([]string, error) {
+	level := config.Source.Verification
+	if level == "" || level == source.VerificationNone {
+		return nil, nil
+	}
+	verified, err := c.verify(ctx, config, version)
+	if errors.Is(err, errNoProvenancePublished) && level == source.VerificationIfAvailable {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, c.credentials.redactError(fmt.Errorf("%s: verification of %s: %w", config.Source.Name(), version, err))
+	}
+	return verified, nil
+}
+
+func (c *Client) verify(ctx context.Context, config *source.Config, version string) ([]string, error) {
+	if config.Source.GitHub == nil && config.Source.GoProxy == nil && config.Source.NPMRegistry == nil && config.Source.Maven == nil {
+		return nil, fmt.Errorf("%w for %s sources", errNoProvenancePublished, config.Source.Name())
+	}
+	versions, err := c.listVersions(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	upstream, err := findUpstreamVersion(versions, version)
+	if err != nil {
+		return nil, err
+	}
+	baseURL, err := c.registryURL(config)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case config.Source.GitHub != nil:
+		return c.verifyGitHubAttestations(ctx, config.Source.GitHub.Owner, config.Source.GitHub.Repository, upstream.Upstream)
+	case config.Source.GoProxy != nil:
+		if config.Source.GoProxy.Registry != "" {
+			return nil, fmt.Errorf("%w: private modules are not in the Go checksum database", errNoProvenancePublished)
+		}
+		return c.verifyGoSumDB(ctx, baseURL, config.Source.GoProxy.Name, upstream.Upstream)
+	case config.Source.NPMRegistry != nil:
+		if config.Source.NPMRegistry.Repository == "" {
+			return nil, errors.New("npm_registry.repository must be set to verify provenance attestations")
+		}
+		return c.verifyNPMAttestations(ctx, baseURL, config.Source.NPMRegistry.Name, upstream.Upstream, config.Source.NPMRegistry.Repository)
+	case config.Source.Maven != nil:
+		return c.verifyMavenSignatures(ctx, baseURL, config.Source.Maven.Group, config.Source.Maven.Name, upstream.Upstream)
+	}
+	return nil, errors.New("failed to match a source")
+}
+
+// verifyNPMAttestations verifies the SLSA provenance attestation of the package tarball,
+// which must have been built from the repository.
+// See https://docs.npmjs.com/generating-provenance-statements.
+func (c *Client) verifyNPMAttestations(ctx context.Context, registryURL string, name string, version string, repositoryURI string) ([]string, error) {
+	name = strings.TrimPrefix(name, "/")
+	response, err := c.get(ctx, fmt.Sprintf("%s/%s/%s", registryURL, name, version), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var data struct {
+		Dist struct {
+			Tarball      string          `json:"tarball"`
+			Integrity    string          `json:"integrity"`
+			Attestations json.RawMessage `json:"attestations"`
+		} `json:"dist"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if len(data.Dist.Attestations) == 0 {
+		return nil, fmt.Errorf("%w: %s@%s has no attestations", errNoProvenancePublished, name, version)
+	}
+	encodedDigest, ok := strings.CutPrefix(data.Dist.Integrity, "sha512-")
+	if !ok {
+		return nil, fmt.Errorf("unsupported integrity %q", data.Dist.Integrity)
+	}
+	digest, err := base64.StdEncoding.DecodeString(encodedDigest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid integrity %q: %w", data.Dist.Integrity, err)
+	}
+	if c.sigstoreRoot == nil {
+		return nil, errors.New("no Sigstore trusted root is configured to verify attestations")
+	}
+	attestationsResponse, err := c.get(ctx, fmt.Sprintf("%s/-/npm/v1/attestations/%s@%s", registryURL, name, version), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer attestationsResponse.Body.Close()
+	var attestations struct {
+		Attestations []struct {
+			PredicateType string          `json:"predicateType"` //nolint:tagliatelle
+			Bundle        json.RawMessage `json:"bundle"`
+		} `json:"attestations"`
+	}
+	if err := json.NewDecoder(attestationsResponse.Body).Decode(&attestations); err != nil {
+		return nil, err
+	}
+	for _, attestation := range attestations.Attestations {
+		// Registries also publish a publish attestation, signed by the registry.
+		if !strings.HasPrefix(attestation.PredicateType, slsaProvenancePrefix) {
+			continue
+		}
+		identity, err := verifySigstoreBundle(c.sigstoreRoot, attestation.Bundle, "sha512", hex.EncodeToString(digest), strings.TrimSuffix(repositoryURI, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("provenance attestation of %s@%s: %w", name, version, err)
+		}
+		return []string{
+			fmt.Sprintf("npm provenance attestation of %s built from %s", artifactName(data.Dist.Tarball), identity.SourceRepositoryURI),
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s@%s has no provenance attestation", errNoProvenancePublished, name, version)
+}
+
+// verifyGitHubAttestations verifies the artifact attestations of the assets of the
+// release for the tag. Assets without attestations are ignored, but at least one
+// asset must be attested. Attestations must have been built from the repository.
+// See https://docs.github.com/en/actions/security-for-github-actions/using-artifact-attestations.
+func (c *Client) verifyGitHubAttestations(ctx context.Context, owner string, repository string, tag string) ([]string, error) {
+	request, err := c.ghClient.NewRequest(http.MethodGet, fmt.Sprintf("repos/%s/%s/releases/tags/%s", owner, repository, url.PathEscape(tag)), nil)
+	if err != nil {
+		return nil, err
+	}
+	// Asset digests aren't exposed by the go-github types.
+	var release struct {
+		Assets []struct {
+			Name   string `json:"name"`
+			Digest string `json:"digest"`
+		} `json:"assets"`
+	}
+	if _, err := c.ghClient.Do(ctx, request, &release); err != nil {
+		if IsGitHubNotFound(err) {
+			return nil, fmt.Errorf("%w: no release for tag %s", errNoProvenancePublished, tag)
+		}
+		return nil, err
+	}
+	repositoryURI := fmt.Sprintf("https://github.com/%s/%s", owner, repository)
+	var verified []string
+	for _, asset := range release.Assets {
+		digest, ok := strings.CutPrefix(asset.Digest, "sha256:")
+		if !ok {
+			continue
+		}
+		attestations, _, err := c.ghClient.Repositories.ListAttestations(ctx, owner, repository, asset.Digest, nil)
+		if err != nil {
+			if IsGitHubNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if len(attestations.Attestations) == 0 {
+			continue
+		}
+		if c.sigstoreRoot == nil {
+			return nil, errors.New("no Sigstore trusted root is configured to verify attestations")
+		}
+		// Other attestations (e.g. SBOMs) may be published for the same asset:
+		// one provenance attestation built from the repository is required.
+		var lastErr error
+		for _, attestation := range attestations.Attestations {
+			identity, err := verifySigstoreBundle(c.sigstoreRoot, attestation.Bundle, "sha256", digest, repositoryURI)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			lastErr = nil
+			verified = append(verified, fmt.Sprintf("GitHub artifact attestation of %s built from %s", asset.Name, identity.SourceRepositoryURI))
+			break
+		}
+		if lastErr != nil {
+			return nil, fmt.Errorf("attestation of %s: %w", asset.Name, lastErr)
+		}
+	}
+	if len(verified) == 0 {
+		return nil, fmt.Errorf("%w: no attested assets in release %s", errNoProvenancePublished, tag)
+	}
+	return verified, nil
+}
+
+// verifyGoSumDB verifies that the hashes of the module zip and go.mod file served
+// by the proxy are included in the Go checksum database, as the go command does.
+func (c *Client) verifyGoSumDB(ctx context.Context, proxyURL string, name string, version string) ([]string, error) {
+	modulePath := strings.TrimPrefix(name, "/")
+	artifacts, err := c.hashGoModuleArtifacts(ctx, proxyURL, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	ops := &sumDBOps{ctx: ctx, client: c, config: make(map[string][]byte), cache: make(map[string][]byte)}
+	client := sumdb.NewClient(ops)
+	verified := make([]string, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		// The go.mod hash is looked up with a "/go.mod" version suffix.
+		artifactVersion := strings.TrimPrefix(artifact.Name, modulePath+"@")
+		lines, err := client.Lookup(modulePath, artifactVersion)
+		if err != nil {
+			if ops.securityError != "" {
+				return nil, fmt.Errorf("%w: %s", err, ops.securityError)
+			}
+			return nil, fmt.Errorf("checksum database lookup: %w", err)
+		}
+		if !slices.Contains(lines, fmt.Sprintf("%s %s h1:%s", modulePath, artifactVersion, artifact.Digests["h1"])) {
+			return nil, fmt.Errorf("hash of %s does not match the Go checksum database", artifact.Name)
+		}
+		verified = append(verified, fmt.Sprintf("Go checksum database inclusion of %s", artifact.Name))
+	}
+	return verified, nil
+}
+
+// sumDBOps implements sumdb.ClientOps with an in-memory configuration and cache.
+type sumDBOps struct {
+	ctx    context.Context //nolint:containedctx // sumdb.ClientOps methods don't take a context.
+	client *Client
+
+	mu            sync.Mutex
+	config        map[string][]byte
+	cache         map[string][]byte
+	securityError string
+}
+
+var _ sumdb.ClientOps = (*sumDBOps)(nil)
+
+func (o *sumDBOps) ReadRemote(path string) ([]byte, error) {
+	return o.client.download(o.ctx, o.client.goSumDBBaseURL+path)
+}
+
+func (o *sumDBOps) ReadConfig(file string) ([]byte, error) {
+	if file == "key" {
+		return []byte(o.client.goSumDBKey), nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	// A missing latest tree is returned as empty, to start from an empty tree.
+	return o.config[file], nil
+}
+
+func (o *sumDBOps) WriteConfig(file string, old, new []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !bytes.Equal(o.config[file], old) {
+		return sumdb.ErrWriteConflict
+	}
+	o.config[file] = new
+	return nil
+}
+
+func (o *sumDBOps) ReadCache(file string) ([]byte, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	data, ok := o.cache[file]
+	if !ok {
+		return nil, errNotFound
+	}
+	return data, nil
+}
+
+func (o *sumDBOps) WriteCache(file string, data []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.cache[file] = data
+}
+
+func (o *sumDBOps) Log(string) {}
+
+func (o *sumDBOps) SecurityError(msg string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.securityError = msg
+}
+
+// verifyMavenSignatures verifies the PGP signatures of the POM and, if published,
+// the JAR against the pinned keyring. Maven Central requires all files to be signed.
+func (c *Client) verifyMavenSignatures(ctx context.Context, repositoryURL string, group string, name string, version string) ([]string, error) {
+	versionDir := append(strings.Split(group, "."), name, version)
+	var verified, unsigned []string
+	for _, extension := range []string{"pom", "jar"} {
+		filename := fmt.Sprintf("%s-%s.%s", name, version, extension)
+		artifactURL, err := url.JoinPath(repositoryURL, append(versionDir, filename)...)
+		if err != nil {
+			return nil, err
+		}
+		content, err := c.download(ctx, artifactURL)
+		if err != nil {
+			if errors.Is(err, errNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		signature, err := c.download(ctx, artifactURL+".asc")
+		if err != nil {
+			if errors.Is(err, errNotFound) {
+				unsigned = append(unsigned, filename)
+				continue
+			}
+			return nil, err
+		}
+		if len(c.mavenKeyring) == 0 {
+			return nil, errors.New("no Maven keyring is configured to verify signatures")
+		}
+		signer, err := openpgp.CheckArmoredDetachedSignature(c.mavenKeyring, bytes.NewReader(content), bytes.NewReader(signature), nil)
+		if err != nil {
+			return nil, fmt.Errorf("signature of %s: %w", filename, err)
+		}
+		verified = append(verified, fmt.Sprintf("PGP signature of %s by key %X", filename, signer.PrimaryKey.Fingerprint))
+	}
+	if len(verified) == 0 {
+		return nil, fmt.Errorf("%w: %s:%s:%s is not signed", errNoProvenancePublished, group, name, version)
+	}
+	if len(unsigned) > 0 {
+		return nil, fmt.Errorf("missing signatures for %s", strings.Join(unsigned, ", "))
+	}
+	return verified, nil
+}
@@ -0,0 +1,289 @@
+package fetchclient
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/dirhash"
+	"golang.org/x/mod/sumdb/note"
+
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+	signer := newTestSigstore(t)
+	mux := http.NewServeMux()
+
+	// npm: one package with a provenance attestation, one without.
+	tarballDigest := sha512.Sum512([]byte("tarball"))
+	mux.HandleFunc("/npm/@acme/attested", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"versions":{"1.0.0":{}}}`))
+	})
+	mux.HandleFunc("/npm/@acme/attested/1.0.0", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"dist":{"tarball":"https://npm.test/@acme/attested/-/attested-1.0.0.tgz","integrity":"sha512-%s","attestations":{"url":"https://npm.test/-/npm/v1/attestations/@acme/attested@1.0.0","provenance":{"predicateType":"https://slsa.dev/provenance/v1"}}}}`,
+			base64.StdEncoding.EncodeToString(tarballDigest[:]))
+	})
+	npmAttestations, err := json.Marshal(map[string]any{"attestations": []any{
+		map[string]any{"predicateType": "https://github.com/npm/attestation/tree/main/specs/publish/v0.1", "bundle": map[string]any{}},
+		map[string]any{"predicateType": "https://slsa.dev/provenance/v1", "bundle": json.RawMessage(signer.bundle(t, testBundleOptions{digest: hex.EncodeToString(tarballDigest[:])}))},
+	}})
+	require.NoError(t, err)
+	mux.HandleFunc("/npm/-/npm/v1/attestations/@acme/attested@1.0.0", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(npmAttestations)
+	})
+	mux.HandleFunc("/npm/@acme/plain", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"versions":{"1.0.0":{}}}`))
+	})
+	mux.HandleFunc("/npm/@acme/plain/1.0.0", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"dist":{"tarball":"https://npm.test/@acme/plain/-/plain-1.0.0.tgz","integrity":"sha512-AAAA"}}`))
+	})
+
+	// Go: the checksum database records the hashes of the original module content;
+	// the proxy serves modified content for example.com/tampered.
+	gosum := make(map[string]string)
+	for _, modulePath := range []string{"example.com/mod", "example.com/tampered"} {
+		zipContent, modContent := testModule(t, modulePath, "v1.0.0", "package mod\n")
+		gosum[modulePath] = testGoSumLines(t, modulePath, "v1.0.0", zipContent, modContent)
+		if modulePath == "example.com/tampered" {
+			zipContent, _ = testModule(t, modulePath, "v1.0.0", "package mod // tampered\n")
+		}
+		mux.HandleFunc("/go/"+modulePath+"/@latest", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"Version":"v1.0.0"}`))
+		})
+		mux.HandleFunc("/go/"+modulePath+"/@v/list", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("v1.0.0\n"))
+		})
+		mux.HandleFunc("/go/"+modulePath+"/@v/v1.0.0.mod", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write(modContent)
+		})
+		mux.HandleFunc("/go/"+modulePath+"/@v/v1.0.0.zip", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write(zipContent)
+		})
+	}
+	signerKey, verifierKey, err := note.GenerateKey(rand.Reader, "sum.test")
+	require.NoError(t, err)
+	mux.Handle("/sumdb/", http.StripPrefix("/sumdb", sumdb.NewServer(sumdb.NewTestServer(signerKey, func(path, vers string) ([]byte, error) {
+		lines, ok := gosum[path]
+		if !ok || vers != "v1.0.0" {
+			return nil, os.ErrNotExist
+		}
+		return []byte(lines), nil
+	}))))
+
+	// Maven: artifacts signed by the pinned key, unsigned, and signed by another key.
+	pinned, err := openpgp.NewEntity("Pinned", "", "pinned@example.com", nil)
+	require.NoError(t, err)
+	other, err := openpgp.NewEntity("Other", "", "other@example.com", nil)
+	require.NoError(t, err)
+	for name, key := range map[string]*openpgp.Entity{"signed": pinned, "unsigned": nil, "forged": other} {
+		versionDir := "/maven/io/acme/" + name + "/1.0.0/"
+		mux.HandleFunc("/maven/io/acme/"+name+"/maven-metadata.xml", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`<metadata><versioning><versions><version>1.0.0</version></versions></versioning></metadata>`))
+		})
+		for _, extension := range []string{"pom", "jar"} {
+			filename := fmt.Sprintf("%s-1.0.0.%s", name, extension)
+			content := []byte(filename + " content")
+			mux.HandleFunc(versionDir+filename, func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write(content)
+			})
+			if key == nil {
+				continue
+			}
+			var signature bytes.Buffer
+			require.NoError(t, openpgp.ArmoredDetachSign(&signature, key, bytes.NewReader(content), nil))
+			mux.HandleFunc(versionDir+filename+".asc", func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write(signature.Bytes())
+			})
+		}
+	}
+
+	// GitHub: a release asset with a provenance attestation (and an SBOM attestation),
+	// and a release whose attestation was built from another repository.
+	assetDigest := sha256.Sum256([]byte("asset"))
+	for repository, sourceRepository := range map[string]string{"plugin": "https://github.com/acme/plugin", "forked": "https://github.com/evil/plugin"} {
+		mux.HandleFunc("/github/repos/acme/"+repository+"/tags", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`[{"name":"v1.0.0","commit":{"sha":"aaa"}}]`))
+		})
+		mux.HandleFunc("/github/repos/acme/"+repository+"/releases/tags/v1.0.0", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = fmt.Fprintf(w, `{"assets":[{"name":"checksums.txt"},{"name":"plugin.tar.gz","digest":"sha256:%x"}]}`, assetDigest)
+		})
+		githubAttestations, err := json.Marshal(map[string]any{"attestations": []any{
+			map[string]any{"bundle": json.RawMessage(signer.bundle(t, testBundleOptions{digest: hex.EncodeToString(assetDigest[:]), predicateType: "https://spdx.dev/Document/v2.3", sourceRepository: sourceRepository}))},
+			map[string]any{"bundle": json.RawMessage(signer.bundle(t, testBundleOptions{digest: hex.EncodeToString(assetDigest[:]), sourceRepository: sourceRepository}))},
+		}})
+		require.NoError(t, err)
+		mux.HandleFunc(fmt.Sprintf("/github/repos/acme/%s/attestations/sha256:%x", repository, assetDigest), func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write(githubAttestations)
+		})
+	}
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	ghClient := github.NewClient(srv.Client())
+	ghClient.BaseURL, err = url.Parse(srv.URL + "/github/")
+	require.NoError(t, err)
+	c := &Client{
+		httpClient:     srv.Client(),
+		ghClient:       ghClient,
+		goProxyBaseURL: srv.URL + "/go",
+		goSumDBBaseURL: srv.URL + "/sumdb",
+		goSumDBKey:     verifierKey,
+		mavenBaseURL:   srv.URL + "/maven",
+		npmBaseURL:     srv.URL + "/npm",
+		sigstoreRoot:   signer.root,
+		mavenKeyring:   openpgp.EntityList{pinned},
+	}
+
+	tests := []struct {
+		name    string
+		source  source.Source
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "verification disabled",
+			source: source.Source{NPMRegistry: &source.NPMRegistryConfig{Name: "@acme/plain"}},
+		},
+		{
+			name:   "npm provenance attestation",
+			source: source.Source{Verification: source.VerificationRequired, NPMRegistry: &source.NPMRegistryConfig{Name: "@acme/attested", Repository: "https://github.com/acme/plugin"}},
+			want:   []string{"npm provenance attestation of attested-1.0.0.tgz built from https://github.com/acme/plugin"},
+		},
+		{
+			name:    "npm attestation from another repository",
+			source:  source.Source{Verification: source.VerificationIfAvailable, NPMRegistry: &source.NPMRegistryConfig{Name: "@acme/attested", Repository: "https://github.com/acme/other"}},
+			wantErr: `expected SourceRepositoryURI to be "https://github.com/acme/other", got "https://github.com/acme/plugin"`,
+		},
+		{
+			name:    "npm without repository",
+			source:  source.Source{Verification: source.VerificationIfAvailable, NPMRegistry: &source.NPMRegistryConfig{Name: "@acme/attested"}},
+			wantErr: "npm_registry.repository must be set",
+		},
+		{
+			name:   "npm without attestations if available",
+			source: source.Source{Verification: source.VerificationIfAvailable, NPMRegistry: &source.NPMRegistryConfig{Name: "@acme/plain", Repository: "https://github.com/acme/plugin"}},
+		},
+		{
+			name:    "npm without attestations required",
+			source:  source.Source{Verification: source.VerificationRequired, NPMRegistry: &source.NPMRegistryConfig{Name: "@acme/plain", Repository: "https://github.com/acme/plugin"}},
+			wantErr: "@acme/plain@1.0.0 has no attestations",
+		},
+		{
+			name:   "go checksum database",
+			source: source.Source{Verification: source.VerificationRequired, GoProxy: &source.GoProxyConfig{Name: "example.com/mod"}},
+			want: []string{
+				"Go checksum database inclusion of example.com/mod@v1.0.0",
+				"Go checksum database inclusion of example.com/mod@v1.0.0/go.mod",
+			},
+		},
+		{
+			name:    "go checksum mismatch",
+			source:  source.Source{Verification: source.VerificationIfAvailable, GoProxy: &source.GoProxyConfig{Name: "example.com/tampered"}},
+			wantErr: "hash of example.com/tampered@v1.0.0 does not match the Go checksum database",
+		},
+		{
+			name:   "maven signatures",
+			source: source.Source{Verification: source.VerificationRequired, Maven: &source.MavenConfig{Group: "io.acme", Name: "signed"}},
+			want: []string{
+				fmt.Sprintf("PGP signature of signed-1.0.0.pom by key %X", pinned.PrimaryKey.Fingerprint),
+				fmt.Sprintf("PGP signature of signed-1.0.0.jar by key %X", pinned.PrimaryKey.Fingerprint),
+			},
+		},
+		{
+			name:   "maven unsigned if available",
+			source: source.Source{Verification: source.VerificationIfAvailable, Maven: &source.MavenConfig{Group: "io.acme", Name: "unsigned"}},
+		},
+		{
+			name:    "maven unsigned required",
+			source:  source.Source{Verification: source.VerificationRequired, Maven: &source.MavenConfig{Group: "io.acme", Name: "unsigned"}},
+			wantErr: "io.acme:unsigned:1.0.0 is not signed",
+		},
+		{
+			name:    "maven signed by unpinned key",
+			source:  source.Source{Verification: source.VerificationIfAvailable, Maven: &source.MavenConfig{Group: "io.acme", Name: "forged"}},
+			wantErr: "signature of forged-1.0.0.pom",
+		},
+		{
+			name:   "github artifact attestations",
+			source: source.Source{Verification: source.VerificationRequired, GitHub: &source.GitHubConfig{Owner: "acme", Repository: "plugin"}},
+			want:   []string{"GitHub artifact attestation of plugin.tar.gz built from https://github.com/acme/plugin"},
+		},
+		{
+			name:    "github attestation from another repository",
+			source:  source.Source{Verification: source.VerificationIfAvailable, GitHub: &source.GitHubConfig{Owner: "acme", Repository: "forked"}},
+			wantErr: `got "https://github.com/evil/plugin"`,
+		},
+		{
+			name:    "unsupported source required",
+			source:  source.Source{Verification: source.VerificationRequired, Crates: &source.CratesConfig{CrateName: "prost"}},
+			wantErr: "no verifiable provenance is published for crates sources",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verified, err := c.Verify(t.Context(), &source.Config{Source: tt.source}, "v1.0.0")
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, verified)
+		})
+	}
+}
+
+// testModule returns the zip and go.mod file of a module with a single Go file.
+func testModule(t *testing.T, modulePath string, version string, goFile string) ([]byte, []byte) {
+	t.Helper()
+	modContent := []byte("module " + modulePath + "\n")
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for name, content := range map[string][]byte{"go.mod": modContent, "mod.go": []byte(goFile)} {
+		file, err := writer.Create(modulePath + "@" + version + "/" + name)
+		require.NoError(t, err)
+		_, err = file.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return buffer.Bytes(), modContent
+}
+
+// testGoSumLines returns the go.sum lines of a module.
+func testGoSumLines(t *testing.T, modulePath string, version string, zipContent []byte, modContent []byte) string {
+	t.Helper()
+	zipPath := filepath.Join(t.TempDir(), "module.zip")
+	require.NoError(t, os.WriteFile(zipPath, zipContent, 0644))
+	zipHash, err := dirhash.HashZip(zipPath, dirhash.Hash1)
+	require.NoError(t, err)
+	modHash, err := dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(modContent)), nil
+	})
+	require.NoError(t, err)
+	return strings.Join([]string{
+		fmt.Sprintf("%s %s %s", modulePath, version, zipHash),
+		fmt.Sprintf("%s %s/go.mod %s", modulePath, version, modHash),
+		"",
+	}, "\n")
+}
@@ -0,0 +1,193 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"buf.build/go/app/appcmd"
+	"buf.build/go/app/appext"
+	"github.com/spf13/pflag"
+	"golang.org/x/mod/semver"
+
+	"github.com/bufbuild/plugins/internal/docker"
+	"github.com/bufbuild/plugins/internal/plugin"
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+type createVersionFlags struct {
+	from string
+}
+
+func (f *createVersionFlags) Bind(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&f.from,
+		"from",
+		"",
+		`The existing version to create the new version from. Defaults to the latest version before the new version.`,
+	)
+}
+
+// NewCreateVersionCommand returns the command which creates a plugin version by hand,
+// without looking up its source: for example a backport on an older release line.
+func NewCreateVersionCommand(name string) *appcmd.Command {
+	builder := appext.NewBuilder(name)
+	f := &createVersionFlags{}
+	return &appcmd.Command{
+		Use:   name + " <org/name> <version> [directory]",
+		Short: "Creates a plugin version from an existing version, then runs its post-processing and tests.",
+		Args:  appcmd.RangeArgs(2, 3),
+		Run: builder.NewRunFunc(func(ctx context.Context, container appext.Container) error {
+			root, err := rootDir(container, 2)
+			if err != nil {
+				return err
+			}
+			logger := container.Logger()
+			created, err := createVersion(ctx, logger, root, container.Arg(0), container.Arg(1), f.from)
+			if err != nil {
+				return fmt.Errorf("failed to create version: %w", err)
+			}
+			logger.InfoContext(ctx, "created", slog.String("path", filepath.Join(created.pluginDir, created.newVersion)))
+			_, quarantined, err := postProcessCreatedPlugins(ctx, logger, http.DefaultClient, []createdPlugin{created}, false)
+			if err != nil {
+				return fmt.Errorf("failed to run post-processing on %s: %w", created, err)
+			}
+			if len(quarantined) > 0 {
+				return fmt.Errorf("removed %s: %s", created, quarantined[0].Reason)
+			}
+			return nil
+		}),
+		BindFlags:           f.Bind,
+		BindPersistentFlags: builder.BindRoot,
+	}
+}
+
+// createVersion creates the version of the plugin ("org/name") in the repository
+// root from the version from, as the fetcher does for a new upstream version: the
+// plugin dependencies and base images are updated to their latest versions. If from
+// is empty, the latest version before the new version is used.
+func createVersion(
+	ctx context.Context,
+	logger *slog.Logger,
+	root string,
+	pluginName string,
+	version string,
+	from string,
+) (createdPlugin, error) {
+	org, name, ok := strings.Cut(pluginName, "/")
+	if !ok || org == "" || name == "" || strings.Contains(name, "/") {
+		return createdPlugin{}, fmt.Errorf("invalid plugin %q: must be org/name", pluginName)
+	}
+	if !semver.IsValid(version) {
+		return createdPlugin{}, fmt.Errorf("invalid version %q", version)
+	}
+	pluginDir := filepath.Join(root, "plugins", org, name)
+	exists, err := checkDirExists(filepath.Join(pluginDir, version))
+	if err != nil {
+		return createdPlugin{}, err
+	}
+	if exists {
+		return createdPlugin{}, fmt.Errorf("%s:%s already exists", pluginName, version)
+	}
+	if from == "" {
+		from, err = getPreviousVersionFromDir(pluginDir, version)
+		if err != nil {
+			return createdPlugin{}, err
+		}
+	} else if exists, err := checkDirExists(filepath.Join(pluginDir, from)); err != nil {
+		return createdPlugin{}, err
+	} else if !exists {
+		return createdPlugin{}, fmt.Errorf("%s:%s does not exist", pluginName, from)
+	}
+	config, err := loadPluginSourceConfig(pluginDir)
+	if err != nil {
+		return createdPlugin{}, err
+	}
+	baseImageDir, err := docker.FindBaseImageDir(root)
+	if err != nil {
+		return createdPlugin{}, err
+	}
+	latestBaseImages, err := docker.LoadLatestBaseImages(baseImageDir)
+	if err != nil {
+		return createdPlugin{}, err
+	}
+	allPlugins, err := plugin.FindAll(filepath.Join(root, "plugins"))
+	if err != nil {
+		return createdPlugin{}, fmt.Errorf("failed to load existing plugins: %w", err)
+	}
+	latestPluginVersions := make(map[string]string)
+	for _, p := range allPlugins {
+		current := latestPluginVersions[p.Name]
+		if current == "" || semver.Compare(current, p.PluginVersion) < 0 {
+			latestPluginVersions[p.Name] = p.PluginVersion
+		}
+	}
+	pending := &pluginToCreate{
+		pluginDir:       pluginDir,
+		previousVersion: from,
+		newVersion:      version,
+		config:          config,
+	}
+	if err := createPluginDir(ctx, logger, pending, latestBaseImages, latestPluginVersions); err != nil {
+		return createdPlugin{}, err
+	}
+	created := createdPlugin{
+		org:             org,
+		name:            name,
+		pluginDir:       pluginDir,
+		previousVersion: from,
+		newVersion:      version,
+	}
+	if config != nil {
+		created.source = config.CacheKey()
+		created.postProcess = config.PostProcess
+	}
+	return created, nil
+}
+
+// getPreviousVersionFromDir returns the latest version in basedir before the version.
+func getPreviousVersionFromDir(basedir string, version string) (string, error) {
+	entries, err := os.ReadDir(basedir)
+	if err != nil {
+		return "", err
+	}
+	var previous string
+	for _, entry := range entries {
+		if !entry.IsDir() || !semver.IsValid(entry.Name()) || semver.Compare(entry.Name(), version) >= 0 {
+			continue
+		}
+		if previous == "" || semver.Compare(entry.Name(), previous) > 0 {
+			previous = entry.Name()
+		}
+	}
+	if previous == "" {
+		return "", fmt.Errorf("no version before %s in %s: use --from", version, basedir)
+	}
+	return previous, nil
+}
+
+// loadPluginSourceConfig loads the source.yaml of a plugin directory, or returns nil
+// if the plugin has none.
+func loadPluginSourceConfig(pluginDir string) (*source.Config, error) {
+	filename := filepath.Join(pluginDir, "source.yaml")
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	config, err := source.NewConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	config.Filename = filename
+	return config, nil
+}
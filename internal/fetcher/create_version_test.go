@@ -0,0 +1,58 @@
+package fetcher
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateVersion(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	tmpDir := t.TempDir()
+	setupTestRepository(t, tmpDir)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	// A newer base-plugin release line, so v1.0.1 is a backport.
+	basePluginDir := filepath.Join(tmpDir, "plugins", "test", "base-plugin")
+	require.NoError(t, os.CopyFS(filepath.Join(basePluginDir, "v2.0.0"), os.DirFS(filepath.Join(basePluginDir, "v1.0.0"))))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(basePluginDir, "v2.0.0", "buf.plugin.yaml"),
+		[]byte("version: v1\nname: buf.build/test/base-plugin\nplugin_version: v2.0.0\noutput_languages:\n  - go\n"),
+		0644,
+	))
+
+	created, err := createVersion(ctx, logger, tmpDir, "test/consumer-plugin", "v1.0.1", "")
+	require.NoError(t, err)
+	assert.Equal(t, createdPlugin{
+		org:             "test",
+		name:            "consumer-plugin",
+		pluginDir:       filepath.Join(tmpDir, "plugins", "test", "consumer-plugin"),
+		previousVersion: "v1.0.0",
+		newVersion:      "v1.0.1",
+		source:          "github-test-consumer-plugin",
+	}, created)
+	config, err := readPluginConfig(filepath.Join(created.pluginDir, "v1.0.1"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.1", config.PluginVersion)
+	require.Len(t, config.Deps, 1)
+	// Plugin dependencies are updated to their latest versions.
+	assert.Equal(t, "buf.build/test/base-plugin:v2.0.0", config.Deps[0].Plugin)
+
+	created, err = createVersion(ctx, logger, tmpDir, "test/base-plugin", "v1.5.0", "v2.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "v2.0.0", created.previousVersion)
+
+	_, err = createVersion(ctx, logger, tmpDir, "test/base-plugin", "v1.0.0", "")
+	require.ErrorContains(t, err, "already exists")
+	_, err = createVersion(ctx, logger, tmpDir, "test/base-plugin", "v0.9.0", "")
+	require.ErrorContains(t, err, "use --from")
+	_, err = createVersion(ctx, logger, tmpDir, "test/base-plugin", "v1.6.0", "v1.2.0")
+	require.ErrorContains(t, err, "does not exist")
+	_, err = createVersion(ctx, logger, tmpDir, "base-plugin", "v1.6.0", "")
+	require.Error(t, err)
+}
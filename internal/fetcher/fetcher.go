@@ -0,0 +1,1822 @@
+// Package fetcher creates new plugin versions from the latest upstream versions of
+// their sources, and runs the post-processing and tests of each created version.
+package fetcher
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"buf.build/go/app/appcmd"
+	"buf.build/go/app/appext"
+	"github.com/bufbuild/buf/private/bufpkg/bufremoteplugin/bufremotepluginconfig"
+	"github.com/bufbuild/buf/private/pkg/encoding"
+	"github.com/spf13/pflag"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/bufbuild/plugins/internal/cargo"
+	"github.com/bufbuild/plugins/internal/docker"
+	"github.com/bufbuild/plugins/internal/fetchclient"
+	"github.com/bufbuild/plugins/internal/git"
+	"github.com/bufbuild/plugins/internal/maven"
+	"github.com/bufbuild/plugins/internal/nuget"
+	"github.com/bufbuild/plugins/internal/plugin"
+	"github.com/bufbuild/plugins/internal/readiness"
+	"github.com/bufbuild/plugins/internal/release"
+	"github.com/bufbuild/plugins/internal/source"
+	"github.com/bufbuild/plugins/internal/substitute"
+)
+
+const (
+	communityOrg = "community"
+	// defaultGoModVersion is the Go version assumed for modules with no go directive.
+	defaultGoModVersion = "1.16"
+	goModProxyURL       = "https://proxy.golang.org"
+	// defaultParallelism is the default number of sources fetched concurrently.
+	// Per-host limits are enforced separately by the fetch client.
+	defaultParallelism = 16
+	// provenanceFilename is the file recording the upstream provenance of a plugin version.
+	provenanceFilename = "provenance.json"
+	// uvVersion is the version of uv used to compile the requirements of Python plugins.
+	uvVersion = "0.9.5"
+	// defaultMaxSourceErrorRatio is the default ratio of sources which may fail without
+	// failing the run: beyond it, failures are likely not specific to a few upstreams.
+	defaultMaxSourceErrorRatio = 0.2
+)
+
+var (
+	errNoVersions = errors.New("no versions found")
+	// skippedDirNames are the directories of a plugin version which are never copied
+	// to a new version.
+	skippedDirNames = map[string]struct{}{
+		".git": {},
+	}
+	// vendoredDirNames are the directories of a plugin version holding third-party
+	// files, which are copied to a new version without substituting the version.
+	vendoredDirNames = map[string]struct{}{
+		"vendor":       {},
+		"third_party":  {},
+		"node_modules": {},
+	}
+)
+
+type flags struct {
+	include             []string
+	parallelism         int
+	sigstoreTrustedRoot string
+	mavenKeyring        string
+	plan                bool
+	quarantine          bool
+	summary             string
+	maxSourceErrors     int
+	maxSourceErrorRatio float64
+	splitBy             string
+	publish             bool
+	publishRepository   string
+	publishBase         string
+	publishLabels       []string
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	flagSet.StringArrayVar(
+		&f.include,
+		"include",
+		nil,
+		`Only fetch plugins matching these patterns (org or org/name). May be specified multiple times.`,
+	)
+	flagSet.IntVar(
+		&f.parallelism,
+		"parallelism",
+		defaultParallelism,
+		`The maximum number of sources to fetch concurrently.`,
+	)
+	flagSet.StringVar(
+		&f.sigstoreTrustedRoot,
+		"sigstore-trusted-root",
+		"",
+		`The Sigstore trusted_root.json used to verify npm and GitHub attestations.`,
+	)
+	flagSet.StringVar(
+		&f.mavenKeyring,
+		"maven-keyring",
+		"",
+		`The armored PGP keyring trusted to sign Maven artifacts.`,
+	)
+	flagSet.BoolVar(
+		&f.plan,
+		"plan",
+		false,
+		`Print the plugin versions which would be created as JSON, with diffs of their files, without writing anything.`,
+	)
+	flagSet.BoolVar(
+		&f.quarantine,
+		"quarantine",
+		false,
+		`Add the plugin versions failing their tests to the ignore_versions of their source.yaml.`,
+	)
+	flagSet.StringVar(
+		&f.summary,
+		"summary",
+		"",
+		`Write the outcome of each source (status, reason, and versions seen) as JSON to this file.`,
+	)
+	flagSet.IntVar(
+		&f.maxSourceErrors,
+		"max-source-errors",
+		-1,
+		`Fail if more sources than this fail to be fetched. Negative for no limit.`,
+	)
+	flagSet.Float64Var(
+		&f.maxSourceErrorRatio,
+		"max-source-error-ratio",
+		defaultMaxSourceErrorRatio,
+		`Fail if a larger ratio of the sources fail to be fetched.`,
+	)
+	flagSet.StringVar(
+		&f.splitBy,
+		"split-by",
+		"",
+		`Also partition the created plugins into independent PRs by "org", lockstep "closure", or "plugin", written as JSON to the pr_groups GitHub output.`,
+	)
+	flagSet.BoolVar(
+		&f.publish,
+		"publish",
+		false,
+		`Commit the created plugins to a branch (one per group with --split-by) and open or update its PR, using GITHUB_TOKEN.`,
+	)
+	flagSet.StringVar(
+		&f.publishRepository,
+		"publish-repository",
+		defaultPublishRepository,
+		`The GitHub repository (owner/repo) to publish to with --publish.`,
+	)
+	flagSet.StringVar(
+		&f.publishBase,
+		"publish-base",
+		defaultPublishBase,
+		`The branch to open PRs against with --publish.`,
+	)
+	flagSet.StringArrayVar(
+		&f.publishLabels,
+		"publish-label",
+		nil,
+		`Add this label to the PRs opened or updated with --publish. May be specified multiple times.`,
+	)
+}
+
+type pluginFilter struct {
+	orgs    map[string]struct{}
+	plugins map[string]struct{}
+}
+
+func newPluginFilter(includes []string) *pluginFilter {
+	if len(includes) == 0 {
+		return nil
+	}
+	f := &pluginFilter{
+		orgs:    make(map[string]struct{}),
+		plugins: make(map[string]struct{}),
+	}
+	for _, pattern := range includes {
+		if strings.Contains(pattern, "/") {
+			f.plugins[pattern] = struct{}{}
+		} else {
+			f.orgs[pattern] = struct{}{}
+		}
+	}
+	return f
+}
+
+func (f *pluginFilter) includes(org, name string) bool {
+	if f == nil {
+		return true
+	}
+	if _, ok := f.orgs[org]; ok {
+		return true
+	}
+	_, ok := f.plugins[org+"/"+name]
+	return ok
+}
+
+// Fetcher is an interface for fetching plugin versions from external sources.
+// Implementations must be safe for concurrent use.
+type Fetcher interface {
+	Fetch(ctx context.Context, config *source.Config) (string, error)
+}
+
+// VersionsFetcher is optionally implemented by a Fetcher to also return the versions
+// observed upstream when fetching the latest one, which are listed in the --summary.
+type VersionsFetcher interface {
+	FetchVersions(ctx context.Context, config *source.Config) (string, []string, error)
+}
+
+// ProvenanceResolver is optionally implemented by a Fetcher to resolve the upstream
+// provenance of a fetched version. When implemented, the provenance is written to
+// provenanceFilename in each created plugin version directory.
+type ProvenanceResolver interface {
+	Provenance(ctx context.Context, config *source.Config, version string) (*fetchclient.Provenance, error)
+}
+
+// Verifier is optionally implemented by a Fetcher to verify the upstream provenance
+// of a fetched version at the level required by its source config. Versions which
+// fail verification are not created, and their source is reported as failed.
+type Verifier interface {
+	Verify(ctx context.Context, config *source.Config, version string) ([]string, error)
+}
+
+// ReadinessProber is optionally implemented by a Fetcher to probe the upstream artifacts
+// of a pending plugin version through its own client, such as with its host limits,
+// registry credentials and the source's registry. Otherwise the public registries are
+// probed with http.DefaultClient.
+type ReadinessProber interface {
+	Prober(config *source.Config) (*readiness.Prober, error)
+}
+
+// ChangesResolver is optionally implemented by a Fetcher to link to the upstream
+// changes of a created version (compare URLs, release notes and registry pages) in
+// the PR body.
+type ChangesResolver interface {
+	Changes(ctx context.Context, config *source.Config, previousVersion string, newVersion string) (*fetchclient.Changes, error)
+}
+
+// NewRootCommand returns the command which fetches the latest plugin versions.
+func NewRootCommand(name string) *appcmd.Command {
+	builder := appext.NewBuilder(name)
+	f := &flags{}
+	return &appcmd.Command{
+		Use:   name + " [directory]",
+		Short: "Fetches latest plugin versions from external sources.",
+		Args:  appcmd.MaximumNArgs(1),
+		Run: builder.NewRunFunc(func(ctx context.Context, container appext.Container) error {
+			if err := validateSplitBy(f.splitBy); err != nil {
+				return err
+			}
+			var prPublisher *publisher
+			if f.publish && !f.plan {
+				var err error
+				prPublisher, err = newPublisher(container.Logger(), release.NewClient().GitHub, f.publishRepository, f.publishBase, f.publishLabels)
+				if err != nil {
+					return err
+				}
+			}
+			client, err := fetchclient.New(
+				ctx,
+				fetchclient.WithSigstoreTrustedRoot(f.sigstoreTrustedRoot),
+				fetchclient.WithMavenKeyring(f.mavenKeyring),
+			)
+			if err != nil {
+				return err
+			}
+			summary := &fetchSummary{}
+			if f.plan {
+				plan := &fetchPlan{}
+				if _, err := run(ctx, container, client, f, withPlan(plan), withSummary(summary)); err != nil {
+					return fmt.Errorf("failed to plan versions: %w", err)
+				}
+				if err := writePlan(container.Stdout(), plan); err != nil {
+					return err
+				}
+				return reportSummary(ctx, container.Logger(), f, summary)
+			}
+			created, err := run(ctx, container, client, f, withSummary(summary))
+			if err != nil {
+				return fmt.Errorf("failed to fetch versions: %w", err)
+			}
+			created, quarantined, err := postProcessCreatedPlugins(ctx, container.Logger(), http.DefaultClient, created, f.quarantine)
+			if err != nil {
+				return fmt.Errorf("failed to run post-processing on plugins: %w", err)
+			}
+			for _, entry := range quarantined {
+				summary.recordQuarantined(entry)
+			}
+			if err := writeGitHubOutput("pr_title", generatePRTitle(created)); err != nil {
+				return fmt.Errorf("failed to write GitHub output: %w", err)
+			}
+			body := generatePRBody(created)
+			if report := generateQuarantineReport(quarantined); report != "" {
+				body = strings.TrimLeft(body+"\n\n"+report, "\n")
+			}
+			if err := writeGitHubOutput("pr_body", body); err != nil {
+				return fmt.Errorf("failed to write GitHub output: %w", err)
+			}
+			quarantineReport, err := marshalQuarantineReport(quarantined)
+			if err != nil {
+				return err
+			}
+			if err := writeGitHubOutput("quarantine_report", quarantineReport); err != nil {
+				return fmt.Errorf("failed to write GitHub output: %w", err)
+			}
+			if f.splitBy == "" && prPublisher == nil {
+				return reportSummary(ctx, container.Logger(), f, summary)
+			}
+			root, err := rootDir(container, 0)
+			if err != nil {
+				return err
+			}
+			var groups []*prGroup
+			if f.splitBy != "" {
+				groups, err = splitCreatedPlugins(root, created, quarantined, f.splitBy)
+				if err != nil {
+					return fmt.Errorf("failed to split created plugins: %w", err)
+				}
+				if err := writePRGroups(groups); err != nil {
+					return fmt.Errorf("failed to write GitHub output: %w", err)
+				}
+			} else {
+				group, err := combinedPRGroup(root, created, quarantined, generatePRTitle(created), body)
+				if err != nil {
+					return err
+				}
+				groups = []*prGroup{group}
+			}
+			if prPublisher != nil {
+				if err := prPublisher.publishGroups(ctx, root, groups); err != nil {
+					return fmt.Errorf("failed to publish: %w", err)
+				}
+			}
+			return reportSummary(ctx, container.Logger(), f, summary)
+		}),
+		BindFlags:           f.Bind,
+		BindPersistentFlags: builder.BindRoot,
+	}
+}
+
+type goMinVersionBump struct {
+	oldVersion string
+	newVersion string
+	module     string
+	modVersion string
+}
+
+type createdPlugin struct {
+	org              string
+	name             string
+	pluginDir        string
+	previousVersion  string
+	newVersion       string
+	goMinVersionBump *goMinVersionBump
+	// source is the cache key of the plugin's source config (see source.Config.CacheKey).
+	source string
+	// changes links to the upstream changes, if resolved (see ChangesResolver).
+	changes *fetchclient.Changes
+	// postProcess are the post-processing steps declared in the plugin's source config.
+	postProcess []source.PostProcessStep
+}
+
+func (p createdPlugin) String() string {
+	return fmt.Sprintf("%s/%s:%s", p.org, p.name, p.newVersion)
+}
+
+// postProcessCreatedPlugins runs the post-processing steps declared for each created plugin,
+// then tests it (see testCreatedPlugins). It returns the plugins which passed both and
+// the quarantined ones.
+func postProcessCreatedPlugins(
+	ctx context.Context,
+	logger *slog.Logger,
+	client *http.Client,
+	plugins []createdPlugin,
+	quarantine bool,
+) ([]createdPlugin, []*quarantinedPlugin, error) {
+	if len(plugins) == 0 {
+		return nil, nil, nil
+	}
+	passed, quarantined, err := testCreatedPlugins(ctx, logger, plugins, postProcessAndTest(logger, client, runPluginTests), quarantine)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to run plugin tests: %w", err)
+	}
+	return passed, quarantined, nil
+}
+
+// postProcessAndTest returns a check running the post-processing steps of a created
+// plugin, then test.
+func postProcessAndTest(
+	logger *slog.Logger,
+	client *http.Client,
+	test func(context.Context, createdPlugin) error,
+) func(context.Context, *createdPlugin) error {
+	return func(ctx context.Context, plugin *createdPlugin) error {
+		if err := runPostProcessSteps(ctx, logger, client, plugin); err != nil {
+			return fmt.Errorf("post-processing failed: %w", err)
+		}
+		if err := test(ctx, *plugin); err != nil {
+			return fmt.Errorf("make test failed: %w", err)
+		}
+		return nil
+	}
+}
+
+// runGoModTidy runs 'go mod tidy' for plugins (like twirp-go) which don't use modules.
+// In order to get more reproducible builds, we check in a go.mod/go.sum file.
+func runGoModTidy(ctx context.Context, logger *slog.Logger, plugin createdPlugin, image string) error {
+	logger.InfoContext(ctx, "running go mod tidy", slog.Any("plugin", plugin), slog.String("image", image))
+	return runInContainer(
+		ctx,
+		image,
+		filepath.Join(plugin.pluginDir, plugin.newVersion),
+		[]string{"GOPATH=/tmp/go", "GOCACHE=/tmp/go-cache"},
+		nil,
+		"go", "mod", "tidy",
+	)
+}
+
+// recreateNPMPackageLock will remove an existing package-lock.json file and recreate it.
+// This will ensure that we correctly resolve any updated versions in package.json.
+// Only the package-lock.json is written: no node_modules is installed.
+func recreateNPMPackageLock(ctx context.Context, logger *slog.Logger, plugin createdPlugin, image string) error {
+	versionDir := filepath.Join(plugin.pluginDir, plugin.newVersion)
+	if err := os.Remove(filepath.Join(versionDir, "package-lock.json")); err != nil {
+		return err
+	}
+	logger.InfoContext(ctx, "recreating package-lock.json", slog.Any("plugin", plugin), slog.String("image", image))
+	return runInContainer(
+		ctx,
+		image,
+		versionDir,
+		[]string{"npm_config_cache=/tmp/npm-cache"},
+		nil,
+		"npm", "install", "--package-lock-only", "--ignore-scripts",
+	)
+}
+
+// recompilePythonRequirements recompiles the requirements.txt of Python plugins, pinning every
+// transitive dependency with the hashes of its distributions, so pip installs them in
+// hash-checking mode. The requirements are compiled from requirements.in if it exists, or
+// from the requirements of requirements.txt.
+func recompilePythonRequirements(ctx context.Context, logger *slog.Logger, plugin createdPlugin, image string) error {
+	versionDir := filepath.Join(plugin.pluginDir, plugin.newVersion)
+	input, err := os.ReadFile(filepath.Join(versionDir, "requirements.in"))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		input, err = os.ReadFile(filepath.Join(versionDir, "requirements.txt"))
+		if err != nil {
+			return err
+		}
+	}
+	logger.InfoContext(ctx, "recompiling requirements.txt", slog.Any("plugin", plugin), slog.String("image", image))
+	return runInContainer(
+		ctx,
+		image,
+		versionDir,
+		[]string{"UV_CACHE_DIR=/tmp/uv-cache"},
+		bytes.NewReader(pythonRequirementsInput(input)),
+		"sh", "-c", fmt.Sprintf(
+			"pip install --quiet --disable-pip-version-check --target /tmp/uv uv==%s && "+
+				"/tmp/uv/bin/uv pip compile --quiet --generate-hashes --no-header --no-annotate --output-file requirements.txt -",
+			uvVersion,
+		),
+	)
+}
+
+// pythonRequirementsInput returns the requirements of a requirements file without their
+// hashes, comments, and line continuations, to compile them again.
+func pythonRequirementsInput(content []byte) []byte {
+	var result bytes.Buffer
+	joined := strings.ReplaceAll(string(content), "\\\n", " ")
+	for line := range strings.Lines(joined) {
+		if index := strings.Index(line, "#"); index != -1 && (index == 0 || line[index-1] == ' ' || line[index-1] == '\t') {
+			line = line[:index]
+		}
+		var fields []string
+		for _, field := range strings.Fields(line) {
+			if !strings.HasPrefix(field, "--hash") {
+				fields = append(fields, field)
+			}
+		}
+		if len(fields) > 0 {
+			result.WriteString(strings.Join(fields, " "))
+			result.WriteByte('\n')
+		}
+	}
+	return result.Bytes()
+}
+
+// recreateSwiftPackageResolved resolves Swift package dependencies for plugins that use Swift packages.
+// It clones the git repository specified in the Dockerfile, runs 'swift package resolve',
+// and moves the generated Package.resolved file to the version directory.
+func recreateSwiftPackageResolved(ctx context.Context, logger *slog.Logger, plugin createdPlugin, image string) (retErr error) {
+	versionDir := filepath.Join(plugin.pluginDir, plugin.newVersion)
+	packageResolved := filepath.Join(versionDir, "Package.resolved")
+
+	// Read the Dockerfile to find the git clone command
+	content, err := os.ReadFile(filepath.Join(versionDir, "Dockerfile"))
+	if err != nil {
+		return fmt.Errorf("failed to read Dockerfile: %w", err)
+	}
+	dockerfile, err := docker.ParseDockerfile(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse Dockerfile: %w", err)
+	}
+	var gitCloneCmd string
+	for _, instruction := range dockerfile.Instructions() {
+		if instruction.Keyword != "RUN" {
+			continue
+		}
+		// Strip the "RUN " prefix
+		_, command, _ := strings.Cut(instruction.Original, " ")
+		if command = strings.TrimSpace(command); strings.HasPrefix(command, "git clone") {
+			gitCloneCmd = command
+			break
+		}
+	}
+	if gitCloneCmd == "" {
+		return errors.New("no 'RUN git clone' command found in Dockerfile")
+	}
+
+	logger.InfoContext(ctx, "resolving Swift package", slog.Any("plugin", plugin), slog.String("image", image))
+
+	// Create a tempdir for cloning the repo
+	tmpDir, err := os.MkdirTemp("", "swift-repo-*")
+	if err != nil {
+		return fmt.Errorf("creating tmp dir: %w", err)
+	}
+	defer func() {
+		retErr = errors.Join(retErr, os.RemoveAll(tmpDir))
+	}()
+
+	// Execute the git clone command, cloning to the tmpDir
+	cmd := exec.CommandContext(ctx, "sh", "-c", gitCloneCmd+" -- "+tmpDir) //nolint:gosec // We control the arguments here.
+	cmd.Dir = versionDir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run git clone: %w", err)
+	}
+
+	// Run `swift package resolve` in the cloned directory
+	if err := runInContainer(ctx, image, tmpDir, nil, nil, "swift", "package", "resolve"); err != nil {
+		return fmt.Errorf("failed to run swift package resolve: %w", err)
+	}
+
+	// Move the Package.resolved file from the cloned directory to the version directory
+	src := filepath.Join(tmpDir, "Package.resolved")
+	dest := packageResolved
+	if err := os.Rename(src, dest); err != nil {
+		return fmt.Errorf("failed to move Package.resolved: %w", err)
+	}
+
+	return nil
+}
+
+// regenerateCargoLock regenerates the Cargo.lock of plugins with a Cargo.toml and Cargo.lock,
+// so the lockfile is resolved with the toolchain building the plugin. It then checks that
+// the registry.cargo deps agree with the lockfile.
+func regenerateCargoLock(ctx context.Context, logger *slog.Logger, plugin createdPlugin, image string) error {
+	versionDir := filepath.Join(plugin.pluginDir, plugin.newVersion)
+	logger.InfoContext(ctx, "regenerating Cargo.lock", slog.Any("plugin", plugin), slog.String("image", image))
+	if err := runInContainer(
+		ctx,
+		image,
+		versionDir,
+		[]string{"CARGO_HOME=/tmp/cargo", "CARGO_REGISTRIES_CRATES_IO_PROTOCOL=sparse"},
+		nil,
+		"cargo", "generate-lockfile",
+	); err != nil {
+		return fmt.Errorf("failed to run cargo generate-lockfile: %w", err)
+	}
+	return cargo.CheckDeps(versionDir)
+}
+
+// dockerfileImage returns the first image of a Dockerfile FROM instruction with the
+// name (such as "rust" for "rust:1.91.1-alpine3.22"), or an empty string.
+func dockerfileImage(dockerfile []byte, name string) string {
+	parsed, err := docker.ParseDockerfile(dockerfile)
+	if err != nil {
+		return ""
+	}
+	for _, stage := range parsed.Stages() {
+		if imageName, _, _ := docker.SplitImage(stage.BaseImage); imageName != "" && path.Base(imageName) == name {
+			return stage.BaseImage
+		}
+	}
+	return ""
+}
+
+// regenerateMavenDeps regenerates the pom.xml from the plugin's buf.plugin.yaml.
+func regenerateMavenDeps(plugin createdPlugin) error {
+	versionDir := filepath.Join(plugin.pluginDir, plugin.newVersion)
+	pluginsDir := filepath.Dir(filepath.Dir(plugin.pluginDir))
+	return maven.RegenerateMavenDeps(versionDir, pluginsDir)
+}
+
+// regenerateNugetDeps regenerates the build.csproj from the plugin's buf.plugin.yaml.
+func regenerateNugetDeps(plugin createdPlugin) error {
+	versionDir := filepath.Join(plugin.pluginDir, plugin.newVersion)
+	pluginsDir := filepath.Dir(filepath.Dir(plugin.pluginDir))
+	return nuget.RegenerateNugetDeps(versionDir, pluginsDir)
+}
+
+// runPluginTests runs 'make test PLUGINS="org/name:v<new>"' in order to generate the plugin.sum file.
+func runPluginTests(ctx context.Context, plugin createdPlugin) error {
+	env := os.Environ()
+	env = append(env, "ALLOW_EMPTY_PLUGIN_SUM=true")
+	cmd := exec.CommandContext(ctx, "make", "test", "PLUGINS="+plugin.String()) //nolint:gosec
+	cmd.Env = env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func goModFileURL(module, version string) string {
+	return fmt.Sprintf("%s/%s/@v/%s.mod", goModProxyURL, module, version)
+}
+
+func fetchGoModVersion(ctx context.Context, client *http.Client, module, version string) (string, error) {
+	reqURL := goModFileURL(module, version)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d fetching go.mod for %s@%s", resp.StatusCode, module, version)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	modFile, err := modfile.ParseLax("go.mod", content, nil)
+	if err != nil {
+		return "", err
+	}
+	if modFile.Go == nil || modFile.Go.Version == "" {
+		return defaultGoModVersion, nil
+	}
+	// Normalize to major.minor (e.g. "1.25.0" → "1.25").
+	normalized := strings.TrimPrefix(semver.MajorMinor("v"+modFile.Go.Version), "v")
+	if normalized == "" {
+		return defaultGoModVersion, nil
+	}
+	return normalized, nil
+}
+
+func updateGoRegistryMinVersion(ctx context.Context, logger *slog.Logger, client *http.Client, plugin createdPlugin) (*goMinVersionBump, error) {
+	versionDir := filepath.Join(plugin.pluginDir, plugin.newVersion)
+	pluginYAMLPath := filepath.Join(versionDir, "buf.plugin.yaml")
+
+	content, err := os.ReadFile(pluginYAMLPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var config bufremotepluginconfig.ExternalConfig
+	if err := encoding.UnmarshalJSONOrYAMLStrict(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse buf.plugin.yaml: %w", err)
+	}
+
+	if config.Registry.Go == nil || len(config.Registry.Go.Deps) == 0 {
+		return nil, nil
+	}
+
+	currentMinVersion := cmp.Or(config.Registry.Go.MinVersion, defaultGoModVersion)
+	maxVersion := currentMinVersion
+	var maxModule, maxModVersion string
+
+	for _, dep := range config.Registry.Go.Deps {
+		goVersion, err := fetchGoModVersion(ctx, client, dep.Module, dep.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch go.mod for %s@%s: %w", dep.Module, dep.Version, err)
+		}
+		if semver.Compare("v"+goVersion, "v"+maxVersion) > 0 {
+			maxVersion = goVersion
+			maxModule = dep.Module
+			maxModVersion = dep.Version
+		}
+	}
+
+	if maxVersion == currentMinVersion {
+		return nil, nil
+	}
+
+	// Use text replacement to preserve formatting and comments.
+	oldStr := fmt.Sprintf(`min_version: "%s"`, currentMinVersion)
+	newStr := fmt.Sprintf(`min_version: "%s"`, maxVersion)
+	newContent := strings.ReplaceAll(string(content), oldStr, newStr)
+
+	if newContent == string(content) {
+		logger.WarnContext(ctx, "could not find min_version to update in buf.plugin.yaml",
+			slog.String("plugin", plugin.String()),
+			slog.String("current", currentMinVersion),
+			slog.String("required", maxVersion))
+		return nil, nil
+	}
+
+	if err := os.WriteFile(pluginYAMLPath, []byte(newContent), 0600); err != nil {
+		return nil, err
+	}
+
+	logger.InfoContext(ctx, "updated registry.go.min_version",
+		slog.String("plugin", plugin.String()),
+		slog.String("old", currentMinVersion),
+		slog.String("new", maxVersion),
+		slog.String("dep", fmt.Sprintf("%s@%s", maxModule, maxModVersion)))
+
+	return &goMinVersionBump{
+		oldVersion: currentMinVersion,
+		newVersion: maxVersion,
+		module:     maxModule,
+		modVersion: maxModVersion,
+	}, nil
+}
+
+// updatePluginDeps updates plugin dependencies in a buf.plugin.yaml file to their latest versions.
+// It parses the YAML content to find deps entries, then uses text replacement to update
+// version references in-place, preserving the original formatting and comments.
+// For example, if the YAML contains:
+//
+//	deps:
+//	  - plugin: buf.build/protocolbuffers/go:v1.30.0
+//
+// and latestVersions maps "buf.build/protocolbuffers/go" to "v1.36.11",
+// the function will update it to:
+//
+//	deps:
+//	  - plugin: buf.build/protocolbuffers/go:v1.36.11
+//
+// It returns the modified content with updated dependency versions, and the dependencies
+// which were updated.
+func updatePluginDeps(ctx context.Context, logger *slog.Logger, content []byte, latestVersions map[string]string) ([]byte, []versionBump, error) {
+	var config bufremotepluginconfig.ExternalConfig
+	if err := encoding.UnmarshalJSONOrYAMLStrict(content, &config); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse buf.plugin.yaml: %w", err)
+	}
+
+	// Check if there are any plugin dependencies
+	if len(config.Deps) == 0 {
+		// No deps, return original content
+		return content, nil, nil
+	}
+
+	// Use text replacement rather than re-marshaling the struct to avoid introducing
+	// empty fields from zero-value nested structs in ExternalConfig.
+	result := string(content)
+	var bumps []versionBump
+	for _, dep := range config.Deps {
+		if dep.Plugin == "" {
+			continue
+		}
+
+		// Parse the plugin reference: buf.build/owner/name:version
+		pluginName, currentVersion, ok := strings.Cut(dep.Plugin, ":")
+		if !ok {
+			continue
+		}
+
+		// Look up the latest version for this plugin
+		latestVersion, exists := latestVersions[pluginName]
+		if !exists || latestVersion == currentVersion {
+			continue
+		}
+
+		oldPluginRef := dep.Plugin
+		newPluginRef := pluginName + ":" + latestVersion
+		logger.InfoContext(ctx, "updating plugin dependency", slog.String("old", oldPluginRef), slog.String("new", newPluginRef))
+		result = strings.ReplaceAll(result, oldPluginRef, newPluginRef)
+		bumps = append(bumps, versionBump{Name: pluginName, Old: currentVersion, New: latestVersion})
+	}
+
+	return []byte(result), bumps, nil
+}
+
+// pluginToCreate represents a plugin that needs a new version created.
+type pluginToCreate struct {
+	pluginDir       string
+	previousVersion string
+	newVersion      string
+	config          *source.Config
+	// provenance is the upstream provenance of newVersion, if resolved.
+	provenance *fetchclient.Provenance
+	// versionsSeen are the versions observed upstream, if known (see VersionsFetcher).
+	versionsSeen []string
+}
+
+type runOption func(*runOptions)
+
+type runOptions struct {
+	// pluginVersionCreateTime returns the time a plugin version directory was created.
+	// The path argument is relative to the repository root.
+	// Defaults to git.FirstCommitTime.
+	pluginVersionCreateTime func(ctx context.Context, path string) (time.Time, error)
+	// checkReadiness returns the reasons the upstream artifacts installed by a pending
+	// plugin version are not available yet, or nil if the version can be created.
+	// Defaults to probing the public registries (see newUpstreamReadinessCheck).
+	checkReadiness func(ctx context.Context, pending *pluginToCreate) ([]string, error)
+	// plan, if set, records the plugin versions which would be created instead of
+	// writing them.
+	plan *fetchPlan
+	// summary, if set, records the outcome of each source.
+	summary *fetchSummary
+}
+
+// withSummary records the outcome of each source in the summary.
+func withSummary(summary *fetchSummary) runOption {
+	return func(o *runOptions) {
+		o.summary = summary
+	}
+}
+
+// withPlan records the plugin versions which would be created in the plan, without
+// writing anything.
+func withPlan(plan *fetchPlan) runOption {
+	return func(o *runOptions) {
+		o.plan = plan
+	}
+}
+
+// withReadinessCheck overrides the checkReadiness function for testing.
+func withReadinessCheck(f func(ctx context.Context, pending *pluginToCreate) ([]string, error)) runOption {
+	return func(o *runOptions) {
+		o.checkReadiness = f
+	}
+}
+
+// withPluginVersionCreateTime overrides the pluginVersionCreateTime function for testing.
+func withPluginVersionCreateTime(f func(ctx context.Context, path string) (time.Time, error)) runOption {
+	return func(o *runOptions) {
+		o.pluginVersionCreateTime = f
+	}
+}
+
+// rootDir returns the absolute path of the repository root: the optional directory
+// argument at index, or the working directory.
+func rootDir(container appext.Container, index int) (string, error) {
+	if container.NumArgs() > index {
+		return filepath.Abs(container.Arg(index))
+	}
+	return os.Getwd()
+}
+
+func run(
+	ctx context.Context,
+	container appext.Container,
+	fetcher Fetcher,
+	f *flags,
+	opts ...runOption,
+) ([]createdPlugin, error) {
+	options := runOptions{
+		pluginVersionCreateTime: git.FirstCommitTime,
+		checkReadiness:          newUpstreamReadinessCheck(fetcher),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	root, err := rootDir(container, 0)
+	if err != nil {
+		return nil, err
+	}
+	logger := container.Logger()
+	now := time.Now()
+	defer func() {
+		logger.InfoContext(ctx, "finished running", slog.Duration("duration", time.Since(now)))
+	}()
+	baseImageDir, err := docker.FindBaseImageDir(root)
+	if err != nil {
+		return nil, err
+	}
+	latestBaseImageVersions, err := docker.LoadLatestBaseImages(baseImageDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load all existing plugins (already sorted in dependency order by plugin.FindAll)
+	pluginsDir := filepath.Join(root, "plugins")
+	allPlugins, err := plugin.FindAll(pluginsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load existing plugins: %w", err)
+	}
+
+	// Build initial map of latest plugin versions
+	latestPluginVersions := make(map[string]string)
+	for _, p := range allPlugins {
+		current := latestPluginVersions[p.Name]
+		if current == "" || semver.Compare(current, p.PluginVersion) < 0 {
+			latestPluginVersions[p.Name] = p.PluginVersion
+		}
+	}
+
+	configs, err := source.GatherConfigs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	pendingCreations, err := fetchPendingCreations(ctx, logger, fetcher, configs, f.include, f.parallelism, options.pluginVersionCreateTime, options.summary)
+	if err != nil {
+		return nil, err
+	}
+
+	created := make([]createdPlugin, 0, len(pendingCreations))
+	processedDirs := make(map[string]bool, len(pendingCreations))
+	// failPending records a pending plugin version which failed, and continues with the
+	// other sources: the errors are reported in the summary.
+	failPending := func(pending *pluginToCreate, message string, err error) {
+		logger.ErrorContext(
+			ctx,
+			message,
+			slog.String("path", fmt.Sprintf("%v/%v", pending.pluginDir, pending.newVersion)),
+			slog.Any("error", err),
+		)
+		reason := message + ": " + err.Error()
+		options.plan.skip(pending, []string{reason})
+		options.summary.recordPending(pending, sourceStatusError, reason)
+		processedDirs[pending.pluginDir] = true
+	}
+	for _, p := range allPlugins {
+		// Extract the plugin directory from the plugin's path
+		// p.Path is the full path to buf.plugin.yaml, directory is two levels up (dir/version/buf.plugin.yaml)
+		// Convert to absolute to match the keys in pendingCreations
+		pluginDir, err := filepath.Abs(filepath.Dir(filepath.Dir(p.Path)))
+		if err != nil {
+			return nil, err
+		}
+
+		// Skip if we've already processed this plugin directory (multiple versions of same plugin)
+		if processedDirs[pluginDir] {
+			continue
+		}
+
+		pending, needsCreation := pendingCreations[pluginDir]
+		if !needsCreation {
+			continue
+		}
+
+		reasons, err := options.checkReadiness(ctx, pending)
+		if err != nil {
+			failPending(pending, "failed to check upstream artifacts", err)
+			continue
+		}
+		if len(reasons) > 0 {
+			// Defer creating the version until a later run, when the artifacts are available.
+			logger.WarnContext(
+				ctx,
+				"deferring plugin version: upstream artifacts are not available yet",
+				slog.String("path", fmt.Sprintf("%v/%v", pending.pluginDir, pending.newVersion)),
+				slog.Any("reasons", reasons),
+			)
+			options.plan.skip(pending, reasons)
+			options.summary.recordPending(pending, sourceStatusSkipped, "upstream artifacts are not available yet: "+strings.Join(reasons, "; "))
+			processedDirs[pluginDir] = true
+			continue
+		}
+
+		var verified []string
+		if verifier, ok := fetcher.(Verifier); ok {
+			verified, err = verifier.Verify(ctx, pending.config, pending.newVersion)
+			if err != nil {
+				// Refuse the version: a later run retries, in case verification
+				// failed because the provenance wasn't published yet.
+				failPending(pending, "upstream provenance could not be verified", err)
+				continue
+			}
+		}
+		if resolver, ok := fetcher.(ProvenanceResolver); ok {
+			pending.provenance, err = resolver.Provenance(ctx, pending.config, pending.newVersion)
+			if err != nil {
+				failPending(pending, "failed to resolve upstream provenance", err)
+				continue
+			}
+			pending.provenance.Verified = verified
+		}
+		if options.plan != nil {
+			files, err := renderPluginVersion(ctx, logger, pending, latestBaseImageVersions, latestPluginVersions)
+			if err != nil {
+				failPending(pending, "failed to render plugin version", err)
+				continue
+			}
+			if err := options.plan.add(root, pending, files); err != nil {
+				failPending(pending, "failed to plan plugin version", err)
+				continue
+			}
+		} else {
+			if err := createPluginDir(ctx, logger, pending, latestBaseImageVersions, latestPluginVersions); err != nil {
+				failPending(pending, "failed to create plugin version", err)
+				continue
+			}
+			logger.InfoContext(ctx, "created", slog.String("path", fmt.Sprintf("%v/%v", pending.pluginDir, pending.newVersion)))
+		}
+		var changes *fetchclient.Changes
+		if resolver, ok := fetcher.(ChangesResolver); ok && options.plan == nil {
+			changes, err = resolver.Changes(ctx, pending.config, pending.previousVersion, pending.newVersion)
+			if err != nil {
+				// The changes only inform reviewers: the PR body omits them.
+				logger.WarnContext(
+					ctx,
+					"failed to resolve upstream changes",
+					slog.String("path", fmt.Sprintf("%v/%v", pending.pluginDir, pending.newVersion)),
+					slog.Any("error", err),
+				)
+			}
+		}
+
+		// Mark this directory as processed
+		processedDirs[pluginDir] = true
+		options.summary.recordPending(pending, sourceStatusCreated, "")
+
+		// Update latestPluginVersions so subsequent plugins in this run can reference this new version
+		latestPluginVersions[p.Name] = pending.newVersion
+
+		created = append(created, createdPlugin{
+			org:             filepath.Base(filepath.Dir(pending.pluginDir)),
+			name:            filepath.Base(pending.pluginDir),
+			pluginDir:       pending.pluginDir,
+			previousVersion: pending.previousVersion,
+			newVersion:      pending.newVersion,
+			source:          pending.config.CacheKey(),
+			postProcess:     pending.config.PostProcess,
+			changes:         changes,
+		})
+	}
+	return created, nil
+}
+
+// newUpstreamReadinessCheck returns a readiness check which probes the upstream artifacts
+// installed by a pending plugin version (see readiness.FindArtifacts), using the prober
+// of the fetcher if it implements ReadinessProber.
+func newUpstreamReadinessCheck(fetcher Fetcher) func(ctx context.Context, pending *pluginToCreate) ([]string, error) {
+	return func(ctx context.Context, pending *pluginToCreate) ([]string, error) {
+		prober := readiness.NewProber(http.DefaultClient)
+		if readinessProber, ok := fetcher.(ReadinessProber); ok {
+			var err error
+			prober, err = readinessProber.Prober(pending.config)
+			if err != nil {
+				return nil, err
+			}
+		}
+		artifacts, err := readiness.FindArtifacts(
+			filepath.Join(pending.pluginDir, pending.previousVersion),
+			newSubstituter(pending),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find upstream artifacts for %s: %w", pending.pluginDir, err)
+		}
+		return prober.Check(ctx, artifacts), nil
+	}
+}
+
+// fetchPendingCreations iterates over source configs, fetches the latest
+// version for each enabled plugin, and returns a map of plugin directories
+// that need a new version created. Sources are fetched concurrently (up to
+// parallelism at a time), and configs sharing a cache key are fetched once.
+func fetchPendingCreations(
+	ctx context.Context,
+	logger *slog.Logger,
+	fetcher Fetcher,
+	configs []*source.Config,
+	includes []string,
+	parallelism int,
+	versionTime func(ctx context.Context, path string) (time.Time, error),
+	summary *fetchSummary,
+) (map[string]*pluginToCreate, error) {
+	filter := newPluginFilter(includes)
+	var toFetch []*source.Config
+	for _, config := range configs {
+		configDir := filepath.Dir(config.Filename)
+		pluginName := filepath.Base(configDir)
+		pluginOrg := filepath.Base(filepath.Dir(configDir))
+		if !filter.includes(pluginOrg, pluginName) {
+			logger.DebugContext(ctx, "skipping source (not in --include list)", slog.String("filename", config.Filename))
+			continue
+		}
+		if config.Source.Disabled {
+			logger.InfoContext(ctx, "skipping source", slog.String("filename", config.Filename))
+			summary.record(configDir, sourceSummary{Source: config.CacheKey(), Status: sourceStatusSkipped, Reason: "disabled"})
+			continue
+		}
+		if config.Source.UpdateFrequency != nil {
+			nextUpdate, err := nextUpdateTime(ctx, logger, config, versionTime)
+			if err != nil {
+				logger.ErrorContext(ctx, "failed to check update frequency", slog.String("filename", config.Filename), slog.Any("error", err))
+				summary.record(configDir, sourceSummary{Source: config.CacheKey(), Status: sourceStatusError, Reason: err.Error()})
+				continue
+			}
+			if !nextUpdate.IsZero() {
+				summary.record(configDir, sourceSummary{
+					Source: config.CacheKey(),
+					Status: sourceStatusSkipped,
+					Reason: "update_frequency not reached until " + nextUpdate.UTC().Format(time.DateOnly),
+				})
+				continue
+			}
+		}
+		toFetch = append(toFetch, config)
+	}
+	results := fetchLatestVersions(ctx, fetcher, toFetch, parallelism)
+
+	pendingCreations := make(map[string]*pluginToCreate)
+	for _, config := range toFetch {
+		// Convert to absolute path to match plugin.Walk behavior (which converts paths via filepath.Abs)
+		pluginDir, err := filepath.Abs(filepath.Dir(config.Filename))
+		if err != nil {
+			return nil, err
+		}
+		entry := sourceSummary{Source: config.CacheKey(), Status: sourceStatusError}
+		result := results[config.CacheKey()]
+		entry.VersionsSeen = result.versions
+		if entry.CurrentVersion, err = getLatestVersionFromDir(pluginDir); err != nil && !errors.Is(err, errNoVersions) {
+			// Continue with the other sources: the errors are reported in the summary.
+			logger.ErrorContext(ctx, "failed to get latest known version", slog.String("dir", pluginDir), slog.Any("error", err))
+			entry.Reason = "failed to get latest known version: " + err.Error()
+			summary.record(pluginDir, entry)
+			continue
+		}
+		if result.err != nil {
+			if errors.Is(result.err, fetchclient.ErrSemverPrerelease) {
+				logger.InfoContext(ctx, "skipping source", slog.String("filename", config.Filename), slog.Any("error", result.err))
+				entry.Status, entry.Reason = sourceStatusSkipped, result.err.Error()
+			} else {
+				// Continue with the other sources: the errors are reported in the summary.
+				logger.ErrorContext(ctx, "failed to fetch source", slog.String("filename", config.Filename), slog.Any("error", result.err))
+				entry.Reason = result.err.Error()
+			}
+			summary.record(pluginDir, entry)
+			continue
+		}
+		newVersion := result.version
+		entry.LatestVersion = newVersion
+		// Some plugins share the same source but specify different ignore versions.
+		// Ensure we continue to only fetch the latest version once but still respect ignores.
+		if slices.Contains(config.Source.IgnoreVersions, newVersion) {
+			logger.InfoContext(ctx, "skipping source", slog.String("filename", config.Filename), slog.String("version", newVersion))
+			entry.Status, entry.Reason = sourceStatusSkipped, "ignore_versions contains "+newVersion
+			summary.record(pluginDir, entry)
+			continue
+		}
+		ok, err := checkDirExists(filepath.Join(pluginDir, newVersion))
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to check plugin version", slog.String("dir", pluginDir), slog.Any("error", err))
+			entry.Reason = "failed to check plugin version: " + err.Error()
+			summary.record(pluginDir, entry)
+			continue
+		}
+		if ok {
+			entry.Status = sourceStatusUpToDate
+			summary.record(pluginDir, entry)
+			continue
+		}
+		if entry.CurrentVersion == "" {
+			logger.ErrorContext(ctx, "failed to get latest known version", slog.String("dir", pluginDir), slog.Any("error", errNoVersions))
+			entry.Reason = "failed to get latest known version: " + errNoVersions.Error()
+			summary.record(pluginDir, entry)
+			continue
+		}
+
+		pendingCreations[pluginDir] = &pluginToCreate{
+			pluginDir:       pluginDir,
+			previousVersion: entry.CurrentVersion,
+			newVersion:      newVersion,
+			config:          config,
+			versionsSeen:    result.versions,
+		}
+	}
+	return pendingCreations, nil
+}
+
+// fetchResult is the outcome of fetching the latest version of a source.
+type fetchResult struct {
+	version string
+	// versions are the versions observed upstream, if known (see VersionsFetcher).
+	versions []string
+	err      error
+}
+
+// fetchLatestVersions fetches the latest version of each unique source (by cache key)
+// using a bounded pool of workers. Errors are recorded per cache key rather than
+// cancelling other fetches, so callers can report them in config order.
+func fetchLatestVersions(
+	ctx context.Context,
+	fetcher Fetcher,
+	configs []*source.Config,
+	parallelism int,
+) map[string]fetchResult {
+	var (
+		mu      sync.Mutex
+		results = make(map[string]fetchResult, len(configs))
+		seen    = make(map[string]struct{}, len(configs))
+	)
+	var eg errgroup.Group
+	eg.SetLimit(max(parallelism, 1))
+	for _, config := range configs {
+		cacheKey := config.CacheKey()
+		if _, ok := seen[cacheKey]; ok {
+			continue
+		}
+		seen[cacheKey] = struct{}{}
+		eg.Go(func() error {
+			var result fetchResult
+			if versionsFetcher, ok := fetcher.(VersionsFetcher); ok {
+				result.version, result.versions, result.err = versionsFetcher.FetchVersions(ctx, config)
+			} else {
+				result.version, result.err = fetcher.Fetch(ctx, config)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			results[cacheKey] = result
+			return nil
+		})
+	}
+	_ = eg.Wait()
+	return results
+}
+
+// nextUpdateTime returns the time the source can next be updated according to its
+// update_frequency, or the zero time if it can be updated now.
+func nextUpdateTime(
+	ctx context.Context,
+	logger *slog.Logger,
+	config *source.Config,
+	versionTime func(ctx context.Context, path string) (time.Time, error),
+) (time.Time, error) {
+	configDir := filepath.Dir(config.Filename)
+	latestVersion, err := getLatestVersionFromDir(configDir)
+	if err != nil {
+		if errors.Is(err, errNoVersions) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	versionPath := filepath.Join(configDir, latestVersion)
+	createTime, err := versionTime(ctx, versionPath)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get create time for %s: %w", versionPath, err)
+	}
+	if createTime.IsZero() {
+		return time.Time{}, nil
+	}
+	freq := time.Duration(*config.Source.UpdateFrequency)
+	nextUpdate := createTime.Add(freq)
+	if time.Now().Before(nextUpdate) {
+		logger.InfoContext(ctx, "skipping source (update frequency not reached)",
+			slog.String("filename", config.Filename),
+			slog.String("last_updated", createTime.UTC().Format(time.DateOnly)),
+			slog.String("next_update", nextUpdate.UTC().Format(time.DateOnly)),
+		)
+		return nextUpdate, nil
+	}
+	return time.Time{}, nil
+}
+
+// renderedFile is a file of a new plugin version, rendered from the previous version.
+type renderedFile struct {
+	// name is the slash-separated path of the file, relative to the version directory.
+	name    string
+	content []byte
+	mode    fs.FileMode
+	// dependencyBumps are the plugin dependencies updated to their latest version.
+	dependencyBumps []versionBump
+	// baseImageBumps are the base images updated to their latest version.
+	baseImageBumps []versionBump
+}
+
+// renderDirectory renders the files of the source directory and its subdirectories for
+// a new plugin version, preserving their modes. Files under a vendored directory are
+// copied unchanged, and version control directories are not copied. Symbolic links
+// and other special files are not supported.
+func renderDirectory(
+	ctx context.Context,
+	logger *slog.Logger,
+	source string,
+	target string,
+	substituter *substitute.Substituter,
+	latestBaseImages *docker.BaseImages,
+	latestPluginVersions map[string]string,
+) ([]*renderedFile, error) {
+	var files []*renderedFile
+	if err := filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if _, ok := skippedDirNames[d.Name()]; ok && path != source {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("failed to copy %s: only regular files and directories are supported", path)
+		}
+		if relativePath == provenanceFilename {
+			// Provenance is specific to each version and is never copied.
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var rendered *renderedFile
+		if isVendored(relativePath) {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			rendered = &renderedFile{content: content}
+		} else {
+			rendered, err = renderFile(
+				ctx,
+				logger,
+				path,
+				filepath.Join(target, relativePath),
+				substituter,
+				latestBaseImages,
+				latestPluginVersions,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		rendered.name = filepath.ToSlash(relativePath)
+		rendered.mode = info.Mode().Perm()
+		files = append(files, rendered)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// isVendored returns true if the relative path of a plugin version file is under a
+// vendored directory.
+func isVendored(relativePath string) bool {
+	for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(relativePath)), "/") {
+		if _, ok := vendoredDirNames[dir]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// renderPluginVersion renders the files of a pending plugin version: the files of the
+// previous version, and its provenance (if resolved).
+func renderPluginVersion(
+	ctx context.Context,
+	logger *slog.Logger,
+	pending *pluginToCreate,
+	latestBaseImages *docker.BaseImages,
+	latestPluginVersions map[string]string,
+) ([]*renderedFile, error) {
+	files, err := renderDirectory(
+		ctx,
+		logger,
+		filepath.Join(pending.pluginDir, pending.previousVersion),
+		filepath.Join(pending.pluginDir, pending.newVersion),
+		newSubstituter(pending),
+		latestBaseImages,
+		latestPluginVersions,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if pending.provenance == nil {
+		return files, nil
+	}
+	content, err := json.MarshalIndent(pending.provenance, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(files, &renderedFile{name: provenanceFilename, content: append(content, '\n'), mode: 0644}), nil
+}
+
+func createPluginDir(
+	ctx context.Context,
+	logger *slog.Logger,
+	pending *pluginToCreate,
+	latestBaseImages *docker.BaseImages,
+	latestPluginVersions map[string]string,
+) (retErr error) {
+	files, err := renderPluginVersion(ctx, logger, pending, latestBaseImages, latestPluginVersions)
+	if err != nil {
+		return err
+	}
+	versionDir := filepath.Join(pending.pluginDir, pending.newVersion)
+	if err := os.Mkdir(versionDir, 0755); err != nil {
+		return err
+	}
+	defer func() {
+		if retErr != nil {
+			retErr = errors.Join(retErr, os.RemoveAll(versionDir))
+		}
+	}()
+	for _, file := range files {
+		path := filepath.Join(versionDir, filepath.FromSlash(file.name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, file.content, file.mode); err != nil {
+			return err
+		}
+		// The file mode passed to WriteFile is subject to the umask.
+		if err := os.Chmod(path, file.mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newSubstituter returns the substituter rewriting the previous version of a pending
+// plugin version in its files.
+func newSubstituter(pending *pluginToCreate) *substitute.Substituter {
+	var upstreamNames []string
+	if pending.config != nil {
+		upstreamNames = substitute.UpstreamNames(pending.config.Source)
+	}
+	return substitute.New(pending.previousVersion, pending.newVersion, upstreamNames)
+}
+
+// renderFile renders the file src of a previous plugin version as the file dest of
+// the new version.
+func renderFile(
+	ctx context.Context,
+	logger *slog.Logger,
+	src string,
+	dest string,
+	substituter *substitute.Substituter,
+	latestBaseImages *docker.BaseImages,
+	latestPluginVersions map[string]string,
+) (*renderedFile, error) {
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+	filename := filepath.Base(dest)
+	rendered := &renderedFile{}
+	if filename == "buf.plugin.yaml" {
+		// Update plugin dependencies to latest versions
+		content, rendered.dependencyBumps, err = updatePluginDeps(ctx, logger, content, latestPluginVersions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update plugin deps: %w", err)
+		}
+	}
+	result, err := substituter.File(filename, content)
+	if err != nil {
+		return nil, err
+	}
+	for _, substitution := range result.Substitutions {
+		logger.InfoContext(
+			ctx,
+			"substituted version",
+			slog.String("file", dest),
+			slog.Int("line", substitution.Line),
+			slog.String("old", substitution.Old),
+			slog.String("new", substitution.New),
+			slog.String("rule", substitution.Rule),
+		)
+	}
+	for _, occurrence := range result.Unchanged {
+		logger.WarnContext(
+			ctx,
+			"previous version left unchanged: add a "+substitute.Marker+" comment to substitute it",
+			slog.String("file", dest),
+			slog.Int("line", occurrence.Line),
+			slog.String("text", occurrence.Text),
+		)
+	}
+	rendered.content = result.Content
+	if strings.HasPrefix(filename, "Dockerfile") {
+		rendered.content, rendered.baseImageBumps, err = updateDockerfileBaseImages(rendered.content, latestBaseImages)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rendered, nil
+}
+
+// updateDockerfileBaseImages replaces the FROM images of a Dockerfile with the latest
+// base images, and its syntax directive with the latest Dockerfile frontend. It returns
+// the modified content and the base images which were updated.
+func updateDockerfileBaseImages(content []byte, latestBaseImages *docker.BaseImages) ([]byte, []versionBump, error) {
+	content, updates, err := docker.UpdateBaseImages(content, latestBaseImages)
+	if err != nil {
+		return nil, nil, err
+	}
+	var bumps []versionBump
+	for _, update := range updates {
+		bumps = append(bumps, versionBump{Name: update.Name, Old: update.Old, New: update.New})
+	}
+	return content, bumps, nil
+}
+
+func getLatestVersionFromDir(basedir string) (string, error) {
+	entries, err := os.ReadDir(basedir)
+	if err != nil {
+		return "", err
+	}
+	var versions []string
+	for _, entry := range entries {
+		if entry.IsDir() && semver.IsValid(entry.Name()) {
+			versions = append(versions, entry.Name())
+		}
+	}
+	if len(versions) == 0 {
+		return "", errNoVersions
+	}
+	semver.Sort(versions)
+	return versions[len(versions)-1], nil
+}
+
+// pluginGroupName returns the display name used to group a plugin in the PR title and body.
+// Community plugins use their plugin name (e.g. "mercari-grpc-federation") since "community"
+// is not a meaningful org name. All other plugins use their org name.
+func pluginGroupName(p createdPlugin) string {
+	if p.org == communityOrg {
+		return p.name
+	}
+	return p.org
+}
+
+// generatePRTitle generates a PR title summarising which plugins were updated.
+// When 1 or 2 plugins are updated, the full org/name is used for clarity.
+// Community plugins omit the "community" org prefix since the plugin name is self-descriptive.
+// For 3 or more, plugins are grouped by org (or plugin name for community plugins).
+// Examples:
+//
+//	"Update grpc/swift"
+//	"Update grpc/swift and connectrpc/go"
+//	"Update mercari-grpc-federation"
+//	"Update protocolbuffers, grpc, and mercari-grpc-federation"
+func generatePRTitle(created []createdPlugin) string {
+	if len(created) == 0 {
+		return "Found new plugin versions"
+	}
+	if len(created) <= 2 {
+		names := make([]string, len(created))
+		for i, p := range created {
+			if p.org == communityOrg {
+				names[i] = p.name
+			} else {
+				names[i] = p.org + "/" + p.name
+			}
+		}
+		if len(names) == 1 {
+			return "Update " + names[0]
+		}
+		return "Update " + names[0] + " and " + names[1]
+	}
+	seen := make(map[string]struct{})
+	var names []string
+	for _, p := range created {
+		name := pluginGroupName(p)
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+	}
+	switch len(names) {
+	case 1:
+		return "Update " + names[0]
+	case 2:
+		return "Update " + names[0] + " and " + names[1]
+	default:
+		return "Update " + strings.Join(names[:len(names)-1], ", ") + ", and " + names[len(names)-1]
+	}
+}
+
+const (
+	// maxReleaseNotesExcerpt is the maximum length of the upstream release notes
+	// excerpted for each plugin in the PR body.
+	maxReleaseNotesExcerpt = 2000
+	// collapsePRBodyLength is the PR body length above which each group of plugins is
+	// collapsed into a details section.
+	collapsePRBodyLength = 5000
+	// maxPRBodyLength leaves room under GitHub's limit of 65536 characters. Longer
+	// bodies omit the release notes excerpts.
+	maxPRBodyLength = 60000
+)
+
+// generatePRBody generates a markdown PR body grouping updated plugins by org,
+// with community plugins each getting their own section. Each plugin links to its
+// upstream changes, if resolved, and flags major version and Go min version bumps.
+// Release notes are excerpted in details sections (once per release), and groups are
+// collapsed into details sections if the body is long.
+// Example:
+//
+//	### protocolbuffers
+//	- go: v1.36.11 → v1.37.0
+//	  - [Compare v1.36.11...v1.37.0](https://github.com/protocolbuffers/protobuf-go/compare/v1.36.11...v1.37.0)
+//	  - [Release notes](https://github.com/protocolbuffers/protobuf-go/releases/tag/v1.37.0)
+//
+//	  <details><summary>Release notes excerpt</summary>
+//
+//	  ...
+//
+//	  </details>
+//	- java: v4.28.3 → v5.29.0 ⚠️ **major version bump**
+//	  - [Registry page](https://central.sonatype.com/artifact/com.google.protobuf/protoc/5.29.0)
+//
+//	### mercari-grpc-federation
+//	- v1.0.0 → v1.1.0
+func generatePRBody(created []createdPlugin) string {
+	if len(created) == 0 {
+		return ""
+	}
+	body := writePRBody(created, true, false)
+	if len(body) > collapsePRBodyLength {
+		body = writePRBody(created, true, true)
+	}
+	if len(body) > maxPRBodyLength {
+		body = writePRBody(created, false, true)
+	}
+	return body
+}
+
+// writePRBody writes the PR body of generatePRBody, with or without release notes
+// excerpts and with or without collapsing each group.
+func writePRBody(created []createdPlugin, releaseNotes bool, collapse bool) string {
+	type pluginGroup struct {
+		name    string
+		plugins []createdPlugin
+	}
+	seenIdx := make(map[string]int)
+	var groups []pluginGroup
+	for _, p := range created {
+		groupName := pluginGroupName(p)
+		if idx, ok := seenIdx[groupName]; ok {
+			groups[idx].plugins = append(groups[idx].plugins, p)
+		} else {
+			seenIdx[groupName] = len(groups)
+			groups = append(groups, pluginGroup{name: groupName, plugins: []createdPlugin{p}})
+		}
+	}
+	// Plugins sharing an upstream release (such as protocolbuffers) excerpt its notes once.
+	excerpted := make(map[string]struct{})
+	var sb strings.Builder
+	for i, g := range groups {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		if collapse {
+			summary := fmt.Sprintf("<b>%s</b>: %d plugin", g.name, len(g.plugins))
+			if len(g.plugins) > 1 {
+				summary += "s"
+			}
+			if slices.ContainsFunc(g.plugins, func(p createdPlugin) bool { return isMajorBump(p) || p.goMinVersionBump != nil }) {
+				summary += " ⚠️"
+			}
+			fmt.Fprintf(&sb, "<details><summary>%s</summary>\n\n", summary)
+		} else {
+			fmt.Fprintf(&sb, "### %s\n", g.name)
+		}
+		for _, p := range g.plugins {
+			if p.org == communityOrg {
+				fmt.Fprintf(&sb, "- %s → %s", p.previousVersion, p.newVersion)
+			} else {
+				fmt.Fprintf(&sb, "- %s: %s → %s", p.name, p.previousVersion, p.newVersion)
+			}
+			if isMajorBump(p) {
+				sb.WriteString(" ⚠️ **major version bump**")
+			}
+			sb.WriteString("\n")
+			writePluginChanges(&sb, p, releaseNotes, excerpted)
+		}
+		if collapse {
+			sb.WriteString("\n</details>\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// writePluginChanges writes the sub-items of a plugin in the PR body: links to its
+// upstream changes, its Go min version bump, and an excerpt of its release notes
+// unless already excerpted for another plugin.
+func writePluginChanges(sb *strings.Builder, p createdPlugin, releaseNotes bool, excerpted map[string]struct{}) {
+	changes := p.changes
+	if changes == nil {
+		changes = &fetchclient.Changes{}
+	}
+	if changes.CompareURL != "" {
+		fmt.Fprintf(sb, "  - [Compare %s...%s](%s)\n", changes.PreviousUpstream, changes.NewUpstream, changes.CompareURL)
+	}
+	if changes.ReleaseURL != "" {
+		fmt.Fprintf(sb, "  - [Release notes](%s)\n", changes.ReleaseURL)
+	}
+	if changes.RegistryURL != "" {
+		fmt.Fprintf(sb, "  - [Registry page](%s)\n", changes.RegistryURL)
+	}
+	if p.goMinVersionBump != nil {
+		goModURL := goModFileURL(p.goMinVersionBump.module, p.goMinVersionBump.modVersion)
+		fmt.Fprintf(sb, "  - ⚠️ registry.go.min_version bumped: %s → %s (required by [%s@%s go.mod](%s))\n",
+			p.goMinVersionBump.oldVersion, p.goMinVersionBump.newVersion,
+			p.goMinVersionBump.module, p.goMinVersionBump.modVersion,
+			goModURL)
+	}
+	notes := releaseNotesExcerpt(changes.ReleaseNotes)
+	if !releaseNotes || notes == "" {
+		return
+	}
+	if _, ok := excerpted[changes.ReleaseURL]; ok {
+		return
+	}
+	excerpted[changes.ReleaseURL] = struct{}{}
+	// Indented to continue the list item.
+	sb.WriteString("\n  <details><summary>Release notes excerpt</summary>\n\n")
+	for line := range strings.Lines(notes + "\n") {
+		if strings.TrimSpace(line) == "" {
+			sb.WriteString("\n")
+		} else {
+			sb.WriteString("  " + line)
+		}
+	}
+	sb.WriteString("\n  </details>\n")
+}
+
+// releaseNotesExcerpt returns the release notes, truncated at a line break to at most
+// maxReleaseNotesExcerpt bytes.
+func releaseNotesExcerpt(notes string) string {
+	notes = strings.TrimSpace(strings.ReplaceAll(notes, "\r\n", "\n"))
+	if len(notes) <= maxReleaseNotesExcerpt {
+		return notes
+	}
+	excerpt := notes[:maxReleaseNotesExcerpt]
+	if i := strings.LastIndexByte(excerpt, '\n'); i > 0 {
+		excerpt = excerpt[:i]
+	} else {
+		for !utf8.RuneStart(notes[len(excerpt)]) {
+			excerpt = excerpt[:len(excerpt)-1]
+		}
+	}
+	return strings.TrimSpace(excerpt) + "\n\n…"
+}
+
+// isMajorBump returns true if the plugin's new version has another major version.
+func isMajorBump(p createdPlugin) bool {
+	return semver.IsValid(p.previousVersion) && semver.IsValid(p.newVersion) &&
+		semver.Major(p.previousVersion) != semver.Major(p.newVersion)
+}
+
+// writeGitHubOutput writes a key/value output to the GITHUB_OUTPUT file, if set.
+func writeGitHubOutput(key, value string) error {
+	outputFile := os.Getenv("GITHUB_OUTPUT")
+	if outputFile == "" {
+		return nil
+	}
+	output, err := formatGitHubOutput(key, value)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(output)
+	return err
+}
+
+// formatGitHubOutput formats a key/value output for the GITHUB_OUTPUT file.
+// Multi-line values use the heredoc format required by GitHub Actions, with a random
+// delimiter: values such as PR bodies include upstream release notes, which could
+// otherwise end the value early and inject other outputs.
+func formatGitHubOutput(key, value string) (string, error) {
+	if !strings.Contains(value, "\n") {
+		return fmt.Sprintf("%s=%s\n", key, value), nil
+	}
+	var random [16]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return "", err
+	}
+	delimiter := "ghadelimiter_" + hex.EncodeToString(random[:])
+	if strings.Contains(value, delimiter) {
+		return "", fmt.Errorf("output %s contains the delimiter %s", key, delimiter)
+	}
+	return fmt.Sprintf("%s<<%s\n%s\n%s\n", key, delimiter, strings.TrimRight(value, "\n"), delimiter), nil
+}
+
+func checkDirExists(dir string) (bool, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !info.IsDir() {
+		return false, fmt.Errorf("expecting directory: %q", dir)
+	}
+	return true, nil
+}
@@ -1,13 +1,18 @@
-package main
+package fetcher
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -18,6 +23,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/bufbuild/plugins/internal/fetchclient"
 	"github.com/bufbuild/plugins/internal/source"
 )
 
@@ -129,8 +135,9 @@ registry:
 			"buf.build/protocolbuffers/go": "v1.36.11",
 		}
 		logger := slog.New(slog.NewTextHandler(testWriter{t}, &slog.HandlerOptions{Level: slog.LevelDebug}))
-		result, err := updatePluginDeps(t.Context(), logger, []byte(input), latestVersions)
+		result, bumps, err := updatePluginDeps(t.Context(), logger, []byte(input), latestVersions)
 		require.NoError(t, err)
+		assert.Equal(t, []versionBump{{Name: "buf.build/protocolbuffers/go", Old: "v1.35.0", New: "v1.36.11"}}, bumps)
 
 		output := string(result)
 		// Dep version should be updated.
@@ -148,7 +155,7 @@ registry:
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 			logger := slog.New(slog.NewTextHandler(testWriter{t}, &slog.HandlerOptions{Level: slog.LevelDebug}))
-			result, err := updatePluginDeps(t.Context(), logger, []byte(tt.input), tt.latestVersions)
+			result, _, err := updatePluginDeps(t.Context(), logger, []byte(tt.input), tt.latestVersions)
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
@@ -234,6 +241,213 @@ func TestRunDependencyOrdering(t *testing.T) {
 		"consumer should reference newly created base-plugin v2.0.0, not the old v1.0.0")
 }
 
+func TestRunWritesProvenance(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	tmpDir := t.TempDir()
+	setupTestRepository(t, tmpDir)
+	// A previous version's provenance must not be copied to the new version.
+	basePluginDir := filepath.Join(tmpDir, "plugins", "test", "base-plugin")
+	require.NoError(t, os.WriteFile(filepath.Join(basePluginDir, "v1.0.0", provenanceFilename), []byte(`{"commit":"old"}`), 0644))
+
+	fetcher := &provenanceFetcher{
+		mockFetcher: mockFetcher{
+			versions: map[string]string{
+				"github-test-base-plugin":     "v2.0.0",
+				"github-test-consumer-plugin": "v2.0.0",
+			},
+		},
+	}
+	container := newTestContainer(t, tmpDir)
+	created, err := run(ctx, container, fetcher, &flags{})
+	require.NoError(t, err)
+	require.Len(t, created, 2)
+
+	for _, name := range []string{"base-plugin", "consumer-plugin"} {
+		content, err := os.ReadFile(filepath.Join(tmpDir, "plugins", "test", name, "v2.0.0", provenanceFilename))
+		require.NoError(t, err)
+		var provenance fetchclient.Provenance
+		require.NoError(t, json.Unmarshal(content, &provenance))
+		assert.Equal(t, fetchclient.Provenance{Source: "github", Version: "v2.0.0", Commit: "test/" + name + "@v2.0.0"}, provenance)
+	}
+}
+
+func TestRunCopiesNestedDirectories(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	tmpDir := t.TempDir()
+	setupTestRepository(t, tmpDir)
+	previousDir := filepath.Join(tmpDir, "plugins", "test", "base-plugin", "v1.0.0")
+	for path, content := range map[string]string{
+		".dockerignore":                 "*\n!patches/\n",
+		"patches/fix.patch":             "# fetcher:version\n--- a/base-plugin-1.0.0/main.go\n",
+		".config/settings.toml":         "version = \"1.0.0\"\n",
+		"vendor/base-plugin/Dockerfile": "RUN go install github.com/test/base-plugin@v1.0.0\n",
+		".git/HEAD":                     "ref: refs/heads/main\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(previousDir, path)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(previousDir, path), []byte(content), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(previousDir, "build.sh"), []byte("#!/bin/sh\n"), 0755))
+
+	fetcher := &mockFetcher{versions: map[string]string{"github-test-base-plugin": "v2.0.0"}}
+	container := newTestContainer(t, tmpDir)
+	_, err := run(ctx, container, fetcher, &flags{include: []string{"test/base-plugin"}})
+	require.NoError(t, err)
+
+	newDir := filepath.Join(tmpDir, "plugins", "test", "base-plugin", "v2.0.0")
+	for path, expected := range map[string]string{
+		".dockerignore": "*\n!patches/\n",
+		// Subdirectories are rewritten with the same rules.
+		"patches/fix.patch":     "# fetcher:version\n--- a/base-plugin-2.0.0/main.go\n",
+		".config/settings.toml": "version = \"1.0.0\"\n",
+		// Vendored files are copied unchanged.
+		"vendor/base-plugin/Dockerfile": "RUN go install github.com/test/base-plugin@v1.0.0\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(newDir, path))
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(content), path)
+	}
+	info, err := os.Stat(filepath.Join(newDir, "build.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0755), info.Mode().Perm())
+	_, err = os.Stat(filepath.Join(newDir, ".git"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestRunProvenanceError(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	tmpDir := t.TempDir()
+	setupTestRepository(t, tmpDir)
+
+	fetcher := &provenanceFetcher{
+		mockFetcher: mockFetcher{versions: map[string]string{"github-test-base-plugin": "v2.0.0"}},
+		err:         errors.New("no commit found"),
+	}
+	container := newTestContainer(t, tmpDir)
+	summary := &fetchSummary{}
+	created, err := run(ctx, container, fetcher, &flags{include: []string{"test/base-plugin"}}, withSummary(summary))
+	require.NoError(t, err)
+	assert.Empty(t, created)
+	// The version directory is not created when its provenance can't be resolved.
+	ok, err := checkDirExists(filepath.Join(tmpDir, "plugins", "test", "base-plugin", "v2.0.0"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	// The source is reported as failed, counting against the error thresholds.
+	require.Len(t, summary.Sources, 1)
+	assert.Equal(t, sourceStatusError, summary.Sources[0].Status)
+	assert.Equal(t, "failed to resolve upstream provenance: no commit found", summary.Sources[0].Reason)
+	require.EqualError(t, summary.checkErrorThresholds(0, 1), "1 sources failed, more than --max-source-errors=0")
+}
+
+func TestRunDefersUnreadyVersions(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	tmpDir := t.TempDir()
+	setupTestRepository(t, tmpDir)
+
+	fetcher := &mockFetcher{
+		versions: map[string]string{
+			"github-test-base-plugin":     "v2.0.0",
+			"github-test-consumer-plugin": "v2.0.0",
+		},
+	}
+	container := newTestContainer(t, tmpDir)
+	created, err := run(ctx, container, fetcher, &flags{}, withReadinessCheck(func(_ context.Context, pending *pluginToCreate) ([]string, error) {
+		if filepath.Base(pending.pluginDir) == "base-plugin" {
+			return []string{"go example.com/base@v2.0.0 (Dockerfile): not found"}, nil
+		}
+		return nil, nil
+	}))
+	require.NoError(t, err)
+
+	// Only the consumer is created; the base plugin is deferred to a later run.
+	require.Len(t, created, 1)
+	assert.Equal(t, "consumer-plugin", created[0].name)
+	ok, err := checkDirExists(filepath.Join(tmpDir, "plugins", "test", "base-plugin", "v2.0.0"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+}
+
+func TestRunReadinessCheckError(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	tmpDir := t.TempDir()
+	setupTestRepository(t, tmpDir)
+
+	fetcher := &mockFetcher{
+		versions: map[string]string{
+			"github-test-base-plugin":     "v2.0.0",
+			"github-test-consumer-plugin": "v2.0.0",
+		},
+	}
+	container := newTestContainer(t, tmpDir)
+	summary := &fetchSummary{}
+	created, err := run(ctx, container, fetcher, &flags{}, withSummary(summary), withReadinessCheck(func(_ context.Context, pending *pluginToCreate) ([]string, error) {
+		if filepath.Base(pending.pluginDir) == "base-plugin" {
+			return nil, errors.New("unauthorized")
+		}
+		return nil, nil
+	}))
+	require.NoError(t, err)
+
+	// The consumer is still created when the readiness check of the base plugin fails.
+	require.Len(t, created, 1)
+	assert.Equal(t, "consumer-plugin", created[0].name)
+	ok, err := checkDirExists(filepath.Join(tmpDir, "plugins", "test", "base-plugin", "v2.0.0"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	statuses := make(map[string]string, len(summary.Sources))
+	for _, entry := range summary.Sources {
+		statuses[entry.Plugin] = entry.Status
+		if entry.Plugin == "test/base-plugin" {
+			assert.Equal(t, "failed to check upstream artifacts: unauthorized", entry.Reason)
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"test/base-plugin":     sourceStatusError,
+		"test/consumer-plugin": sourceStatusCreated,
+	}, statuses)
+}
+
+func TestRunRefusesUnverifiedVersions(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	tmpDir := t.TempDir()
+	setupTestRepository(t, tmpDir)
+
+	fetcher := &verifyingFetcher{
+		provenanceFetcher: provenanceFetcher{
+			mockFetcher: mockFetcher{
+				versions: map[string]string{
+					"github-test-base-plugin":     "v2.0.0",
+					"github-test-consumer-plugin": "v2.0.0",
+				},
+			},
+		},
+		unverified: map[string]bool{"base-plugin": true},
+	}
+	container := newTestContainer(t, tmpDir)
+	summary := &fetchSummary{}
+	created, err := run(ctx, container, fetcher, &flags{}, withSummary(summary))
+	require.NoError(t, err)
+	require.Len(t, created, 1)
+	assert.Equal(t, "consumer-plugin", created[0].name)
+	ok, err := checkDirExists(filepath.Join(tmpDir, "plugins", "test", "base-plugin", "v2.0.0"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 1, summary.count(sourceStatusError))
+
+	// What was verified is recorded in the provenance.
+	content, err := os.ReadFile(filepath.Join(tmpDir, "plugins", "test", "consumer-plugin", "v2.0.0", provenanceFilename))
+	require.NoError(t, err)
+	var provenance fetchclient.Provenance
+	require.NoError(t, json.Unmarshal(content, &provenance))
+	assert.Equal(t, []string{"attestation of consumer-plugin@v2.0.0"}, provenance.Verified)
+}
+
 func TestRunUpdateFrequency(t *testing.T) {
 	t.Parallel()
 
@@ -292,6 +506,59 @@ func TestRunUpdateFrequency(t *testing.T) {
 	})
 }
 
+func TestFetchPendingCreationsConcurrent(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	var configs []*source.Config
+	// Ten plugins share two sources; each source must only be fetched once.
+	for i := range 10 {
+		pluginDir := filepath.Join(tmpDir, "plugins", "test", fmt.Sprintf("plugin-%d", i))
+		require.NoError(t, os.MkdirAll(filepath.Join(pluginDir, "v1.0.0"), 0755))
+		configs = append(configs, &source.Config{
+			Filename: filepath.Join(pluginDir, "source.yaml"),
+			Source: source.Source{
+				GitHub: &source.GitHubConfig{Owner: "test", Repository: fmt.Sprintf("repo-%d", i%2)},
+			},
+		})
+	}
+	// Ignored versions are still applied per config for shared sources.
+	configs[3].Source.IgnoreVersions = []string{"v2.0.0"}
+	fetcher := &countingFetcher{
+		versions: map[string]string{
+			"github-test-repo-0": "v2.0.0",
+			"github-test-repo-1": "v2.0.0",
+		},
+	}
+	logger := slog.New(slog.NewTextHandler(testWriter{t}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	pending, err := fetchPendingCreations(t.Context(), logger, fetcher, configs, nil, 4, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, pending, 9)
+	assert.Equal(t, map[string]int{"github-test-repo-0": 1, "github-test-repo-1": 1}, fetcher.calls)
+	for pluginDir, p := range pending {
+		assert.NotEqual(t, "plugin-3", filepath.Base(pluginDir))
+		assert.Equal(t, "v1.0.0", p.previousVersion)
+		assert.Equal(t, "v2.0.0", p.newVersion)
+	}
+}
+
+// countingFetcher returns predetermined versions and records the number of fetches per cache key.
+type countingFetcher struct {
+	versions map[string]string
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (c *countingFetcher) Fetch(_ context.Context, config *source.Config) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.calls == nil {
+		c.calls = make(map[string]int)
+	}
+	c.calls[config.CacheKey()]++
+	return c.versions[config.CacheKey()], nil
+}
+
 // mockFetcher returns predetermined versions for testing.
 type mockFetcher struct {
 	versions map[string]string // maps cache key (e.g., "github-owner-repo") -> version to return
@@ -306,6 +573,55 @@ func (m *mockFetcher) Fetch(_ context.Context, config *source.Config) (string, e
 	return "v1.0.0", nil
 }
 
+// provenanceFetcher is a mockFetcher which also resolves provenance.
+type provenanceFetcher struct {
+	mockFetcher
+	err error
+}
+
+func (p *provenanceFetcher) Provenance(_ context.Context, config *source.Config, version string) (*fetchclient.Provenance, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &fetchclient.Provenance{
+		Source:  config.Source.Name(),
+		Version: version,
+		Commit:  config.Source.GitHub.Owner + "/" + config.Source.GitHub.Repository + "@" + version,
+	}, nil
+}
+
+// verifyingFetcher is a provenanceFetcher which also verifies provenance, refusing
+// the versions of the plugins in unverified.
+type verifyingFetcher struct {
+	provenanceFetcher
+	unverified map[string]bool
+}
+
+func (v *verifyingFetcher) Verify(_ context.Context, config *source.Config, version string) ([]string, error) {
+	if v.unverified[config.Source.GitHub.Repository] {
+		return nil, errors.New("invalid signature")
+	}
+	return []string{"attestation of " + config.Source.GitHub.Repository + "@" + version}, nil
+}
+
+// changesFetcher is a mockFetcher which also resolves upstream changes, failing for
+// the plugins in unresolved.
+type changesFetcher struct {
+	mockFetcher
+	unresolved map[string]bool
+}
+
+func (c *changesFetcher) Changes(_ context.Context, config *source.Config, previousVersion string, newVersion string) (*fetchclient.Changes, error) {
+	if c.unresolved[config.Source.GitHub.Repository] {
+		return nil, errors.New("rate limited")
+	}
+	return &fetchclient.Changes{
+		PreviousUpstream: previousVersion,
+		NewUpstream:      newVersion,
+		CompareURL:       "https://github.com/" + config.Source.GitHub.Owner + "/" + config.Source.GitHub.Repository + "/compare/" + previousVersion + "..." + newVersion,
+	}, nil
+}
+
 // setupTestRepository creates a complete test repository structure with:
 // - plugins/ directory with base-plugin and consumer-plugin
 // - source.yaml files for version detection
@@ -400,6 +716,35 @@ COPY --from=consumer /binary /usr/local/bin/protoc-gen-consumer
 	))
 }
 
+func TestDockerfileImage(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "rust:1.91.1-alpine3.22", dockerfileImage([]byte(`# syntax=docker/dockerfile:1.19
+FROM --platform=$BUILDPLATFORM rust:1.91.1-alpine3.22 AS builder
+RUN cargo install protoc-gen-prost --version 0.5.0 --locked --root /app
+FROM scratch
+`), "rust"))
+	assert.Equal(t, "docker.io/library/python:3.13.13-trixie@sha256:d52f", dockerfileImage([]byte("FROM docker.io/library/python:3.13.13-trixie@sha256:d52f AS build\n"), "python"))
+	assert.Empty(t, dockerfileImage([]byte("FROM golang:1.25.5-bookworm\nFROM example.com:5000/rusty:1\n"), "rust"))
+}
+
+func TestPythonRequirementsInput(t *testing.T) {
+	t.Parallel()
+	input := `# Compiled by uv.
+mypy-protobuf==5.1.0 \
+    --hash=sha256:0123 \
+    --hash=sha256:4567
+protobuf==6.33.1 ; python_version >= "3.9" \
+    --hash=sha256:89ab
+--extra-index-url https://example.com/simple # mirror
+types-protobuf==6.32.1.20251105
+`
+	assert.Equal(t, `mypy-protobuf==5.1.0
+protobuf==6.33.1 ; python_version >= "3.9"
+--extra-index-url https://example.com/simple
+types-protobuf==6.32.1.20251105
+`, string(pythonRequirementsInput([]byte(input))))
+}
+
 // mockHTTPTransport is an http.RoundTripper that serves static responses for testing.
 type mockHTTPTransport struct {
 	responses map[string]string // URL -> response body
@@ -596,6 +941,114 @@ func TestGeneratePRBodyWithGoMinVersionBump(t *testing.T) {
 	assert.Contains(t, body, "proxy.golang.org/github.com/grpc-ecosystem/grpc-gateway/v2/@v/v2.29.0.mod")
 }
 
+func TestRunResolvesChanges(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	setupTestRepository(t, tmpDir)
+	fetcher := &changesFetcher{
+		mockFetcher: mockFetcher{
+			versions: map[string]string{
+				"github-test-base-plugin":     "v2.0.0",
+				"github-test-consumer-plugin": "v2.0.0",
+			},
+		},
+		unresolved: map[string]bool{"consumer-plugin": true},
+	}
+	created, err := run(t.Context(), newTestContainer(t, tmpDir), fetcher, &flags{})
+	require.NoError(t, err)
+	require.Len(t, created, 2)
+	byName := make(map[string]createdPlugin)
+	for _, p := range created {
+		byName[p.name] = p
+	}
+	require.NotNil(t, byName["base-plugin"].changes)
+	assert.Equal(t, "https://github.com/test/base-plugin/compare/v1.0.0...v2.0.0", byName["base-plugin"].changes.CompareURL)
+	// Changes which can't be resolved are omitted from the PR body.
+	assert.Nil(t, byName["consumer-plugin"].changes)
+}
+
+func TestGeneratePRBodyWithChanges(t *testing.T) {
+	t.Parallel()
+	release := &fetchclient.Changes{
+		PreviousUpstream: "v29.0",
+		NewUpstream:      "v30.0",
+		CompareURL:       "https://github.com/protocolbuffers/protobuf/compare/v29.0...v30.0",
+		ReleaseURL:       "https://github.com/protocolbuffers/protobuf/releases/tag/v30.0",
+		ReleaseNotes:     "# Announcements\r\n\r\n* Drop support for Java 7",
+	}
+	created := []createdPlugin{
+		{org: "protocolbuffers", name: "java", previousVersion: "v29.0", newVersion: "v30.0", changes: release},
+		{org: "protocolbuffers", name: "kotlin", previousVersion: "v29.0", newVersion: "v30.0", changes: release},
+		{
+			org:             "community",
+			name:            "stephenh-ts-proto",
+			previousVersion: "v2.6.0",
+			newVersion:      "v2.6.1",
+			changes:         &fetchclient.Changes{NewUpstream: "2.6.1", RegistryURL: "https://www.npmjs.com/package/ts-proto/v/2.6.1"},
+		},
+	}
+	assert.Equal(t, `### protocolbuffers
+- java: v29.0 → v30.0 ⚠️ **major version bump**
+  - [Compare v29.0...v30.0](https://github.com/protocolbuffers/protobuf/compare/v29.0...v30.0)
+  - [Release notes](https://github.com/protocolbuffers/protobuf/releases/tag/v30.0)
+
+  <details><summary>Release notes excerpt</summary>
+
+  # Announcements
+
+  * Drop support for Java 7
+
+  </details>
+- kotlin: v29.0 → v30.0 ⚠️ **major version bump**
+  - [Compare v29.0...v30.0](https://github.com/protocolbuffers/protobuf/compare/v29.0...v30.0)
+  - [Release notes](https://github.com/protocolbuffers/protobuf/releases/tag/v30.0)
+
+### stephenh-ts-proto
+- v2.6.0 → v2.6.1
+  - [Registry page](https://www.npmjs.com/package/ts-proto/v/2.6.1)`, generatePRBody(created))
+}
+
+func TestGeneratePRBodyCollapsesLongBodies(t *testing.T) {
+	t.Parallel()
+	newChanges := func(repository string) *fetchclient.Changes {
+		return &fetchclient.Changes{
+			ReleaseURL:   "https://github.com/acme/" + repository + "/releases/tag/v1.1.0",
+			ReleaseNotes: strings.Repeat("* A change to "+repository+"\n", 200),
+		}
+	}
+	created := []createdPlugin{
+		{org: "org0", name: "plugin", previousVersion: "v1.0.0", newVersion: "v1.1.0", changes: newChanges("plugin")},
+		{org: "org1", name: "other", previousVersion: "v1.0.0", newVersion: "v2.0.0", changes: newChanges("other")},
+		{org: "org1", name: "plugin", previousVersion: "v1.0.0", newVersion: "v1.1.0", changes: newChanges("plugin")},
+		{org: "org2", name: "plugin", previousVersion: "v1.0.0", newVersion: "v1.1.0", changes: newChanges("third")},
+	}
+	body := generatePRBody(created)
+	assert.NotContains(t, body, "### ")
+	assert.Contains(t, body, "<details><summary><b>org0</b>: 1 plugin</summary>")
+	assert.Contains(t, body, "<details><summary><b>org1</b>: 2 plugins ⚠️</summary>")
+	// The release notes of each release are excerpted once, truncated at a line break.
+	assert.Equal(t, 3, strings.Count(body, "Release notes excerpt"))
+	assert.Contains(t, body, "  * A change to plugin\n\n  …\n")
+	assert.Less(t, len(body), maxPRBodyLength)
+
+	for i := range 300 {
+		created = append(created, createdPlugin{
+			org:             fmt.Sprintf("org%d", i+3),
+			name:            "plugin",
+			previousVersion: "v1.0.0",
+			newVersion:      "v1.1.0",
+			changes: &fetchclient.Changes{
+				ReleaseURL:   fmt.Sprintf("https://github.com/acme/plugin%d/releases/tag/v1.1.0", i),
+				ReleaseNotes: strings.Repeat("* A change\n", 20),
+			},
+		})
+	}
+	body = generatePRBody(created)
+	// Bodies over GitHub's limit omit the release notes excerpts.
+	assert.NotContains(t, body, "Release notes excerpt")
+	assert.Less(t, len(body), maxPRBodyLength)
+}
+
 type testWriter struct {
 	tb testing.TB
 }
@@ -614,3 +1067,23 @@ func newTestContainer(t *testing.T, root string) appext.Container {
 	logger := slog.New(slog.NewTextHandler(testWriter{t}, &slog.HandlerOptions{Level: slog.LevelDebug}))
 	return appext.NewContainer(nameContainer, logger, appext.LogLevelDebug, appext.LogFormatText)
 }
+
+func TestFormatGitHubOutput(t *testing.T) {
+	t.Parallel()
+	output, err := formatGitHubOutput("pr_title", "Update acme/plugin to v1.1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "pr_title=Update acme/plugin to v1.1.0\n", output)
+
+	// A line of the value matching a fixed delimiter can't end the value early.
+	body := "Release notes:\nEOF\npr_title=injected\n"
+	output, err = formatGitHubOutput("pr_body", body)
+	require.NoError(t, err)
+	header, rest, ok := strings.Cut(output, "\n")
+	require.True(t, ok)
+	delimiter, ok := strings.CutPrefix(header, "pr_body<<ghadelimiter_")
+	require.True(t, ok)
+	assert.Equal(t, strings.TrimRight(body, "\n")+"\nghadelimiter_"+delimiter+"\n", rest)
+	other, err := formatGitHubOutput("pr_body", body)
+	require.NoError(t, err)
+	assert.NotEqual(t, output, other)
+}
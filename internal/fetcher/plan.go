@@ -0,0 +1,261 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bufbuild/buf/private/pkg/diff/diffmyers"
+)
+
+// diffContextLines is the number of unchanged lines around the changes of a diff.
+const diffContextLines = 3
+
+// versionBump is a dependency or base image updated to its latest version.
+type versionBump struct {
+	Name string `json:"name"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// fetchPlan is the plugin versions the fetcher would create (see --plan).
+type fetchPlan struct {
+	Plugins []*planEntry `json:"plugins"`
+}
+
+// planEntry is a pending plugin version of a fetchPlan.
+type planEntry struct {
+	// Plugin is the org and name of the plugin (such as "connectrpc/go").
+	Plugin string `json:"plugin"`
+	// Source is the cache key of the source.yaml source (such as "github-connectrpc-connect-go").
+	Source          string `json:"source"`
+	PreviousVersion string `json:"previous_version"`
+	NewVersion      string `json:"new_version"`
+	// SkipReasons are the reasons the version would not be created in this run.
+	SkipReasons     []string      `json:"skip_reasons,omitempty"`
+	DependencyBumps []versionBump `json:"dependency_bumps,omitempty"`
+	BaseImageBumps  []versionBump `json:"base_image_bumps,omitempty"`
+	// PostProcessing are the post_process steps of the source config run on the version
+	// after it is created, with the image they run in, followed by its tests.
+	PostProcessing []string    `json:"post_processing,omitempty"`
+	Files          []*planFile `json:"files,omitempty"`
+}
+
+// planFile is a file of a pending plugin version.
+type planFile struct {
+	// Path is relative to the repository root.
+	Path string `json:"path"`
+	// Diff is the unified diff from the file of the previous version, or from an empty
+	// file if it is new. It is empty if the file is copied unchanged.
+	Diff string `json:"diff,omitempty"`
+}
+
+// skip records a pending plugin version which would not be created. It does nothing
+// if the plan is nil.
+func (p *fetchPlan) skip(pending *pluginToCreate, reasons []string) {
+	if p == nil {
+		return
+	}
+	entry := newPlanEntry(pending)
+	entry.SkipReasons = reasons
+	p.Plugins = append(p.Plugins, entry)
+}
+
+// add records a pending plugin version which would be created with the rendered files.
+func (p *fetchPlan) add(root string, pending *pluginToCreate, files []*renderedFile) error {
+	entry := newPlanEntry(pending)
+	for _, file := range files {
+		entry.DependencyBumps = append(entry.DependencyBumps, file.dependencyBumps...)
+		entry.BaseImageBumps = append(entry.BaseImageBumps, file.baseImageBumps...)
+		previousPath := filepath.Join(pending.pluginDir, pending.previousVersion, filepath.FromSlash(file.name))
+		if file.name == provenanceFilename {
+			// Provenance is never copied from the previous version.
+			previousPath = ""
+		}
+		planFile, err := newPlanFile(root, previousPath, filepath.Join(pending.pluginDir, pending.newVersion, filepath.FromSlash(file.name)), file.content)
+		if err != nil {
+			return err
+		}
+		entry.Files = append(entry.Files, planFile)
+	}
+	postProcessing, err := postProcessingSteps(pending, files)
+	if err != nil {
+		return fmt.Errorf("failed to find post-processing steps for %s: %w", entry.Plugin, err)
+	}
+	entry.PostProcessing = postProcessing
+	p.Plugins = append(p.Plugins, entry)
+	return nil
+}
+
+func newPlanEntry(pending *pluginToCreate) *planEntry {
+	return &planEntry{
+		Plugin:          filepath.Base(filepath.Dir(pending.pluginDir)) + "/" + filepath.Base(pending.pluginDir),
+		Source:          pending.config.CacheKey(),
+		PreviousVersion: pending.previousVersion,
+		NewVersion:      pending.newVersion,
+	}
+}
+
+// newPlanFile returns the file written to path with the content, diffed against the
+// file at previousPath (or an empty file if previousPath is empty or doesn't exist).
+func newPlanFile(root string, previousPath string, path string, content []byte) (*planFile, error) {
+	relativePath, err := filepath.Rel(root, path)
+	if err != nil {
+		return nil, err
+	}
+	file := &planFile{Path: filepath.ToSlash(relativePath)}
+	fromName := "/dev/null"
+	var previousContent []byte
+	if previousPath != "" {
+		previousContent, err = os.ReadFile(previousPath)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return nil, err
+		case bytes.Equal(previousContent, content):
+			return file, nil
+		default:
+			relativePreviousPath, err := filepath.Rel(root, previousPath)
+			if err != nil {
+				return nil, err
+			}
+			fromName = "a/" + filepath.ToSlash(relativePreviousPath)
+		}
+	}
+	file.Diff = unifiedDiff(fromName, "b/"+file.Path, previousContent, content)
+	return file, nil
+}
+
+// diffLine is a line of a unified diff.
+type diffLine struct {
+	// kind is ' ' for context lines, '-' for deleted lines, and '+' for inserted lines.
+	kind byte
+	text []byte
+}
+
+// unifiedDiff returns the unified diff from the content from to the content to, with
+// diffContextLines lines of context around each change.
+func unifiedDiff(fromName string, toName string, from []byte, to []byte) string {
+	fromLines, toLines := splitLines(from), splitLines(to)
+	var lines []diffLine
+	fromIndex := 0
+	for _, edit := range diffmyers.Diff(fromLines, toLines) {
+		for ; fromIndex < edit.FromPosition; fromIndex++ {
+			lines = append(lines, diffLine{kind: ' ', text: fromLines[fromIndex]})
+		}
+		if edit.Kind == diffmyers.EditKindDelete {
+			lines = append(lines, diffLine{kind: '-', text: fromLines[edit.FromPosition]})
+			fromIndex = edit.FromPosition + 1
+		} else {
+			lines = append(lines, diffLine{kind: '+', text: toLines[edit.ToPosition]})
+		}
+	}
+	for ; fromIndex < len(fromLines); fromIndex++ {
+		lines = append(lines, diffLine{kind: ' ', text: fromLines[fromIndex]})
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	// fromLine and toLine are the number of lines of each file before lines[start].
+	var fromLine, toLine int
+	for start := 0; start < len(lines); {
+		first := slices.IndexFunc(lines[start:], func(line diffLine) bool { return line.kind != ' ' })
+		if first == -1 {
+			break
+		}
+		first += start
+		// Extend the hunk until a change is followed by more than twice the context.
+		end := first + 1
+		for next := end; next < len(lines) && next-end <= 2*diffContextLines; next++ {
+			if lines[next].kind != ' ' {
+				end = next + 1
+			}
+		}
+		hunkStart, hunkEnd := max(first-diffContextLines, start), min(end+diffContextLines, len(lines))
+		fromLine, toLine = fromLine+hunkStart-start, toLine+hunkStart-start
+		var fromCount, toCount int
+		for _, line := range lines[hunkStart:hunkEnd] {
+			if line.kind != '+' {
+				fromCount++
+			}
+			if line.kind != '-' {
+				toCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(fromLine, fromCount), hunkRange(toLine, toCount))
+		for _, line := range lines[hunkStart:hunkEnd] {
+			sb.WriteByte(line.kind)
+			sb.Write(line.text)
+			if !bytes.HasSuffix(line.text, []byte("\n")) {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		fromLine, toLine = fromLine+fromCount, toLine+toCount
+		start = hunkEnd
+	}
+	return sb.String()
+}
+
+// hunkRange returns the range of a unified diff hunk header, which starts after the
+// given number of lines.
+func hunkRange(linesBefore int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", linesBefore)
+	}
+	return fmt.Sprintf("%d,%d", linesBefore+1, count)
+}
+
+// splitLines splits content into lines, keeping their line endings.
+func splitLines(content []byte) [][]byte {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// postProcessingSteps returns the post_process steps run on the pending plugin version
+// rendered as the files, in order (see runPostProcessSteps), followed by its tests.
+func postProcessingSteps(pending *pluginToCreate, files []*renderedFile) ([]string, error) {
+	readDockerfile := func() ([]byte, error) {
+		for _, file := range files {
+			if file.name == "Dockerfile" {
+				return file.content, nil
+			}
+		}
+		return nil, fs.ErrNotExist
+	}
+	var steps []string
+	for _, declared := range pending.config.PostProcess {
+		step, ok := postProcessSteps[declared.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown post_process step %q", declared.Name)
+		}
+		image, err := postProcessStepImage(declared, step.toolchain, readDockerfile)
+		if err != nil {
+			return nil, fmt.Errorf("post_process step %s: %w", declared.Name, err)
+		}
+		if image == "" {
+			steps = append(steps, string(declared.Name))
+		} else {
+			steps = append(steps, fmt.Sprintf("%s (%s)", declared.Name, image))
+		}
+	}
+	return append(steps, "make test"), nil
+}
+
+// writePlan writes the plan as indented JSON.
+func writePlan(w io.Writer, plan *fetchPlan) error {
+	if plan.Plugins == nil {
+		plan.Plugins = []*planEntry{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(plan)
+}
@@ -0,0 +1,124 @@
+package fetcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+func TestRunPlan(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	tmpDir := t.TempDir()
+	setupTestRepository(t, tmpDir)
+	// A newer golang base image is planned for the Dockerfiles.
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "baseimages", "Dockerfile.golang"), []byte("FROM golang:1.23.0-bookworm\n"), 0644))
+
+	fetcher := &provenanceFetcher{
+		mockFetcher: mockFetcher{
+			versions: map[string]string{
+				"github-test-base-plugin":     "v2.0.0",
+				"github-test-consumer-plugin": "v2.0.0",
+			},
+		},
+	}
+	container := newTestContainer(t, tmpDir)
+	plan := &fetchPlan{}
+	created, err := run(ctx, container, fetcher, &flags{}, withPlan(plan))
+	require.NoError(t, err)
+	require.Len(t, created, 2)
+
+	// Nothing is written.
+	for _, name := range []string{"base-plugin", "consumer-plugin"} {
+		ok, err := checkDirExists(filepath.Join(tmpDir, "plugins", "test", name, "v2.0.0"))
+		require.NoError(t, err)
+		assert.False(t, ok)
+	}
+
+	require.Len(t, plan.Plugins, 2)
+	base, consumer := plan.Plugins[0], plan.Plugins[1]
+	assert.Equal(t, "test/base-plugin", base.Plugin)
+	assert.Equal(t, "github-test-base-plugin", base.Source)
+	assert.Equal(t, "v1.0.0", base.PreviousVersion)
+	assert.Equal(t, "v2.0.0", base.NewVersion)
+	assert.Empty(t, base.SkipReasons)
+	assert.Empty(t, base.DependencyBumps)
+	assert.Equal(t, []versionBump{{Name: "golang", Old: "1.22.0-bookworm", New: "1.23.0-bookworm"}}, base.BaseImageBumps)
+	assert.Equal(t, []string{"make test"}, base.PostProcessing)
+	// The consumer depends on the planned version of the base plugin.
+	assert.Equal(t, "test/consumer-plugin", consumer.Plugin)
+	assert.Equal(t, []versionBump{{Name: "buf.build/test/base-plugin", Old: "v1.0.0", New: "v2.0.0"}}, consumer.DependencyBumps)
+
+	files := make(map[string]string)
+	for _, file := range consumer.Files {
+		files[file.Path] = file.Diff
+	}
+	assert.Equal(t, `--- a/plugins/test/consumer-plugin/v1.0.0/buf.plugin.yaml
++++ b/plugins/test/consumer-plugin/v2.0.0/buf.plugin.yaml
+@@ -1,7 +1,7 @@
+ version: v1
+ name: buf.build/test/consumer-plugin
+-plugin_version: v1.0.0
++plugin_version: v2.0.0
+ deps:
+-  - plugin: buf.build/test/base-plugin:v1.0.0
++  - plugin: buf.build/test/base-plugin:v2.0.0
+ output_languages:
+   - go
+`, files["plugins/test/consumer-plugin/v2.0.0/buf.plugin.yaml"])
+	assert.Contains(t, files["plugins/test/consumer-plugin/v2.0.0/Dockerfile"], "+FROM golang:1.23.0-bookworm\n")
+	assert.Contains(t, files["plugins/test/consumer-plugin/v2.0.0/"+provenanceFilename], "--- /dev/null\n")
+}
+
+func TestRunPlanSkipsDeferredVersions(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	tmpDir := t.TempDir()
+	setupTestRepository(t, tmpDir)
+
+	fetcher := &mockFetcher{versions: map[string]string{"github-test-base-plugin": "v2.0.0"}}
+	container := newTestContainer(t, tmpDir)
+	plan := &fetchPlan{}
+	_, err := run(ctx, container, fetcher, &flags{include: []string{"test/base-plugin"}}, withPlan(plan), withReadinessCheck(func(context.Context, *pluginToCreate) ([]string, error) {
+		return []string{"go example.com/base@v2.0.0 (Dockerfile): not found"}, nil
+	}))
+	require.NoError(t, err)
+	require.Len(t, plan.Plugins, 1)
+	assert.Equal(t, []string{"go example.com/base@v2.0.0 (Dockerfile): not found"}, plan.Plugins[0].SkipReasons)
+	assert.Empty(t, plan.Plugins[0].Files)
+}
+
+func TestPostProcessingSteps(t *testing.T) {
+	t.Parallel()
+	pending := &pluginToCreate{config: &source.Config{PostProcess: []source.PostProcessStep{
+		{Name: source.PostProcessMavenDeps},
+		{Name: source.PostProcessNPMLock},
+		{Name: source.PostProcessGoModTidy, Image: "golang:1.25"},
+		{Name: source.PostProcessGoMinVersion},
+	}}}
+	files := []*renderedFile{{name: "Dockerfile", content: []byte("FROM node:24.11.1-trixie AS build\nFROM scratch\n")}}
+	// The plan lists the declared steps, in order, with the image they run in.
+	steps, err := postProcessingSteps(pending, files)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"maven_deps",
+		"npm_lock (node:24.11.1-trixie)",
+		"go_mod_tidy (golang:1.25)",
+		"go_min_version",
+		"make test",
+	}, steps)
+
+	steps, err = postProcessingSteps(&pluginToCreate{config: &source.Config{}}, files)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"make test"}, steps)
+
+	pending.config.PostProcess = []source.PostProcessStep{{Name: source.PostProcessCargoLock}}
+	_, err = postProcessingSteps(pending, files)
+	require.ErrorContains(t, err, "no rust image found")
+}
@@ -0,0 +1,223 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/bufbuild/plugins/internal/bazelshim"
+	"github.com/bufbuild/plugins/internal/patches"
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+// postProcessStep is the implementation of a post-processing step (see source.PostProcessStepName).
+type postProcessStep struct {
+	// toolchain is the name of the image of the plugin's Dockerfile which the step runs
+	// in by default (such as "node"), or empty if the step doesn't run in a container.
+	toolchain string
+	run       func(ctx context.Context, logger *slog.Logger, client *http.Client, plugin *createdPlugin, image string) error
+}
+
+// postProcessSteps are the implementations of all post-processing steps.
+var postProcessSteps = map[source.PostProcessStepName]postProcessStep{
+	source.PostProcessMavenDeps: {
+		run: func(_ context.Context, _ *slog.Logger, _ *http.Client, plugin *createdPlugin, _ string) error {
+			return regenerateMavenDeps(*plugin)
+		},
+	},
+	source.PostProcessNugetDeps: {
+		run: func(_ context.Context, _ *slog.Logger, _ *http.Client, plugin *createdPlugin, _ string) error {
+			return regenerateNugetDeps(*plugin)
+		},
+	},
+	source.PostProcessGoModTidy: {
+		toolchain: "golang",
+		run: func(ctx context.Context, logger *slog.Logger, _ *http.Client, plugin *createdPlugin, image string) error {
+			return runGoModTidy(ctx, logger, *plugin, image)
+		},
+	},
+	source.PostProcessNPMLock: {
+		toolchain: "node",
+		run: func(ctx context.Context, logger *slog.Logger, _ *http.Client, plugin *createdPlugin, image string) error {
+			return recreateNPMPackageLock(ctx, logger, *plugin, image)
+		},
+	},
+	source.PostProcessPythonRequirements: {
+		toolchain: "python",
+		run: func(ctx context.Context, logger *slog.Logger, _ *http.Client, plugin *createdPlugin, image string) error {
+			return recompilePythonRequirements(ctx, logger, *plugin, image)
+		},
+	},
+	source.PostProcessSwiftResolve: {
+		toolchain: "swift",
+		run: func(ctx context.Context, logger *slog.Logger, _ *http.Client, plugin *createdPlugin, image string) error {
+			return recreateSwiftPackageResolved(ctx, logger, *plugin, image)
+		},
+	},
+	source.PostProcessCargoLock: {
+		toolchain: "rust",
+		run: func(ctx context.Context, logger *slog.Logger, _ *http.Client, plugin *createdPlugin, image string) error {
+			return regenerateCargoLock(ctx, logger, *plugin, image)
+		},
+	},
+	source.PostProcessGoMinVersion: {
+		run: func(ctx context.Context, logger *slog.Logger, client *http.Client, plugin *createdPlugin, _ string) error {
+			bump, err := updateGoRegistryMinVersion(ctx, logger, client, *plugin)
+			if err != nil {
+				return err
+			}
+			plugin.goMinVersionBump = bump
+			return nil
+		},
+	},
+	source.PostProcessBazelShims: {
+		run: func(_ context.Context, _ *slog.Logger, _ *http.Client, plugin *createdPlugin, _ string) error {
+			return renderBazelShims(*plugin)
+		},
+	},
+	source.PostProcessPatches: {
+		run: func(ctx context.Context, logger *slog.Logger, _ *http.Client, plugin *createdPlugin, _ string) error {
+			return applyPatches(ctx, logger, *plugin)
+		},
+	},
+}
+
+// renderBazelShims renders the Bazel files of a protocolbuffers plugin, rather than
+// keeping the files copied from the previous version.
+func renderBazelShims(plugin createdPlugin) error {
+	files, err := bazelshim.Render(plugin.org+"/"+plugin.name, plugin.newVersion)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("%s/%s isn't built from a shim", plugin.org, plugin.name)
+	}
+	for _, file := range files {
+		if err := os.WriteFile(filepath.Join(plugin.pluginDir, plugin.newVersion, file.Name), file.Content, 0644); err != nil { //nolint:gosec
+			return err
+		}
+	}
+	return nil
+}
+
+// applyPatches applies the patches of the plugin to the upstream source cloned by its
+// Dockerfile, so patches which no longer apply fail before the plugin is built. Patches
+// applying with offsets are rewritten with refreshed context, while patches only applying
+// with fuzz fail: the hunks may have applied to the wrong place, so a reviewer must
+// refresh them with check-patches.
+func applyPatches(ctx context.Context, logger *slog.Logger, plugin createdPlugin) error {
+	versionDir := filepath.Join(plugin.pluginDir, plugin.newVersion)
+	patchFiles, err := patches.FindPatches(versionDir)
+	if err != nil {
+		return err
+	}
+	if len(patchFiles) == 0 {
+		return errors.New("no patches found")
+	}
+	dockerfile, err := os.ReadFile(filepath.Join(versionDir, "Dockerfile"))
+	if err != nil {
+		return fmt.Errorf("failed to read Dockerfile: %w", err)
+	}
+	upstream, err := patches.FindUpstream(dockerfile)
+	if err != nil {
+		return err
+	}
+	logger.InfoContext(ctx, "applying patches", slog.Any("plugin", plugin), slog.String("url", upstream.URL), slog.String("ref", upstream.Ref))
+	results, err := patches.Apply(ctx, upstream, versionDir, patchFiles, patches.RefreshOffsets)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.Status == patches.StatusFailed {
+			return fmt.Errorf("%s doesn't apply to %s of %s:\n%s", result.Patch, upstream.Ref, upstream.URL, result.Output)
+		}
+		logger.InfoContext(ctx, "applied patch", slog.Any("plugin", plugin), slog.String("patch", result.Patch), slog.String("status", string(result.Status)))
+	}
+	return nil
+}
+
+// runPostProcessSteps runs the post-processing steps declared for the plugin, in order.
+func runPostProcessSteps(ctx context.Context, logger *slog.Logger, client *http.Client, plugin *createdPlugin) error {
+	for _, declared := range plugin.postProcess {
+		step, ok := postProcessSteps[declared.Name]
+		if !ok {
+			return fmt.Errorf("unknown post_process step %q for %s", declared.Name, plugin)
+		}
+		image, err := postProcessImage(*plugin, declared, step.toolchain)
+		if err != nil {
+			return fmt.Errorf("failed to run post_process step %s for %s: %w", declared.Name, plugin, err)
+		}
+		if err := step.run(ctx, logger, client, plugin, image); err != nil {
+			return fmt.Errorf("failed to run post_process step %s for %s: %w", declared.Name, plugin, err)
+		}
+	}
+	return nil
+}
+
+// postProcessImage returns the image a post-processing step runs in: the image declared
+// in the source config, or else the image of the plugin's Dockerfile for the toolchain.
+// It returns an empty string for steps which don't run in a container.
+func postProcessImage(plugin createdPlugin, declared source.PostProcessStep, toolchain string) (string, error) {
+	return postProcessStepImage(declared, toolchain, func() ([]byte, error) {
+		return os.ReadFile(filepath.Join(plugin.pluginDir, plugin.newVersion, "Dockerfile"))
+	})
+}
+
+// postProcessStepImage is postProcessImage, reading the plugin's Dockerfile with
+// readDockerfile only if needed.
+func postProcessStepImage(declared source.PostProcessStep, toolchain string, readDockerfile func() ([]byte, error)) (string, error) {
+	if toolchain == "" {
+		if declared.Image != "" {
+			return "", fmt.Errorf("image %q set, but %s doesn't run in a container", declared.Image, declared.Name)
+		}
+		return "", nil
+	}
+	if declared.Image != "" {
+		return declared.Image, nil
+	}
+	dockerfile, err := readDockerfile()
+	if err != nil {
+		return "", fmt.Errorf("failed to read Dockerfile: %w", err)
+	}
+	image := dockerfileImage(dockerfile, toolchain)
+	if image == "" {
+		return "", fmt.Errorf("no %s image found in Dockerfile: set the image of the step in source.yaml", toolchain)
+	}
+	return image, nil
+}
+
+// runInContainer runs the command in a container of the image, with dir mounted as its
+// working directory. The command runs as the current user, with HOME set to /tmp, and
+// reads stdin if not nil.
+func runInContainer(ctx context.Context, image string, dir string, env []string, stdin io.Reader, command ...string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	args := []string{
+		"run", "--rm",
+		"--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()),
+		"--env", "HOME=/tmp",
+		"--volume", absDir + ":/workspace",
+		"--workdir", "/workspace",
+	}
+	if stdin != nil {
+		args = append(args, "--interactive")
+	}
+	for _, variable := range env {
+		args = append(args, "--env", variable)
+	}
+	args = append(args, image)
+	args = append(args, command...)
+	cmd := exec.CommandContext(ctx, "docker", args...) //nolint:gosec // We control the arguments here.
+	cmd.Stdin = stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,38 @@
+package fetcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+func TestPostProcessSteps(t *testing.T) {
+	t.Parallel()
+	for _, name := range source.PostProcessStepNames {
+		assert.Contains(t, postProcessSteps, name)
+	}
+	assert.Len(t, postProcessSteps, len(source.PostProcessStepNames))
+
+	pluginDir := filepath.Join(t.TempDir(), "acme", "plugin")
+	require.NoError(t, os.MkdirAll(filepath.Join(pluginDir, "v1.0.0"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "v1.0.0", "Dockerfile"), []byte("FROM node:24.11.1-trixie AS build\nFROM scratch\n"), 0644))
+	plugin := createdPlugin{org: "acme", name: "plugin", pluginDir: pluginDir, newVersion: "v1.0.0"}
+	image, err := postProcessImage(plugin, source.PostProcessStep{Name: source.PostProcessNPMLock}, "node")
+	require.NoError(t, err)
+	assert.Equal(t, "node:24.11.1-trixie", image)
+	image, err = postProcessImage(plugin, source.PostProcessStep{Name: source.PostProcessNPMLock, Image: "node:22"}, "node")
+	require.NoError(t, err)
+	assert.Equal(t, "node:22", image)
+	_, err = postProcessImage(plugin, source.PostProcessStep{Name: source.PostProcessCargoLock}, "rust")
+	require.ErrorContains(t, err, "no rust image found")
+	image, err = postProcessImage(plugin, source.PostProcessStep{Name: source.PostProcessMavenDeps}, "")
+	require.NoError(t, err)
+	assert.Empty(t, image)
+	_, err = postProcessImage(plugin, source.PostProcessStep{Name: source.PostProcessMavenDeps, Image: "maven:3"}, "")
+	require.Error(t, err)
+}
@@ -0,0 +1,265 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-github/v72/github"
+
+	"github.com/bufbuild/plugins/internal/fetchclient"
+)
+
+const (
+	// defaultPublishRepository is the GitHub repository PRs are opened against by --publish.
+	defaultPublishRepository = "bufbuild/plugins"
+	// defaultPublishBase is the branch PRs are opened against by --publish.
+	defaultPublishBase = "main"
+	// combinedBranch is the branch of the PR of all created plugins, when not split.
+	combinedBranch = "fetch-versions"
+)
+
+// publisher commits the files of PR groups to a branch of a GitHub repository with the
+// Git Data API, and opens a PR for the branch or updates the PR already open for it.
+type publisher struct {
+	logger *slog.Logger
+	client *github.Client
+	owner  string
+	repo   string
+	// base is the branch the branches are created from and the PRs are opened against.
+	base   string
+	labels []string
+}
+
+// newPublisher returns a publisher for the repository ("owner/repo").
+func newPublisher(logger *slog.Logger, client *github.Client, repository string, base string, labels []string) (*publisher, error) {
+	owner, repo, ok := strings.Cut(repository, "/")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return nil, fmt.Errorf("invalid repository %q: must be owner/repo", repository)
+	}
+	return &publisher{
+		logger: logger,
+		client: client,
+		owner:  owner,
+		repo:   repo,
+		base:   base,
+		labels: labels,
+	}, nil
+}
+
+// publishGroups publishes each group with files, reading them relative to root.
+func (p *publisher) publishGroups(ctx context.Context, root string, groups []*prGroup) error {
+	for _, group := range groups {
+		if len(group.Files) == 0 {
+			p.logger.InfoContext(ctx, "nothing to publish", slog.String("branch", group.Branch))
+			continue
+		}
+		if _, err := p.publish(ctx, root, group); err != nil {
+			return fmt.Errorf("failed to publish %s: %w", group.Name, err)
+		}
+	}
+	return nil
+}
+
+// publish commits the files of the group on top of the base branch to the group's
+// branch, replacing any earlier commit of the fetcher, and opens or updates its PR.
+// Files are read relative to root. It returns the PR, or nil if the branch was left
+// unchanged because it has commits not from the fetcher (see updateBranch).
+func (p *publisher) publish(ctx context.Context, root string, group *prGroup) (*github.PullRequest, error) {
+	baseRef, _, err := p.client.Git.GetRef(ctx, p.owner, p.repo, "heads/"+p.base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get branch %s: %w", p.base, err)
+	}
+	baseCommit, _, err := p.client.Git.GetCommit(ctx, p.owner, p.repo, baseRef.GetObject().GetSHA())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit %s: %w", baseRef.GetObject().GetSHA(), err)
+	}
+	entries := make([]*github.TreeEntry, 0, len(group.Files))
+	for _, file := range group.Files {
+		entry, err := p.createBlob(ctx, root, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create blob of %s: %w", file, err)
+		}
+		entries = append(entries, entry)
+	}
+	tree, _, err := p.client.Git.CreateTree(ctx, p.owner, p.repo, baseCommit.GetTree().GetSHA(), entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tree: %w", err)
+	}
+	commit, _, err := p.client.Git.CreateCommit(ctx, p.owner, p.repo, &github.Commit{
+		Message: github.Ptr(group.Title),
+		Tree:    tree,
+		Parents: []*github.Commit{{SHA: baseCommit.SHA}},
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create commit: %w", err)
+	}
+	updated, err := p.updateBranch(ctx, group.Branch, commit)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, nil
+	}
+	pull, err := p.openOrUpdatePull(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.labels) > 0 {
+		if _, _, err := p.client.Issues.AddLabelsToIssue(ctx, p.owner, p.repo, pull.GetNumber(), p.labels); err != nil {
+			return nil, fmt.Errorf("failed to label PR #%d: %w", pull.GetNumber(), err)
+		}
+	}
+	return pull, nil
+}
+
+// createBlob creates the blob of a file relative to root, returning its tree entry.
+func (p *publisher) createBlob(ctx context.Context, root string, file string) (*github.TreeEntry, error) {
+	path := filepath.Join(root, filepath.FromSlash(file))
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	blob, _, err := p.client.Git.CreateBlob(ctx, p.owner, p.repo, &github.Blob{
+		Content:  github.Ptr(base64.StdEncoding.EncodeToString(content)),
+		Encoding: github.Ptr("base64"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	mode := "100644"
+	if info.Mode().Perm()&0111 != 0 {
+		mode = "100755"
+	}
+	return &github.TreeEntry{
+		Path: github.Ptr(file),
+		Mode: github.Ptr(mode),
+		Type: github.Ptr("blob"),
+		SHA:  blob.SHA,
+	}, nil
+}
+
+// updateBranch points the branch to the commit, creating the branch if needed. An
+// existing branch is only replaced if all its commits since the base branch have the
+// same committer as the commit, i.e. were created by the fetcher: the commits pushed
+// by reviewers are never discarded. It returns false if the branch was left unchanged.
+func (p *publisher) updateBranch(ctx context.Context, branch string, commit *github.Commit) (bool, error) {
+	ref := &github.Reference{
+		Ref:    github.Ptr("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: commit.SHA},
+	}
+	existing, _, err := p.client.Git.GetRef(ctx, p.owner, p.repo, "heads/"+branch)
+	switch {
+	case fetchclient.IsGitHubNotFound(err):
+		if _, _, err := p.client.Git.CreateRef(ctx, p.owner, p.repo, ref); err != nil {
+			return false, fmt.Errorf("failed to create branch %s: %w", branch, err)
+		}
+		p.logger.InfoContext(ctx, "created branch", slog.String("branch", branch), slog.String("sha", commit.GetSHA()))
+	case err != nil:
+		return false, fmt.Errorf("failed to get branch %s: %w", branch, err)
+	default:
+		foreign, err := p.foreignCommits(ctx, existing.GetObject().GetSHA(), commit)
+		if err != nil {
+			return false, fmt.Errorf("failed to compare branch %s: %w", branch, err)
+		}
+		if len(foreign) > 0 {
+			p.logger.WarnContext(
+				ctx,
+				"not updating branch: it has commits not from the fetcher, merge or delete it to let the fetcher update it",
+				slog.String("branch", branch),
+				slog.Any("commits", foreign),
+			)
+			return false, nil
+		}
+		// The branch only holds the fetcher's commit, which is rebuilt on the latest base.
+		if _, _, err := p.client.Git.UpdateRef(ctx, p.owner, p.repo, ref, true); err != nil {
+			return false, fmt.Errorf("failed to update branch %s: %w", branch, err)
+		}
+		p.logger.InfoContext(ctx, "updated branch", slog.String("branch", branch), slog.String("sha", commit.GetSHA()))
+	}
+	return true, nil
+}
+
+// foreignCommits returns the SHAs of the commits of head since the base branch whose
+// committer differs from the committer of the fetcher's commit.
+func (p *publisher) foreignCommits(ctx context.Context, head string, commit *github.Commit) ([]string, error) {
+	comparison, _, err := p.client.Repositories.CompareCommits(ctx, p.owner, p.repo, p.base, head, nil)
+	if err != nil {
+		return nil, err
+	}
+	var foreign []string
+	for _, existing := range comparison.Commits {
+		if existing.GetCommit().GetCommitter().GetEmail() != commit.GetCommitter().GetEmail() {
+			foreign = append(foreign, existing.GetSHA())
+		}
+	}
+	return foreign, nil
+}
+
+// openOrUpdatePull opens a PR for the group's branch, or updates the title and body
+// of the PR already open for it.
+func (p *publisher) openOrUpdatePull(ctx context.Context, group *prGroup) (*github.PullRequest, error) {
+	pulls, _, err := p.client.PullRequests.List(ctx, p.owner, p.repo, &github.PullRequestListOptions{
+		Head:  p.owner + ":" + group.Branch,
+		Base:  p.base,
+		State: "open",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PRs of branch %s: %w", group.Branch, err)
+	}
+	if len(pulls) > 0 {
+		pull, _, err := p.client.PullRequests.Edit(ctx, p.owner, p.repo, pulls[0].GetNumber(), &github.PullRequest{
+			Title: github.Ptr(group.Title),
+			Body:  github.Ptr(group.Body),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to update PR #%d: %w", pulls[0].GetNumber(), err)
+		}
+		p.logger.InfoContext(ctx, "updated PR", slog.String("url", pull.GetHTMLURL()))
+		return pull, nil
+	}
+	pull, _, err := p.client.PullRequests.Create(ctx, p.owner, p.repo, &github.NewPullRequest{
+		Title: github.Ptr(group.Title),
+		Head:  github.Ptr(group.Branch),
+		Base:  github.Ptr(p.base),
+		Body:  github.Ptr(group.Body),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PR for branch %s: %w", group.Branch, err)
+	}
+	p.logger.InfoContext(ctx, "opened PR", slog.String("url", pull.GetHTMLURL()))
+	return pull, nil
+}
+
+// combinedPRGroup returns the group of all the created plugins and the source.yaml
+// files updated by --quarantine, with the PR title and body.
+func combinedPRGroup(root string, created []createdPlugin, quarantined []*quarantinedPlugin, title string, body string) (*prGroup, error) {
+	group := &prGroup{
+		Name:   combinedBranch,
+		Branch: combinedBranch,
+		Title:  title,
+		Body:   body,
+	}
+	for _, plugin := range created {
+		files, err := pluginVersionFiles(root, plugin)
+		if err != nil {
+			return nil, err
+		}
+		group.Plugins = append(group.Plugins, plugin.String())
+		group.Files = append(group.Files, files...)
+	}
+	for _, entry := range quarantined {
+		if entry.Ignored {
+			group.Files = append(group.Files, "plugins/"+entry.Plugin+"/source.yaml")
+		}
+	}
+	return group, nil
+}
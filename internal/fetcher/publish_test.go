@@ -0,0 +1,312 @@
+package fetcher
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGitHub is an in-memory GitHub serving the Git Data, pull request and label APIs
+// of the acme/plugins repository used by the publisher.
+type fakeGitHub struct {
+	mu sync.Mutex
+	// refs maps branches to commit SHAs.
+	refs  map[string]string
+	blobs map[string]string
+	trees map[string][]*github.TreeEntry
+	// commits maps commit SHAs to tree SHAs, and parents and committers map them to
+	// their parent SHA and committer email.
+	commits    map[string]string
+	parents    map[string]string
+	committers map[string]string
+	pulls      []*github.PullRequest
+	labels     map[int][]string
+	// requests are the "METHOD path" of the requests changing the repository.
+	requests []string
+}
+
+func newFakeGitHub(t *testing.T) (*fakeGitHub, *github.Client) {
+	t.Helper()
+	fake := &fakeGitHub{
+		refs:       map[string]string{"main": "base-commit"},
+		blobs:      make(map[string]string),
+		trees:      make(map[string][]*github.TreeEntry),
+		commits:    make(map[string]string),
+		parents:    make(map[string]string),
+		committers: make(map[string]string),
+		labels:     make(map[int][]string),
+	}
+	writeJSON := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		assert.NoError(t, json.NewEncoder(w).Encode(v))
+	}
+	decode := func(r *http.Request, v any) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(v))
+	}
+	const prefix = "/github/repos/acme/plugins"
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+prefix+"/git/ref/heads/{branch...}", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		sha, ok := fake.refs[r.PathValue("branch")]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, &github.Reference{
+			Ref:    github.Ptr("refs/heads/" + r.PathValue("branch")),
+			Object: &github.GitObject{SHA: github.Ptr(sha)},
+		})
+	})
+	mux.HandleFunc("GET "+prefix+"/git/commits/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, &github.Commit{
+			SHA:  github.Ptr(r.PathValue("sha")),
+			Tree: &github.Tree{SHA: github.Ptr(r.PathValue("sha") + "-tree")},
+		})
+	})
+	mux.HandleFunc("POST "+prefix+"/git/blobs", func(w http.ResponseWriter, r *http.Request) {
+		var blob github.Blob
+		decode(r, &blob)
+		assert.Equal(t, "base64", blob.GetEncoding())
+		content, err := base64.StdEncoding.DecodeString(blob.GetContent())
+		assert.NoError(t, err)
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		sha := fmt.Sprintf("blob-%d", len(fake.blobs)+1)
+		fake.blobs[sha] = string(content)
+		writeJSON(w, http.StatusCreated, &github.Blob{SHA: github.Ptr(sha)})
+	})
+	mux.HandleFunc("POST "+prefix+"/git/trees", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			BaseTree string              `json:"base_tree"`
+			Entries  []*github.TreeEntry `json:"tree"`
+		}
+		decode(r, &request)
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		sha := fmt.Sprintf("tree-%d", len(fake.trees)+1)
+		fake.trees[sha] = request.Entries
+		assert.Equal(t, "base-commit-tree", request.BaseTree)
+		writeJSON(w, http.StatusCreated, &github.Tree{SHA: github.Ptr(sha)})
+	})
+	mux.HandleFunc("POST "+prefix+"/git/commits", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Message string   `json:"message"`
+			Tree    string   `json:"tree"`
+			Parents []string `json:"parents"`
+		}
+		decode(r, &request)
+		assert.Equal(t, []string{"base-commit"}, request.Parents)
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		sha := fmt.Sprintf("commit-%d", len(fake.commits)+1)
+		fake.commits[sha] = request.Tree
+		fake.parents[sha] = request.Parents[0]
+		fake.committers[sha] = "fetcher@example.com"
+		writeJSON(w, http.StatusCreated, &github.Commit{
+			SHA:       github.Ptr(sha),
+			Message:   github.Ptr(request.Message),
+			Committer: &github.CommitAuthor{Email: github.Ptr("fetcher@example.com")},
+		})
+	})
+	mux.HandleFunc("GET "+prefix+"/compare/{basehead}", func(w http.ResponseWriter, r *http.Request) {
+		base, head, ok := strings.Cut(r.PathValue("basehead"), "...")
+		assert.True(t, ok)
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		var commits []*github.RepositoryCommit
+		for sha := head; sha != fake.refs[base] && sha != ""; sha = fake.parents[sha] {
+			commits = append(commits, &github.RepositoryCommit{
+				SHA:    github.Ptr(sha),
+				Commit: &github.Commit{Committer: &github.CommitAuthor{Email: github.Ptr(fake.committers[sha])}},
+			})
+		}
+		slices.Reverse(commits)
+		writeJSON(w, http.StatusOK, &github.CommitsComparison{Commits: commits})
+	})
+	mux.HandleFunc("POST "+prefix+"/git/refs", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		}
+		decode(r, &request)
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		fake.requests = append(fake.requests, r.Method+" "+strings.TrimPrefix(r.URL.Path, prefix))
+		fake.refs[strings.TrimPrefix(request.Ref, "refs/heads/")] = request.SHA
+		writeJSON(w, http.StatusCreated, &github.Reference{Ref: github.Ptr(request.Ref)})
+	})
+	mux.HandleFunc("PATCH "+prefix+"/git/refs/heads/{branch...}", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			SHA   string `json:"sha"`
+			Force bool   `json:"force"`
+		}
+		decode(r, &request)
+		assert.True(t, request.Force)
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		fake.requests = append(fake.requests, r.Method+" "+strings.TrimPrefix(r.URL.Path, prefix))
+		fake.refs[r.PathValue("branch")] = request.SHA
+		writeJSON(w, http.StatusOK, &github.Reference{Ref: github.Ptr("refs/heads/" + r.PathValue("branch"))})
+	})
+	mux.HandleFunc("GET "+prefix+"/pulls", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "open", r.URL.Query().Get("state"))
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		pulls := []*github.PullRequest{}
+		for _, pull := range fake.pulls {
+			if "acme:"+pull.GetHead().GetRef() == r.URL.Query().Get("head") && pull.GetState() == "open" {
+				pulls = append(pulls, pull)
+			}
+		}
+		writeJSON(w, http.StatusOK, pulls)
+	})
+	mux.HandleFunc("POST "+prefix+"/pulls", func(w http.ResponseWriter, r *http.Request) {
+		var request github.NewPullRequest
+		decode(r, &request)
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		fake.requests = append(fake.requests, r.Method+" "+strings.TrimPrefix(r.URL.Path, prefix))
+		number := len(fake.pulls) + 1
+		pull := &github.PullRequest{
+			Number:  github.Ptr(number),
+			State:   github.Ptr("open"),
+			Title:   request.Title,
+			Body:    request.Body,
+			HTMLURL: github.Ptr(fmt.Sprintf("https://github.com/acme/plugins/pull/%d", number)),
+			Head:    &github.PullRequestBranch{Ref: request.Head},
+			Base:    &github.PullRequestBranch{Ref: request.Base},
+		}
+		fake.pulls = append(fake.pulls, pull)
+		writeJSON(w, http.StatusCreated, pull)
+	})
+	mux.HandleFunc("PATCH "+prefix+"/pulls/{number}", func(w http.ResponseWriter, r *http.Request) {
+		var request github.PullRequest
+		decode(r, &request)
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		fake.requests = append(fake.requests, r.Method+" "+strings.TrimPrefix(r.URL.Path, prefix))
+		for _, pull := range fake.pulls {
+			if strconv.Itoa(pull.GetNumber()) == r.PathValue("number") {
+				pull.Title, pull.Body = request.Title, request.Body
+				writeJSON(w, http.StatusOK, pull)
+				return
+			}
+		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+	})
+	mux.HandleFunc("POST "+prefix+"/issues/{number}/labels", func(w http.ResponseWriter, r *http.Request) {
+		var labels []string
+		decode(r, &labels)
+		number, err := strconv.Atoi(r.PathValue("number"))
+		assert.NoError(t, err)
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		fake.requests = append(fake.requests, r.Method+" "+strings.TrimPrefix(r.URL.Path, prefix))
+		fake.labels[number] = append(fake.labels[number], labels...)
+		writeJSON(w, http.StatusOK, []*github.Label{})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	client := github.NewClient(srv.Client())
+	var err error
+	client.BaseURL, err = url.Parse(srv.URL + "/github/")
+	require.NoError(t, err)
+	return fake, client
+}
+
+func TestPublish(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	pluginDir := filepath.Join(root, "plugins", "acme", "base")
+	require.NoError(t, os.MkdirAll(filepath.Join(pluginDir, "v2.0.0"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "v2.0.0", "buf.plugin.yaml"), []byte("version: v1\nname: buf.build/acme/base\nplugin_version: v2.0.0\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "v2.0.0", "entrypoint.sh"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "source.yaml"), []byte("source:\n  ignore_versions:\n    - v2.1.0\n"), 0644))
+	created := []createdPlugin{{org: "acme", name: "base", pluginDir: pluginDir, previousVersion: "v1.0.0", newVersion: "v2.0.0"}}
+	quarantined := []*quarantinedPlugin{{Plugin: "acme/base", PreviousVersion: "v2.0.0", Version: "v2.1.0", Ignored: true}}
+	group, err := combinedPRGroup(root, created, quarantined, generatePRTitle(created), generatePRBody(created))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"plugins/acme/base/v2.0.0/buf.plugin.yaml",
+		"plugins/acme/base/v2.0.0/entrypoint.sh",
+		"plugins/acme/base/source.yaml",
+	}, group.Files)
+
+	fake, client := newFakeGitHub(t)
+	ctx := t.Context()
+	p, err := newPublisher(slog.New(slog.NewTextHandler(io.Discard, nil)), client, "acme/plugins", "main", []string{"Feature"})
+	require.NoError(t, err)
+	// A group without files isn't published.
+	require.NoError(t, p.publishGroups(ctx, root, []*prGroup{group, {Name: "empty", Branch: "fetch-versions-empty"}}))
+	assert.Equal(t, []string{
+		"POST /git/refs",
+		"POST /pulls",
+		"POST /issues/1/labels",
+	}, fake.requests)
+	require.Len(t, fake.pulls, 1)
+	assert.Equal(t, "Update acme/base", fake.pulls[0].GetTitle())
+	assert.Equal(t, "fetch-versions", fake.pulls[0].GetHead().GetRef())
+	assert.Equal(t, "main", fake.pulls[0].GetBase().GetRef())
+	assert.Equal(t, []string{"Feature"}, fake.labels[1])
+	tree := fake.trees[fake.commits[fake.refs["fetch-versions"]]]
+	require.Len(t, tree, 3)
+	for _, entry := range tree {
+		content, err := os.ReadFile(filepath.Join(root, entry.GetPath()))
+		require.NoError(t, err)
+		assert.Equal(t, string(content), fake.blobs[entry.GetSHA()], entry.GetPath())
+		if entry.GetPath() == "plugins/acme/base/v2.0.0/entrypoint.sh" {
+			assert.Equal(t, "100755", entry.GetMode())
+		} else {
+			assert.Equal(t, "100644", entry.GetMode())
+		}
+	}
+
+	// Publishing again replaces the commit of the branch and updates the open PR.
+	fake.requests = nil
+	group.Title = "Update acme/base to v2.0.0"
+	pull, err := p.publish(ctx, root, group)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pull.GetNumber())
+	assert.Equal(t, []string{
+		"PATCH /git/refs/heads/fetch-versions",
+		"PATCH /pulls/1",
+		"POST /issues/1/labels",
+	}, fake.requests)
+	assert.Equal(t, "commit-2", fake.refs["fetch-versions"])
+	require.Len(t, fake.pulls, 1)
+	assert.Equal(t, "Update acme/base to v2.0.0", fake.pulls[0].GetTitle())
+
+	// The commits pushed by reviewers to the branch are never discarded.
+	fake.refs["fetch-versions"] = "review-commit"
+	fake.parents["review-commit"] = "commit-2"
+	fake.committers["review-commit"] = "reviewer@example.com"
+	fake.requests = nil
+	group.Title = "Update acme/base to v2.0.1"
+	pull, err = p.publish(ctx, root, group)
+	require.NoError(t, err)
+	assert.Nil(t, pull)
+	assert.Empty(t, fake.requests)
+	assert.Equal(t, "review-commit", fake.refs["fetch-versions"])
+	assert.Equal(t, "Update acme/base to v2.0.0", fake.pulls[0].GetTitle())
+
+	_, err = newPublisher(p.logger, client, "plugins", "main", nil)
+	require.Error(t, err)
+}
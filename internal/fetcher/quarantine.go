@@ -0,0 +1,160 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufremoteplugin/bufremotepluginconfig"
+	"github.com/bufbuild/buf/private/pkg/encoding"
+
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+// quarantinedPlugin is a created plugin version removed from the batch because its
+// post-processing or tests failed, or because it depends on a version which failed.
+type quarantinedPlugin struct {
+	// Plugin is the org and name of the plugin (such as "connectrpc/go").
+	Plugin          string `json:"plugin"`
+	PreviousVersion string `json:"previous_version"`
+	Version         string `json:"version"`
+	Reason          string `json:"reason"`
+	// Ignored is set if the version was added to the ignore_versions of the plugin's
+	// source.yaml (see --quarantine), so later runs don't create it again.
+	Ignored bool `json:"ignored"`
+}
+
+// testCreatedPlugins checks each created plugin independently, in the order they were
+// created (dependencies first). The check may update the plugin (see runPostProcessSteps).
+// Versions failing the check are removed, along with the created versions depending on
+// them, so that the remaining versions can still be released. If ignoreFailing is set,
+// the versions failing the check are added to the ignore_versions of their source.yaml.
+// It returns the remaining plugins and the removed ones.
+func testCreatedPlugins(
+	ctx context.Context,
+	logger *slog.Logger,
+	plugins []createdPlugin,
+	check func(context.Context, *createdPlugin) error,
+	ignoreFailing bool,
+) ([]createdPlugin, []*quarantinedPlugin, error) {
+	var (
+		passed      []createdPlugin
+		quarantined []*quarantinedPlugin
+		// removedRefs are the references ("buf.build/org/name:version") of the removed versions.
+		removedRefs = make(map[string]struct{})
+	)
+	for _, plugin := range plugins {
+		versionDir := filepath.Join(plugin.pluginDir, plugin.newVersion)
+		config, err := readPluginConfig(versionDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		entry := &quarantinedPlugin{
+			Plugin:          plugin.org + "/" + plugin.name,
+			PreviousVersion: plugin.previousVersion,
+			Version:         plugin.newVersion,
+		}
+		for _, dep := range config.Deps {
+			if _, ok := removedRefs[dep.Plugin]; ok {
+				entry.Reason = "depends on quarantined " + dep.Plugin
+				break
+			}
+		}
+		if entry.Reason == "" {
+			start := time.Now()
+			logger.InfoContext(ctx, "starting checking plugin", slog.Any("plugin", plugin))
+			err := check(ctx, &plugin)
+			logger.InfoContext(ctx, "finished checking plugin", slog.Any("plugin", plugin), slog.Duration("duration", time.Since(start)))
+			if err == nil {
+				passed = append(passed, plugin)
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			entry.Reason = err.Error()
+			entry.Ignored = ignoreFailing
+		}
+		logger.ErrorContext(
+			ctx,
+			"quarantining plugin version",
+			slog.String("path", versionDir),
+			slog.String("reason", entry.Reason),
+		)
+		if err := os.RemoveAll(versionDir); err != nil {
+			return nil, nil, err
+		}
+		removedRefs[config.Name+":"+plugin.newVersion] = struct{}{}
+		if entry.Ignored {
+			reason := fmt.Sprintf("Quarantined by the fetcher on %s: %s", time.Now().UTC().Format(time.DateOnly), entry.Reason)
+			if err := ignorePluginVersion(plugin.pluginDir, plugin.newVersion, reason); err != nil {
+				return nil, nil, fmt.Errorf("failed to ignore %s: %w", plugin, err)
+			}
+		}
+		quarantined = append(quarantined, entry)
+	}
+	return passed, quarantined, nil
+}
+
+// readPluginConfig reads the buf.plugin.yaml of a plugin version directory.
+func readPluginConfig(versionDir string) (*bufremotepluginconfig.ExternalConfig, error) {
+	content, err := os.ReadFile(filepath.Join(versionDir, "buf.plugin.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var config bufremotepluginconfig.ExternalConfig
+	if err := encoding.UnmarshalJSONOrYAMLStrict(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse buf.plugin.yaml: %w", err)
+	}
+	return &config, nil
+}
+
+// ignorePluginVersion adds the version to the ignore_versions of the plugin's source.yaml.
+func ignorePluginVersion(pluginDir string, version string, reason string) error {
+	sourceYAMLPath := filepath.Join(pluginDir, "source.yaml")
+	content, err := os.ReadFile(sourceYAMLPath)
+	if err != nil {
+		return err
+	}
+	content, err = source.IgnoreVersion(content, version, reason)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", sourceYAMLPath, err)
+	}
+	return os.WriteFile(sourceYAMLPath, content, 0644) //nolint:gosec
+}
+
+// generateQuarantineReport generates a markdown section of the PR body listing the
+// quarantined plugin versions.
+func generateQuarantineReport(quarantined []*quarantinedPlugin) string {
+	if len(quarantined) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("### Quarantined\n")
+	sb.WriteString("These versions were not created because their post-processing or tests failed, or because they depend on a quarantined version.\n\n")
+	for _, entry := range quarantined {
+		fmt.Fprintf(&sb, "- %s: %s → %s: %s", entry.Plugin, entry.PreviousVersion, entry.Version, entry.Reason)
+		if entry.Ignored {
+			sb.WriteString(" (added to `ignore_versions`)")
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// marshalQuarantineReport returns the quarantined plugin versions as JSON.
+func marshalQuarantineReport(quarantined []*quarantinedPlugin) (string, error) {
+	if quarantined == nil {
+		quarantined = []*quarantinedPlugin{}
+	}
+	data, err := json.Marshal(quarantined)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
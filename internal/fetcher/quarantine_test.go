@@ -0,0 +1,144 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+func TestTestCreatedPluginsQuarantinesFailures(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	setupTestRepository(t, tmpDir)
+	fetcher := &mockFetcher{
+		versions: map[string]string{
+			"github-test-base-plugin":     "v2.0.0",
+			"github-test-consumer-plugin": "v2.0.0",
+		},
+	}
+	created, err := run(t.Context(), newTestContainer(t, tmpDir), fetcher, &flags{})
+	require.NoError(t, err)
+	require.Len(t, created, 2)
+	var tested []string
+	failBasePlugin := func(_ context.Context, plugin createdPlugin) error {
+		tested = append(tested, plugin.String())
+		if plugin.name == "base-plugin" {
+			return errors.New("exit status 2")
+		}
+		return nil
+	}
+	logger := slog.New(slog.NewTextHandler(testWriter{t}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	passed, quarantined, err := testCreatedPlugins(t.Context(), logger, created, postProcessAndTest(logger, nil, failBasePlugin), true)
+	require.NoError(t, err)
+	assert.Empty(t, passed)
+	// The consumer depends on the failing version, so it isn't tested.
+	assert.Equal(t, []string{"test/base-plugin:v2.0.0"}, tested)
+	assert.Equal(t, []*quarantinedPlugin{
+		{Plugin: "test/base-plugin", PreviousVersion: "v1.0.0", Version: "v2.0.0", Reason: "make test failed: exit status 2", Ignored: true},
+		{Plugin: "test/consumer-plugin", PreviousVersion: "v1.0.0", Version: "v2.0.0", Reason: "depends on quarantined buf.build/test/base-plugin:v2.0.0"},
+	}, quarantined)
+	for _, name := range []string{"base-plugin", "consumer-plugin"} {
+		ok, err := checkDirExists(filepath.Join(tmpDir, "plugins", "test", name, "v2.0.0"))
+		require.NoError(t, err)
+		assert.False(t, ok, name)
+	}
+	// Only the failing version is ignored by later runs.
+	for name, ignored := range map[string]bool{"base-plugin": true, "consumer-plugin": false} {
+		content, err := os.ReadFile(filepath.Join(tmpDir, "plugins", "test", name, "source.yaml"))
+		require.NoError(t, err)
+		config, err := source.NewConfig(bytes.NewReader(content))
+		require.NoError(t, err)
+		assert.Equal(t, ignored, slices.Contains(config.Source.IgnoreVersions, "v2.0.0"), name)
+	}
+	report := generateQuarantineReport(quarantined)
+	assert.Contains(t, report, "- test/base-plugin: v1.0.0 → v2.0.0: make test failed: exit status 2 (added to `ignore_versions`)\n")
+	assert.Contains(t, report, "- test/consumer-plugin: v1.0.0 → v2.0.0: depends on quarantined buf.build/test/base-plugin:v2.0.0")
+}
+
+func TestTestCreatedPluginsQuarantinesPostProcessFailures(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	setupTestRepository(t, tmpDir)
+	fetcher := &mockFetcher{
+		versions: map[string]string{
+			"github-test-base-plugin":     "v2.0.0",
+			"github-test-consumer-plugin": "v2.0.0",
+		},
+	}
+	created, err := run(t.Context(), newTestContainer(t, tmpDir), fetcher, &flags{})
+	require.NoError(t, err)
+	require.Len(t, created, 2)
+	require.Equal(t, "base-plugin", created[0].name)
+	// The go.mod of the base plugin's Go dependency can't be fetched, so its post-processing fails.
+	pluginYAML := filepath.Join(created[0].pluginDir, created[0].newVersion, "buf.plugin.yaml")
+	content, err := os.ReadFile(pluginYAML)
+	require.NoError(t, err)
+	content = append(content, "registry:\n  go:\n    deps:\n      - module: example.com/dep\n        version: v1.0.0\n"...)
+	require.NoError(t, os.WriteFile(pluginYAML, content, 0644))
+	created[0].postProcess = []source.PostProcessStep{{Name: source.PostProcessGoMinVersion}}
+	client := &http.Client{Transport: &mockHTTPTransport{}}
+	var tested []string
+	passAll := func(_ context.Context, plugin createdPlugin) error {
+		tested = append(tested, plugin.String())
+		return nil
+	}
+	logger := slog.New(slog.NewTextHandler(testWriter{t}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	passed, quarantined, err := testCreatedPlugins(t.Context(), logger, created, postProcessAndTest(logger, client, passAll), true)
+	require.NoError(t, err)
+	assert.Empty(t, passed)
+	assert.Empty(t, tested)
+	require.Len(t, quarantined, 2)
+	assert.Equal(t, "test/base-plugin", quarantined[0].Plugin)
+	assert.Contains(t, quarantined[0].Reason, "post-processing failed: failed to run post_process step go_min_version for test/base-plugin:v2.0.0: failed to fetch go.mod for example.com/dep@v1.0.0")
+	assert.True(t, quarantined[0].Ignored)
+	assert.Equal(t, "depends on quarantined buf.build/test/base-plugin:v2.0.0", quarantined[1].Reason)
+	ok, err := checkDirExists(filepath.Join(tmpDir, "plugins", "test", "base-plugin", "v2.0.0"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestTestCreatedPluginsKeepsPassingPlugins(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	setupTestRepository(t, tmpDir)
+	fetcher := &mockFetcher{
+		versions: map[string]string{
+			"github-test-base-plugin":     "v2.0.0",
+			"github-test-consumer-plugin": "v2.0.0",
+		},
+	}
+	created, err := run(t.Context(), newTestContainer(t, tmpDir), fetcher, &flags{})
+	require.NoError(t, err)
+	failConsumerPlugin := func(_ context.Context, plugin createdPlugin) error {
+		if plugin.name == "consumer-plugin" {
+			return errors.New("exit status 2")
+		}
+		return nil
+	}
+	logger := slog.New(slog.NewTextHandler(testWriter{t}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	passed, quarantined, err := testCreatedPlugins(t.Context(), logger, created, postProcessAndTest(logger, nil, failConsumerPlugin), false)
+	require.NoError(t, err)
+	require.Len(t, passed, 1)
+	assert.Equal(t, "base-plugin", passed[0].name)
+	require.Len(t, quarantined, 1)
+	assert.Equal(t, "test/consumer-plugin", quarantined[0].Plugin)
+	assert.False(t, quarantined[0].Ignored)
+	ok, err := checkDirExists(filepath.Join(tmpDir, "plugins", "test", "base-plugin", "v2.0.0"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	// Without --quarantine, the source.yaml is unchanged and a later run retries the version.
+	content, err := os.ReadFile(filepath.Join(tmpDir, "plugins", "test", "consumer-plugin", "source.yaml"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "ignore_versions")
+}
@@ -0,0 +1,196 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	// splitByOrg groups the created plugins by org (or plugin name for community plugins).
+	splitByOrg = "org"
+	// splitByClosure groups the created plugins released in lockstep: plugins sharing
+	// a source, such as the protocolbuffers plugins.
+	splitByClosure = "closure"
+	// splitByPlugin groups each created plugin on its own.
+	splitByPlugin = "plugin"
+	// splitBranchPrefix prefixes the branch name of each group.
+	splitBranchPrefix = "fetch-versions-"
+	// quarantineGroupName is the name of the group of the source.yaml files updated to
+	// ignore quarantined versions.
+	quarantineGroupName = "quarantine"
+)
+
+// prGroup is a group of created plugins opened as their own PR (see --split-by).
+type prGroup struct {
+	// Name identifies the group: the org, the plugin name for community plugins, or
+	// "org/name" when split by closure or by plugin.
+	Name   string `json:"name"`
+	Branch string `json:"branch"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	// Plugins are the created plugin versions (such as "connectrpc/go:v1.19.0").
+	Plugins []string `json:"plugins"`
+	// Files are the paths of the files of the group, relative to the repository root.
+	Files []string `json:"files"`
+}
+
+// validateSplitBy returns an error if the --split-by mode is unknown.
+func validateSplitBy(splitBy string) error {
+	switch splitBy {
+	case "", splitByOrg, splitByClosure, splitByPlugin:
+		return nil
+	}
+	return fmt.Errorf("unknown --split-by %q: must be one of %q, %q, or %q", splitBy, splitByOrg, splitByClosure, splitByPlugin)
+}
+
+// splitCreatedPlugins partitions the created plugins into groups which can be merged
+// independently: a plugin depending on the new version of another created plugin is
+// always in the same group. The source.yaml files updated to ignore quarantined
+// versions are in a group of their own. The files of each group are relative to root,
+// which must be an absolute path.
+func splitCreatedPlugins(root string, created []createdPlugin, quarantined []*quarantinedPlugin, splitBy string) ([]*prGroup, error) {
+	// parents is a union-find forest of the indexes of the created plugins.
+	parents := make([]int, len(created))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+	union := func(i, j int) {
+		// The group is named after its first created plugin.
+		i, j = find(i), find(j)
+		parents[max(i, j)] = min(i, j)
+	}
+	firstByKey := make(map[string]int)
+	refs := make(map[string]int)
+	for i, plugin := range created {
+		var key string
+		switch splitBy {
+		case splitByOrg:
+			key = pluginGroupName(plugin)
+		case splitByClosure:
+			key = plugin.source
+		case splitByPlugin:
+			key = plugin.org + "/" + plugin.name
+		default:
+			return nil, validateSplitBy(splitBy)
+		}
+		if first, ok := firstByKey[key]; ok {
+			union(first, i)
+		} else {
+			firstByKey[key] = i
+		}
+		config, err := readPluginConfig(filepath.Join(plugin.pluginDir, plugin.newVersion))
+		if err != nil {
+			return nil, err
+		}
+		refs[config.Name+":"+plugin.newVersion] = i
+		// Created plugins are in dependency order, so dependencies are already known.
+		for _, dep := range config.Deps {
+			if j, ok := refs[dep.Plugin]; ok {
+				union(i, j)
+			}
+		}
+	}
+	var (
+		groups       []*prGroup
+		groupPlugins [][]createdPlugin
+		groupIndexes = make(map[int]int)
+	)
+	for i, plugin := range created {
+		index, ok := groupIndexes[find(i)]
+		if !ok {
+			index = len(groups)
+			groupIndexes[find(i)] = index
+			name := plugin.org + "/" + plugin.name
+			if splitBy == splitByOrg {
+				name = pluginGroupName(plugin)
+			}
+			groups = append(groups, &prGroup{Name: name, Branch: splitBranchPrefix + strings.ReplaceAll(name, "/", "-")})
+			groupPlugins = append(groupPlugins, nil)
+		}
+		groupPlugins[index] = append(groupPlugins[index], plugin)
+		groups[index].Plugins = append(groups[index].Plugins, plugin.String())
+		files, err := pluginVersionFiles(root, plugin)
+		if err != nil {
+			return nil, err
+		}
+		groups[index].Files = append(groups[index].Files, files...)
+	}
+	for i, group := range groups {
+		group.Title = generatePRTitle(groupPlugins[i])
+		group.Body = generatePRBody(groupPlugins[i])
+	}
+	var ignored []*quarantinedPlugin
+	for _, entry := range quarantined {
+		if entry.Ignored {
+			ignored = append(ignored, entry)
+		}
+	}
+	if len(ignored) > 0 {
+		group := &prGroup{
+			Name:   quarantineGroupName,
+			Branch: splitBranchPrefix + quarantineGroupName,
+			Title:  "Ignore plugin versions failing tests",
+			Body:   generateQuarantineReport(ignored),
+		}
+		for _, entry := range ignored {
+			group.Plugins = append(group.Plugins, entry.Plugin+":"+entry.Version)
+			group.Files = append(group.Files, "plugins/"+entry.Plugin+"/source.yaml")
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// pluginVersionFiles returns the files of a created plugin version relative to the
+// repository root: the version directory and the test data generated by its tests.
+func pluginVersionFiles(root string, plugin createdPlugin) ([]string, error) {
+	var files []string
+	for _, dir := range []string{
+		filepath.Join(plugin.pluginDir, plugin.newVersion),
+		filepath.Join(root, "tests", "testdata", "buf.build", plugin.org, plugin.name, plugin.newVersion),
+	} {
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			relativePath, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(relativePath))
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// writePRGroups writes the groups as the pr_groups GitHub output.
+func writePRGroups(groups []*prGroup) error {
+	if groups == nil {
+		groups = []*prGroup{}
+	}
+	data, err := json.Marshal(groups)
+	if err != nil {
+		return err
+	}
+	return writeGitHubOutput("pr_groups", string(data))
+}
@@ -0,0 +1,94 @@
+package fetcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCreatedPlugins(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	newCreatedPlugin := func(org string, name string, sourceKey string, deps ...string) createdPlugin {
+		pluginDir := filepath.Join(root, "plugins", org, name)
+		require.NoError(t, os.MkdirAll(filepath.Join(pluginDir, "v2.0.0"), 0755))
+		pluginYAML := fmt.Sprintf("version: v1\nname: buf.build/%s/%s\nplugin_version: v2.0.0\n", org, name)
+		if len(deps) > 0 {
+			pluginYAML += "deps:\n"
+			for _, dep := range deps {
+				pluginYAML += "  - plugin: " + dep + "\n"
+			}
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "v2.0.0", "buf.plugin.yaml"), []byte(pluginYAML), 0644))
+		return createdPlugin{org: org, name: name, pluginDir: pluginDir, previousVersion: "v1.0.0", newVersion: "v2.0.0", source: sourceKey}
+	}
+	created := []createdPlugin{
+		newCreatedPlugin("acme", "base", "github-acme-base"),
+		newCreatedPlugin("other", "a", "github-other-protoc-gen"),
+		newCreatedPlugin("acme", "consumer", "github-acme-consumer", "buf.build/acme/base:v2.0.0"),
+		newCreatedPlugin("other", "b", "github-other-protoc-gen"),
+		newCreatedPlugin("community", "foo-bar", "npm_registry-foo-bar"),
+	}
+	sumDir := filepath.Join(root, "tests", "testdata", "buf.build", "acme", "base", "v2.0.0", "eliza")
+	require.NoError(t, os.MkdirAll(sumDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(sumDir, "plugin.sum"), []byte("h1:abc\n"), 0644))
+	quarantined := []*quarantinedPlugin{
+		{Plugin: "other/c", PreviousVersion: "v1.0.0", Version: "v2.0.0", Reason: "make test failed: exit status 2", Ignored: true},
+		{Plugin: "other/d", PreviousVersion: "v1.0.0", Version: "v2.0.0", Reason: "make test failed: exit status 2"},
+	}
+
+	groupPlugins := func(groups []*prGroup) map[string][]string {
+		result := make(map[string][]string)
+		for _, group := range groups {
+			result[group.Name] = group.Plugins
+		}
+		return result
+	}
+	// Dependent plugins are never split, whatever the mode.
+	groups, err := splitCreatedPlugins(root, created, nil, splitByPlugin)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"acme/base":         {"acme/base:v2.0.0", "acme/consumer:v2.0.0"},
+		"other/a":           {"other/a:v2.0.0"},
+		"other/b":           {"other/b:v2.0.0"},
+		"community/foo-bar": {"community/foo-bar:v2.0.0"},
+	}, groupPlugins(groups))
+	groups, err = splitCreatedPlugins(root, created, nil, splitByClosure)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"acme/base":         {"acme/base:v2.0.0", "acme/consumer:v2.0.0"},
+		"other/a":           {"other/a:v2.0.0", "other/b:v2.0.0"},
+		"community/foo-bar": {"community/foo-bar:v2.0.0"},
+	}, groupPlugins(groups))
+	groups, err = splitCreatedPlugins(root, created, quarantined, splitByOrg)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"acme":       {"acme/base:v2.0.0", "acme/consumer:v2.0.0"},
+		"other":      {"other/a:v2.0.0", "other/b:v2.0.0"},
+		"foo-bar":    {"community/foo-bar:v2.0.0"},
+		"quarantine": {"other/c:v2.0.0"},
+	}, groupPlugins(groups))
+
+	require.Len(t, groups, 4)
+	acme := groups[0]
+	assert.Equal(t, "fetch-versions-acme", acme.Branch)
+	assert.Equal(t, "Update acme/base and acme/consumer", acme.Title)
+	assert.Equal(t, "### acme\n- base: v1.0.0 → v2.0.0 ⚠️ **major version bump**\n- consumer: v1.0.0 → v2.0.0 ⚠️ **major version bump**", acme.Body)
+	assert.Equal(t, []string{
+		"plugins/acme/base/v2.0.0/buf.plugin.yaml",
+		"tests/testdata/buf.build/acme/base/v2.0.0/eliza/plugin.sum",
+		"plugins/acme/consumer/v2.0.0/buf.plugin.yaml",
+	}, acme.Files)
+	assert.Equal(t, "fetch-versions-foo-bar", groups[2].Branch)
+	quarantine := groups[3]
+	assert.Equal(t, "fetch-versions-quarantine", quarantine.Branch)
+	assert.Equal(t, []string{"plugins/other/c/source.yaml"}, quarantine.Files)
+	assert.Contains(t, quarantine.Body, "- other/c: v1.0.0 → v2.0.0: make test failed: exit status 2 (added to `ignore_versions`)")
+
+	_, err = splitCreatedPlugins(root, created, nil, "repository")
+	require.Error(t, err)
+}
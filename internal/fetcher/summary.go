@@ -0,0 +1,144 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// sourceStatusCreated is a source with a new plugin version.
+	sourceStatusCreated = "created"
+	// sourceStatusUpToDate is a source whose latest version is already in the repository.
+	sourceStatusUpToDate = "up_to_date"
+	// sourceStatusSkipped is a source not updated in this run, such as a disabled source
+	// or a version deferred until its upstream artifacts are published.
+	sourceStatusSkipped = "skipped"
+	// sourceStatusQuarantined is a source whose new version was removed after its tests failed.
+	sourceStatusQuarantined = "quarantined"
+	// sourceStatusError is a source which couldn't be fetched.
+	sourceStatusError = "error"
+)
+
+// fetchSummary is the outcome of each source.yaml of a fetcher run (see --summary).
+type fetchSummary struct {
+	Sources []*sourceSummary `json:"sources"`
+}
+
+// sourceSummary is the outcome of a source.yaml of a fetcher run.
+type sourceSummary struct {
+	// Plugin is the org and name of the plugin (such as "connectrpc/go").
+	Plugin string `json:"plugin"`
+	// Source is the cache key of the source (such as "github-connectrpc-connect-go").
+	Source string `json:"source"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+	// CurrentVersion is the latest version of the plugin in the repository.
+	CurrentVersion string `json:"current_version,omitempty"`
+	// LatestVersion is the latest version found upstream.
+	LatestVersion string `json:"latest_version,omitempty"`
+	// VersionsSeen are the versions observed upstream, in ascending order, including
+	// the ignored and withdrawn ones.
+	VersionsSeen []string `json:"versions_seen,omitempty"`
+}
+
+// record records the outcome of the source.yaml of a plugin directory, replacing any
+// earlier outcome. It does nothing if the summary is nil.
+func (s *fetchSummary) record(pluginDir string, entry sourceSummary) {
+	if s == nil {
+		return
+	}
+	entry.Plugin = filepath.Base(filepath.Dir(pluginDir)) + "/" + filepath.Base(pluginDir)
+	for i, existing := range s.Sources {
+		if existing.Plugin == entry.Plugin {
+			s.Sources[i] = &entry
+			return
+		}
+	}
+	s.Sources = append(s.Sources, &entry)
+}
+
+// recordPending records the outcome of a pending plugin version.
+func (s *fetchSummary) recordPending(pending *pluginToCreate, status string, reason string) {
+	s.record(pending.pluginDir, sourceSummary{
+		Source:         pending.config.CacheKey(),
+		Status:         status,
+		Reason:         reason,
+		CurrentVersion: pending.previousVersion,
+		LatestVersion:  pending.newVersion,
+		VersionsSeen:   pending.versionsSeen,
+	})
+}
+
+// recordQuarantined records a created plugin version removed after its tests failed.
+func (s *fetchSummary) recordQuarantined(quarantined *quarantinedPlugin) {
+	for _, entry := range s.Sources {
+		if entry.Plugin == quarantined.Plugin {
+			entry.Status, entry.Reason = sourceStatusQuarantined, quarantined.Reason
+		}
+	}
+}
+
+// count returns the number of sources with the status.
+func (s *fetchSummary) count(status string) int {
+	var n int
+	for _, entry := range s.Sources {
+		if entry.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// checkErrorThresholds returns an error if more than maxErrors sources failed (unless
+// maxErrors is negative), or if the ratio of failed sources exceeds maxErrorRatio.
+func (s *fetchSummary) checkErrorThresholds(maxErrors int, maxErrorRatio float64) error {
+	failed := s.count(sourceStatusError)
+	if failed == 0 {
+		return nil
+	}
+	if maxErrors >= 0 && failed > maxErrors {
+		return fmt.Errorf("%d sources failed, more than --max-source-errors=%d", failed, maxErrors)
+	}
+	if ratio := float64(failed) / float64(len(s.Sources)); ratio > maxErrorRatio {
+		return fmt.Errorf("%d of %d sources failed, more than --max-source-error-ratio=%g", failed, len(s.Sources), maxErrorRatio)
+	}
+	return nil
+}
+
+// reportSummary logs the sources which failed and the number of sources of each
+// status, writes the summary to the --summary file if set, and returns an error if the
+// failed sources exceed the --max-source-errors or --max-source-error-ratio thresholds.
+func reportSummary(ctx context.Context, logger *slog.Logger, f *flags, summary *fetchSummary) error {
+	attrs := make([]any, 0, 5)
+	for _, status := range []string{sourceStatusCreated, sourceStatusUpToDate, sourceStatusSkipped, sourceStatusQuarantined, sourceStatusError} {
+		attrs = append(attrs, slog.Int(status, summary.count(status)))
+	}
+	logger.InfoContext(ctx, "fetched sources", attrs...)
+	for _, entry := range summary.Sources {
+		if entry.Status == sourceStatusError {
+			logger.ErrorContext(ctx, "source failed", slog.String("plugin", entry.Plugin), slog.String("source", entry.Source), slog.String("reason", entry.Reason))
+		}
+	}
+	if f.summary != "" {
+		if err := writeSummary(f.summary, summary); err != nil {
+			return fmt.Errorf("failed to write summary: %w", err)
+		}
+	}
+	return summary.checkErrorThresholds(f.maxSourceErrors, f.maxSourceErrorRatio)
+}
+
+// writeSummary writes the summary as indented JSON to the file.
+func writeSummary(filename string, summary *fetchSummary) error {
+	if summary.Sources == nil {
+		summary.Sources = []*sourceSummary{}
+	}
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0644) //nolint:gosec
+}
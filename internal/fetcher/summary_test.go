@@ -0,0 +1,122 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bufbuild/plugins/internal/fetchclient"
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+func TestFetchPendingCreationsSummary(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	newConfig := func(name string, modify func(*source.Source)) *source.Config {
+		pluginDir := filepath.Join(tmpDir, "plugins", "test", name)
+		require.NoError(t, os.MkdirAll(filepath.Join(pluginDir, "v1.0.0"), 0755))
+		config := &source.Config{
+			Filename: filepath.Join(pluginDir, "source.yaml"),
+			Source:   source.Source{GitHub: &source.GitHubConfig{Owner: "test", Repository: name}},
+		}
+		if modify != nil {
+			modify(&config.Source)
+		}
+		return config
+	}
+	updateFrequency := source.Duration(30 * 24 * time.Hour)
+	configs := []*source.Config{
+		newConfig("pending", nil),
+		newConfig("up-to-date", nil),
+		newConfig("disabled", func(s *source.Source) { s.Disabled = true }),
+		newConfig("infrequent", func(s *source.Source) { s.UpdateFrequency = &updateFrequency }),
+		newConfig("ignored", func(s *source.Source) { s.IgnoreVersions = []string{"v2.0.0"} }),
+		newConfig("prerelease", nil),
+		newConfig("unavailable", nil),
+	}
+	fetcher := &failingFetcher{
+		mockFetcher: mockFetcher{versions: map[string]string{
+			"github-test-pending":    "v2.0.0",
+			"github-test-up-to-date": "v1.0.0",
+			"github-test-ignored":    "v2.0.0",
+		}},
+		errs: map[string]error{
+			"github-test-prerelease":  fmt.Errorf("github: %w: v2.0.0-rc.1", fetchclient.ErrSemverPrerelease),
+			"github-test-unavailable": errors.New("github: 502 Bad Gateway"),
+		},
+		seen: map[string][]string{
+			"github-test-pending":    {"v1.0.0", "v2.0.0"},
+			"github-test-prerelease": {"v1.0.0", "v2.0.0-rc.1"},
+		},
+	}
+	recentTime := func(_ context.Context, _ string) (time.Time, error) {
+		return time.Now().Add(-24 * time.Hour), nil
+	}
+	logger := slog.New(slog.NewTextHandler(testWriter{t}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	summary := &fetchSummary{}
+	// A failing source doesn't prevent the others from being updated.
+	pending, err := fetchPendingCreations(t.Context(), logger, fetcher, configs, nil, 4, recentTime, summary)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	nextUpdate := time.Now().Add(29 * 24 * time.Hour).UTC().Format(time.DateOnly)
+	assert.ElementsMatch(t, []*sourceSummary{
+		{Plugin: "test/disabled", Source: "github-test-disabled", Status: sourceStatusSkipped, Reason: "disabled"},
+		{Plugin: "test/infrequent", Source: "github-test-infrequent", Status: sourceStatusSkipped, Reason: "update_frequency not reached until " + nextUpdate},
+		{Plugin: "test/up-to-date", Source: "github-test-up-to-date", Status: sourceStatusUpToDate, CurrentVersion: "v1.0.0", LatestVersion: "v1.0.0"},
+		{Plugin: "test/ignored", Source: "github-test-ignored", Status: sourceStatusSkipped, Reason: "ignore_versions contains v2.0.0", CurrentVersion: "v1.0.0", LatestVersion: "v2.0.0"},
+		{Plugin: "test/prerelease", Source: "github-test-prerelease", Status: sourceStatusSkipped, Reason: "github: pre-release versions are not supported: v2.0.0-rc.1", CurrentVersion: "v1.0.0", VersionsSeen: []string{"v1.0.0", "v2.0.0-rc.1"}},
+		{Plugin: "test/unavailable", Source: "github-test-unavailable", Status: sourceStatusError, Reason: "github: 502 Bad Gateway", CurrentVersion: "v1.0.0"},
+	}, summary.Sources)
+
+	// The created version is recorded by run.
+	for _, p := range pending {
+		summary.recordPending(p, sourceStatusCreated, "")
+	}
+	assert.Equal(t, 1, summary.count(sourceStatusCreated))
+	require.NoError(t, summary.checkErrorThresholds(-1, 0.2))
+	require.NoError(t, summary.checkErrorThresholds(1, 1))
+	require.EqualError(t, summary.checkErrorThresholds(0, 1), "1 sources failed, more than --max-source-errors=0")
+	require.EqualError(t, summary.checkErrorThresholds(-1, 0.1), "1 of 7 sources failed, more than --max-source-error-ratio=0.1")
+
+	summaryFile := filepath.Join(tmpDir, "summary.json")
+	require.NoError(t, writeSummary(summaryFile, summary))
+	content, err := os.ReadFile(summaryFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"plugin": "test/pending",
+      "source": "github-test-pending",
+      "status": "created",
+      "current_version": "v1.0.0",
+      "latest_version": "v2.0.0",
+      "versions_seen": [
+        "v1.0.0",
+        "v2.0.0"
+      ]`)
+}
+
+// failingFetcher is a mockFetcher which fails to fetch the sources in errs, and
+// observes the versions in seen.
+type failingFetcher struct {
+	mockFetcher
+	errs map[string]error
+	seen map[string][]string
+}
+
+func (f *failingFetcher) Fetch(ctx context.Context, config *source.Config) (string, error) {
+	if err := f.errs[config.CacheKey()]; err != nil {
+		return "", err
+	}
+	return f.mockFetcher.Fetch(ctx, config)
+}
+
+func (f *failingFetcher) FetchVersions(ctx context.Context, config *source.Config) (string, []string, error) {
+	version, err := f.Fetch(ctx, config)
+	return version, f.seen[config.CacheKey()], err
+}
@@ -0,0 +1,320 @@
+// Package patches applies the patches of a plugin version to its upstream source, to
+// detect patches which no longer apply before the plugin is built, and refreshes the
+// context of patches which only apply with offsets (or fuzz, if requested).
+//
+// Patches are applied with GNU patch to report their offsets and fuzz, and checked with
+// 'git apply' as the Dockerfiles apply them: it accepts offsets, but not fuzz.
+package patches
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bufbuild/plugins/internal/docker"
+)
+
+const (
+	// StatusApplied is the status of a patch which applies cleanly.
+	StatusApplied Status = "applied"
+	// StatusOffset is the status of a patch which applies with offsets.
+	StatusOffset Status = "offset"
+	// StatusRefreshed is the status of a patch which applies with offsets (or fuzz, with
+	// RefreshFuzz), and was rewritten with refreshed context.
+	StatusRefreshed Status = "refreshed"
+	// StatusFailed is the status of a patch which doesn't apply, or only applies with
+	// fuzz and wasn't refreshed: 'git apply' rejects it.
+	StatusFailed Status = "failed"
+)
+
+const (
+	// RefreshNone doesn't rewrite patches.
+	RefreshNone Refresh = iota
+	// RefreshOffsets rewrites the patches which apply with offsets, which 'git apply'
+	// accepts. Patches only applying with fuzz fail, as the hunks may have applied to
+	// the wrong place.
+	RefreshOffsets
+	// RefreshFuzz rewrites the patches which apply with offsets or fuzz. Patches
+	// refreshed from fuzz must be reviewed.
+	RefreshFuzz
+)
+
+var (
+	// hunkPattern matches the report of a hunk applied by GNU patch, such as
+	// "Hunk #2 succeeded at 140 with fuzz 1 (offset 4 lines).".
+	hunkPattern = regexp.MustCompile(`^Hunk #(\d+) succeeded at (\d+)(?: with fuzz (\d+))?(?: \(offset (-?\d+) lines?\))?\.$`)
+	// gitCloneValueFlags are the flags of 'git clone' taking a value as the next argument.
+	gitCloneValueFlags = []string{"--depth", "--branch", "-b", "--origin", "-o", "--config", "-c", "--jobs", "-j", "--filter", "--reference"}
+)
+
+// Status is the outcome of applying a patch.
+type Status string
+
+// Refresh selects the patches rewritten with refreshed context by Apply.
+type Refresh int
+
+// Upstream is the git repository and ref of the upstream source of a plugin version.
+type Upstream struct {
+	URL string
+	Ref string
+}
+
+// Result is the outcome of applying a patch to the upstream source.
+type Result struct {
+	// Patch is the path of the patch, relative to the plugin version directory.
+	Patch  string `json:"patch"`
+	Status Status `json:"status"`
+	// Hunks are the hunks applied with an offset or fuzz.
+	Hunks []*Hunk `json:"hunks,omitempty"`
+	// Output is the output of patch or 'git apply', if the patch doesn't apply.
+	Output string `json:"output,omitempty"`
+}
+
+// Hunk is a hunk of a patch applied with an offset or fuzz.
+type Hunk struct {
+	File   string `json:"file"`
+	Number int    `json:"number"`
+	Line   int    `json:"line"`
+	Offset int    `json:"offset,omitempty"`
+	Fuzz   int    `json:"fuzz,omitempty"`
+}
+
+// FindUpstream returns the repository and ref cloned by the first
+// 'git clone --branch <ref> <url>' command of the Dockerfile.
+func FindUpstream(dockerfile []byte) (Upstream, error) {
+	parsed, err := docker.ParseDockerfile(dockerfile)
+	if err != nil {
+		return Upstream{}, err
+	}
+	for _, instruction := range parsed.Instructions() {
+		if instruction.Keyword != "RUN" {
+			continue
+		}
+		for _, command := range strings.FieldsFunc(instruction.Original, func(r rune) bool { return r == '&' || r == ';' }) {
+			fields := strings.Fields(command)
+			index := slices.Index(fields, "git")
+			if index == -1 || index+1 >= len(fields) || fields[index+1] != "clone" {
+				continue
+			}
+			var upstream Upstream
+			args := fields[index+2:]
+			for i := 0; i < len(args); i++ {
+				arg := strings.Trim(args[i], `"'`)
+				switch {
+				case arg == "--branch" || arg == "-b":
+					if i+1 < len(args) {
+						upstream.Ref = strings.Trim(args[i+1], `"'`)
+					}
+					i++
+				case strings.HasPrefix(arg, "--branch="):
+					upstream.Ref = strings.TrimPrefix(arg, "--branch=")
+				case slices.Contains(gitCloneValueFlags, arg):
+					i++
+				case strings.HasPrefix(arg, "-"):
+				case upstream.URL == "":
+					upstream.URL = arg
+				}
+			}
+			if upstream.URL == "" || upstream.Ref == "" {
+				return Upstream{}, fmt.Errorf("no repository and --branch in %q", strings.TrimSpace(command))
+			}
+			return upstream, nil
+		}
+	}
+	return Upstream{}, errors.New("no 'git clone' command found in Dockerfile")
+}
+
+// FindPatches returns the paths of the patches (*.patch files) of a plugin version
+// directory, relative to the directory and sorted.
+func FindPatches(versionDir string) ([]string, error) {
+	var patches []string
+	if err := filepath.WalkDir(versionDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && strings.HasSuffix(d.Name(), ".patch") {
+			relPath, err := filepath.Rel(versionDir, path)
+			if err != nil {
+				return err
+			}
+			patches = append(patches, relPath)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	slices.Sort(patches)
+	return patches, nil
+}
+
+// Apply fetches the upstream source and applies the patches of the plugin version
+// directory to it in order, as 'git apply' does in the plugin's Dockerfile (from the
+// root of the repository). The patches selected by refresh are rewritten from the
+// patched source.
+func Apply(ctx context.Context, upstream Upstream, versionDir string, patches []string, refresh Refresh) (_ []*Result, retErr error) {
+	workDir, err := os.MkdirTemp("", "patches-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		retErr = errors.Join(retErr, os.RemoveAll(workDir))
+	}()
+	if _, err := git(ctx, workDir, "init", "--quiet"); err != nil {
+		return nil, err
+	}
+	if _, err := git(ctx, workDir, "fetch", "--quiet", "--depth", "1", upstream.URL, upstream.Ref); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s from %s: %w", upstream.Ref, upstream.URL, err)
+	}
+	if _, err := git(ctx, workDir, "checkout", "--quiet", "FETCH_HEAD"); err != nil {
+		return nil, err
+	}
+	results := make([]*Result, 0, len(patches))
+	for _, patch := range patches {
+		result, err := applyPatch(ctx, workDir, filepath.Join(versionDir, patch), refresh)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply %s: %w", patch, err)
+		}
+		result.Patch = filepath.ToSlash(patch)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// applyPatch applies the patch to the source in workDir, unless it fails to apply.
+func applyPatch(ctx context.Context, workDir string, patchFile string, refresh Refresh) (*Result, error) {
+	patchFile, err := filepath.Abs(patchFile)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"--strip=1", "--batch", "--forward", "--no-backup-if-mismatch", "--input=" + patchFile}
+	dryRunOutput, err := run(ctx, workDir, "patch", append([]string{"--dry-run"}, args...)...)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, err
+		}
+		return &Result{Status: StatusFailed, Output: dryRunOutput}, nil
+	}
+	if output, err := run(ctx, workDir, "git", "apply", "--check", patchFile); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, err
+		}
+		// The patch only applies with fuzz, which fails the Docker build unless refreshed.
+		if refresh != RefreshFuzz {
+			return &Result{Status: StatusFailed, Hunks: parseHunks(dryRunOutput), Output: output}, nil
+		}
+	}
+	before, err := writeTree(ctx, workDir)
+	if err != nil {
+		return nil, err
+	}
+	output, err := run(ctx, workDir, "patch", args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, output)
+	}
+	result := &Result{Status: StatusApplied, Hunks: parseHunks(output)}
+	if len(result.Hunks) == 0 {
+		return result, nil
+	}
+	result.Status = StatusOffset
+	if refresh == RefreshNone {
+		return result, nil
+	}
+	after, err := writeTree(ctx, workDir)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := git(ctx, workDir, "diff", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", before, after)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(patchFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(patchFile, []byte(diff), info.Mode().Perm()); err != nil {
+		return nil, err
+	}
+	result.Status = StatusRefreshed
+	return result, nil
+}
+
+// parseHunks returns the hunks applied with an offset or fuzz from the output of GNU patch
+// (with or without --dry-run).
+func parseHunks(output string) []*Hunk {
+	var hunks []*Hunk
+	var file string
+	for line := range strings.Lines(output) {
+		line = strings.TrimSpace(line)
+		name, ok := strings.CutPrefix(line, "patching file ")
+		if !ok {
+			// GNU patch reports "checking file" instead with --dry-run.
+			name, ok = strings.CutPrefix(line, "checking file ")
+		}
+		if ok {
+			file = strings.Trim(name, "'")
+			continue
+		}
+		match := hunkPattern.FindStringSubmatch(line)
+		if match == nil || (match[3] == "" && match[4] == "") {
+			continue
+		}
+		hunk := &Hunk{File: file}
+		hunk.Number, _ = strconv.Atoi(match[1])
+		hunk.Line, _ = strconv.Atoi(match[2])
+		if match[3] != "" {
+			hunk.Fuzz, _ = strconv.Atoi(match[3])
+		}
+		if match[4] != "" {
+			hunk.Offset, _ = strconv.Atoi(match[4])
+		}
+		hunks = append(hunks, hunk)
+	}
+	return hunks
+}
+
+// writeTree writes the source in workDir (including untracked files) as a git tree, and
+// returns its hash.
+func writeTree(ctx context.Context, workDir string) (string, error) {
+	if _, err := git(ctx, workDir, "add", "--all"); err != nil {
+		return "", err
+	}
+	tree, err := git(ctx, workDir, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(tree), nil
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	output, err := run(ctx, dir, "git", args...)
+	if err != nil {
+		return "", fmt.Errorf("run git %v: %w: %s", args, err, output)
+	}
+	return output, nil
+}
+
+// run runs the command in dir, and returns its output (with stderr if it fails).
+func run(ctx context.Context, dir string, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	// patch reports hunks in the C locale.
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.String() + stderr.String(), err
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,209 @@
+package patches
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindUpstream(t *testing.T) {
+	t.Parallel()
+	upstream, err := FindUpstream([]byte(`FROM golang:1.26.3-trixie AS build
+WORKDIR /tmp
+RUN apt-get update \
+ && git clone --depth 1 --branch cmd/protoc-gen-go-grpc/v1.6.2 https://github.com/grpc/grpc-go.git \
+ && cd grpc-go
+COPY separate-package.patch /tmp/grpc-go
+`))
+	require.NoError(t, err)
+	assert.Equal(t, Upstream{URL: "https://github.com/grpc/grpc-go.git", Ref: "cmd/protoc-gen-go-grpc/v1.6.2"}, upstream)
+
+	upstream, err = FindUpstream([]byte("FROM swift:6.3.2-bookworm\nRUN git clone --depth 1 --branch=1.38.1 https://github.com/apple/swift-protobuf --recursive\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Upstream{URL: "https://github.com/apple/swift-protobuf", Ref: "1.38.1"}, upstream)
+
+	_, err = FindUpstream([]byte("FROM swift:6.3.2-bookworm\nRUN git clone https://github.com/apple/swift-protobuf\n"))
+	require.ErrorContains(t, err, "no repository and --branch")
+	_, err = FindUpstream([]byte("FROM golang:1.26.3-trixie\nRUN go install example.com/cmd@v1.0.0\n"))
+	require.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, "line "+string(rune('a'+i-1)))
+	}
+	original := strings.Join(lines, "\n") + "\n"
+	// Upstream added two lines at the top since the patches were written.
+	upstreamURL := newUpstream(t, "v1.0.0", "header 1\nheader 2\n"+original)
+
+	versionDir := t.TempDir()
+	writePatch := func(name string, diff string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(versionDir, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(versionDir, name), []byte(diff), 0644))
+	}
+	offsetPatch := `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -9,7 +9,7 @@
+ line i
+ line j
+ line k
+-line l
++line L
+ line m
+ line n
+ line o
+`
+	writePatch("offset.patch", offsetPatch)
+	writePatch("patches/new-file.patch", `diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++new
+`)
+	writePatch("z-failing.patch", `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,3 +1,3 @@
+ missing 1
+-missing 2
++present 2
+ missing 3
+`)
+	found, err := FindPatches(versionDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"offset.patch", filepath.Join("patches", "new-file.patch"), "z-failing.patch"}, found)
+
+	upstream := Upstream{URL: upstreamURL, Ref: "v1.0.0"}
+	results, err := Apply(t.Context(), upstream, versionDir, found, RefreshNone)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, &Result{
+		Patch:  "offset.patch",
+		Status: StatusOffset,
+		Hunks:  []*Hunk{{File: "file.txt", Number: 1, Line: 11, Offset: 2}},
+	}, results[0])
+	assert.Equal(t, &Result{Patch: "patches/new-file.patch", Status: StatusApplied}, results[1])
+	assert.Equal(t, StatusFailed, results[2].Status)
+	assert.Contains(t, results[2].Output, "FAILED")
+	content, err := os.ReadFile(filepath.Join(versionDir, "offset.patch"))
+	require.NoError(t, err)
+	assert.Equal(t, offsetPatch, string(content))
+
+	results, err = Apply(t.Context(), upstream, versionDir, found[:1], RefreshOffsets)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, StatusRefreshed, results[0].Status)
+	content, err = os.ReadFile(filepath.Join(versionDir, "offset.patch"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "@@ -11,7 +11,7 @@")
+	assert.Contains(t, string(content), "-line l\n+line L\n")
+
+	// The refreshed patch applies cleanly.
+	results, err = Apply(t.Context(), upstream, versionDir, found[:1], RefreshOffsets)
+	require.NoError(t, err)
+	assert.Equal(t, []*Result{{Patch: "offset.patch", Status: StatusApplied}}, results)
+
+	_, err = Apply(t.Context(), Upstream{URL: upstreamURL, Ref: "v2.0.0"}, versionDir, found, RefreshNone)
+	require.ErrorContains(t, err, "failed to fetch v2.0.0")
+}
+
+func TestApplyFuzz(t *testing.T) {
+	t.Parallel()
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, "line "+string(rune('a'+i-1)))
+	}
+	upstreamURL := newUpstream(t, "v1.0.0", strings.Join(lines, "\n")+"\n")
+	versionDir := t.TempDir()
+	// Upstream changed the first line of context since the patch was written.
+	fuzzPatch := `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -9,7 +9,7 @@
+ line I
+ line j
+ line k
+-line l
++line L
+ line m
+ line n
+ line o
+`
+	require.NoError(t, os.WriteFile(filepath.Join(versionDir, "fuzz.patch"), []byte(fuzzPatch), 0644))
+	upstream := Upstream{URL: upstreamURL, Ref: "v1.0.0"}
+
+	// GNU patch applies it with fuzz, but 'git apply' in the Dockerfile rejects it.
+	results, err := Apply(t.Context(), upstream, versionDir, []string{"fuzz.patch"}, RefreshNone)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, StatusFailed, results[0].Status)
+	assert.Equal(t, []*Hunk{{File: "file.txt", Number: 1, Line: 9, Fuzz: 1}}, results[0].Hunks)
+	assert.Contains(t, results[0].Output, "patch does not apply")
+	content, err := os.ReadFile(filepath.Join(versionDir, "fuzz.patch"))
+	require.NoError(t, err)
+	assert.Equal(t, fuzzPatch, string(content))
+
+	// Patches only applying with fuzz aren't refreshed with their offsets.
+	results, err = Apply(t.Context(), upstream, versionDir, []string{"fuzz.patch"}, RefreshOffsets)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, StatusFailed, results[0].Status)
+	content, err = os.ReadFile(filepath.Join(versionDir, "fuzz.patch"))
+	require.NoError(t, err)
+	assert.Equal(t, fuzzPatch, string(content))
+
+	results, err = Apply(t.Context(), upstream, versionDir, []string{"fuzz.patch"}, RefreshFuzz)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, StatusRefreshed, results[0].Status)
+
+	// The refreshed patch applies with 'git apply'.
+	results, err = Apply(t.Context(), upstream, versionDir, []string{"fuzz.patch"}, RefreshNone)
+	require.NoError(t, err)
+	assert.Equal(t, []*Result{{Patch: "fuzz.patch", Status: StatusApplied}}, results)
+}
+
+func TestParseHunks(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, []*Hunk{
+		{File: "a.go", Number: 2, Line: 140, Offset: 4, Fuzz: 1},
+		{File: "b.go", Number: 1, Line: 10, Fuzz: 2},
+		{File: "b.go", Number: 3, Line: 7, Offset: -1},
+	}, parseHunks(`patching file a.go
+Hunk #1 succeeded at 20.
+Hunk #2 succeeded at 140 with fuzz 1 (offset 4 lines).
+patching file 'b.go'
+Hunk #1 succeeded at 10 with fuzz 2.
+Hunk #2 FAILED at 30.
+Hunk #3 succeeded at 7 (offset -1 lines).
+`))
+}
+
+// newUpstream returns the path of a bare git repository with file.txt, tagged with the tag.
+func newUpstream(t *testing.T, tag string, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte(content), 0644))
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "file.txt"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "initial"},
+		{"tag", tag},
+		{"clone", "--quiet", "--bare", ".", "upstream.git"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+	return filepath.Join(dir, "upstream.git")
+}
@@ -0,0 +1,239 @@
+package readiness
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+const (
+	goProxyURL     = "https://proxy.golang.org"
+	npmRegistryURL = "https://registry.npmjs.org"
+	// docs: https://packaging.python.org/en/latest/specifications/simple-repository-api/
+	pypiURL   = "https://pypi.org/simple"
+	mavenURL  = "https://repo1.maven.org/maven2"
+	cratesURL = "https://crates.io/api/v1"
+)
+
+var (
+	// githubReleaseAssetPattern matches GitHub release asset download URLs.
+	githubReleaseAssetPattern = regexp.MustCompile(`^(https://github\.com/[^/]+/[^/]+)/releases/download/([^/]+)/`)
+	pypiNameSeparatorPattern  = regexp.MustCompile(`[-_.]+`)
+)
+
+// Prober checks that artifacts are available from their registries.
+type Prober struct {
+	httpClient      *http.Client
+	goProxyBaseURL  string
+	npmBaseURL      string
+	pypiBaseURL     string
+	mavenBaseURL    string
+	cratesBaseURL   string
+	userAgentHeader string
+}
+
+// NewProber returns a new Prober using the public registries (see WithRegistry).
+func NewProber(httpClient *http.Client) *Prober {
+	return &Prober{
+		httpClient:      httpClient,
+		goProxyBaseURL:  goProxyURL,
+		npmBaseURL:      npmRegistryURL,
+		pypiBaseURL:     pypiURL,
+		mavenBaseURL:    mavenURL,
+		cratesBaseURL:   cratesURL,
+		userAgentHeader: "bufbuild (github.com/bufbuild/plugins)",
+	}
+}
+
+// WithRegistry returns a copy of the prober which probes artifacts of the ecosystem at
+// the registry with the base URL, such as a private registry configured for a source.
+// The PyPI base URL is that of a simple repository index. Registries of other
+// ecosystems can't be changed.
+func (p *Prober) WithRegistry(ecosystem Ecosystem, baseURL string) *Prober {
+	prober := *p
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	switch ecosystem {
+	case EcosystemGo:
+		prober.goProxyBaseURL = baseURL
+	case EcosystemNPM:
+		prober.npmBaseURL = baseURL
+	case EcosystemPyPI:
+		prober.pypiBaseURL = baseURL
+	case EcosystemMaven:
+		prober.mavenBaseURL = baseURL
+	}
+	return &prober
+}
+
+// Check checks that each artifact is available. It returns a reason for each
+// artifact which is not (yet) available, or nil if all artifacts are available.
+// Errors reaching a registry are also reported as reasons, as they usually
+// indicate that the artifact is not available yet.
+func (p *Prober) Check(ctx context.Context, artifacts []Artifact) []string {
+	var reasons []string
+	for _, artifact := range artifacts {
+		if err := p.check(ctx, artifact); err != nil {
+			reasons = append(reasons, fmt.Sprintf("%s: %v", artifact, err))
+		}
+	}
+	return reasons
+}
+
+func (p *Prober) check(ctx context.Context, artifact Artifact) error {
+	switch artifact.Ecosystem {
+	case EcosystemGo:
+		return p.checkGoModule(ctx, artifact.Name, artifact.Version)
+	case EcosystemNPM:
+		return p.probe(ctx, fmt.Sprintf("%s/%s/%s", p.npmBaseURL, artifact.Name, artifact.Version))
+	case EcosystemPyPI:
+		return p.checkPyPIRelease(ctx, artifact.Name, artifact.Version)
+	case EcosystemMaven:
+		group, name, ok := strings.Cut(artifact.Name, ":")
+		if !ok {
+			return fmt.Errorf("invalid maven coordinates %q", artifact.Name)
+		}
+		pomURL, err := url.JoinPath(
+			p.mavenBaseURL,
+			append(strings.Split(group, "."), name, artifact.Version, name+"-"+artifact.Version+".pom")...,
+		)
+		if err != nil {
+			return err
+		}
+		return p.probe(ctx, pomURL)
+	case EcosystemCrates:
+		return p.probe(ctx, fmt.Sprintf("%s/crates/%s/%s", p.cratesBaseURL, artifact.Name, artifact.Version))
+	case EcosystemURL:
+		return p.probe(ctx, probeURL(artifact.Name))
+	}
+	return fmt.Errorf("unknown ecosystem %q", artifact.Ecosystem)
+}
+
+// checkGoModule checks that the module providing the package is available at the
+// version. "go install" takes a package path, so like the go command this tries
+// each prefix of the path (longest first) as the module path.
+func (p *Prober) checkGoModule(ctx context.Context, packagePath string, version string) error {
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for modulePath := packagePath; modulePath != "." && modulePath != ""; {
+		escapedPath, err := module.EscapePath(modulePath)
+		if err != nil {
+			return err
+		}
+		lastErr = p.probe(ctx, fmt.Sprintf("%s/%s/@v/%s.info", p.goProxyBaseURL, escapedPath, escapedVersion))
+		if lastErr == nil {
+			return nil
+		}
+		idx := strings.LastIndex(modulePath, "/")
+		if idx == -1 {
+			break
+		}
+		modulePath = modulePath[:idx]
+	}
+	return lastErr
+}
+
+// checkPyPIRelease checks that the simple repository index lists the release of the project.
+func (p *Prober) checkPyPIRelease(ctx context.Context, name string, version string) error {
+	targetURL := fmt.Sprintf("%s/%s/", p.pypiBaseURL, pypiNormalizedName(name))
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/vnd.pypi.simple.v1+json")
+	request.Header.Set("User-Agent", p.userAgentHeader)
+	response, err := p.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return fmt.Errorf("not found at %s", targetURL)
+	default:
+		return fmt.Errorf("received status code %d retrieving %s", response.StatusCode, targetURL)
+	}
+	var data struct {
+		Versions []string `json:"versions"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", targetURL, err)
+	}
+	if !slices.Contains(data.Versions, version) {
+		return fmt.Errorf("version %s not found at %s", version, targetURL)
+	}
+	return nil
+}
+
+// pypiNormalizedName returns the normalized project name used in simple repository URLs (PEP 503).
+func pypiNormalizedName(name string) string {
+	return strings.ToLower(pypiNameSeparatorPattern.ReplaceAllString(name, "-"))
+}
+
+// probeURL returns the URL to probe for a downloaded file. URLs with unresolved
+// shell variables (e.g. an architecture mapped in a RUN instruction) can't be
+// probed directly: for GitHub release assets the release is probed instead,
+// otherwise the directory containing the file (e.g. a Maven version directory).
+func probeURL(fileURL string) string {
+	idx := strings.Index(fileURL, "$")
+	if idx == -1 {
+		return fileURL
+	}
+	if match := githubReleaseAssetPattern.FindStringSubmatch(fileURL); match != nil && len(match[0]) <= idx {
+		return match[1] + "/releases/tag/" + match[2]
+	}
+	return fileURL[:strings.LastIndex(fileURL[:idx], "/")+1]
+}
+
+// probe checks that the URL resolves with a 200 status code, following redirects.
+func (p *Prober) probe(ctx context.Context, targetURL string) error {
+	status, err := p.request(ctx, http.MethodHead, targetURL)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusForbidden) {
+		// Some servers (and pre-signed redirect targets) don't support HEAD requests.
+		status, err = p.request(ctx, http.MethodGet, targetURL)
+	}
+	if err != nil {
+		return err
+	}
+	switch status {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound, http.StatusGone:
+		return fmt.Errorf("not found at %s", targetURL)
+	default:
+		return fmt.Errorf("received status code %d retrieving %s", status, targetURL)
+	}
+}
+
+func (p *Prober) request(ctx context.Context, method string, targetURL string) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, method, targetURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("User-Agent", p.userAgentHeader)
+	response, err := p.httpClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 1<<20))
+	return response.StatusCode, nil
+}
+
+func sortArtifacts(artifacts []Artifact) {
+	slices.SortFunc(artifacts, func(a, b Artifact) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+}
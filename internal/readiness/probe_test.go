@@ -0,0 +1,94 @@
+package readiness
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProberCheck(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	for _, path := range []string{
+		"/go/google.golang.org/protobuf/@v/v1.36.1.info",
+		"/go/github.com/!google!cloud!platform/protoc-gen-bq-schema/v3/@v/v3.1.0.info",
+		"/npm/@bufbuild/protoc-gen-es/2.2.0",
+		"/private-npm/@acme/protoc-gen-foo/1.0.0",
+		"/maven/io/grpc/grpc-core/1.66.0/grpc-core-1.66.0.pom",
+		"/maven/io/grpc/protoc-gen-grpc-java/1.66.0/",
+		"/crates/crates/protoc-gen-prost/0.5.0",
+		"/github/grpc/grpc-web/releases/tag/2.0.2",
+	} {
+		mux.HandleFunc(path, func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+	mux.HandleFunc("/pypi/grpcio/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/vnd.pypi.simple.v1+json", r.Header.Get("Accept"))
+		_, _ = w.Write([]byte(`{"versions": ["1.65.0", "1.66.0"]}`))
+	})
+	mux.HandleFunc("/no-head/file.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	prober := &Prober{
+		httpClient:     srv.Client(),
+		goProxyBaseURL: srv.URL + "/go",
+		npmBaseURL:     srv.URL + "/npm",
+		pypiBaseURL:    srv.URL + "/pypi",
+		mavenBaseURL:   srv.URL + "/maven",
+		cratesBaseURL:  srv.URL + "/crates",
+	}
+
+	ready := []Artifact{
+		{Ecosystem: EcosystemGo, Name: "google.golang.org/protobuf/cmd/protoc-gen-go", Version: "v1.36.1"},
+		{Ecosystem: EcosystemGo, Name: "github.com/GoogleCloudPlatform/protoc-gen-bq-schema/v3", Version: "v3.1.0"},
+		{Ecosystem: EcosystemNPM, Name: "@bufbuild/protoc-gen-es", Version: "2.2.0"},
+		{Ecosystem: EcosystemPyPI, Name: "grpcio", Version: "1.66.0"},
+		{Ecosystem: EcosystemMaven, Name: "io.grpc:grpc-core", Version: "1.66.0"},
+		{Ecosystem: EcosystemCrates, Name: "protoc-gen-prost", Version: "0.5.0"},
+		{Ecosystem: EcosystemURL, Name: srv.URL + "/maven/io/grpc/protoc-gen-grpc-java/1.66.0/protoc-gen-grpc-java-1.66.0-linux-${arch}.exe"},
+		{Ecosystem: EcosystemURL, Name: srv.URL + "/no-head/file.tar.gz"},
+	}
+	assert.Empty(t, prober.Check(t.Context(), ready))
+
+	private := []Artifact{{Ecosystem: EcosystemNPM, Name: "@acme/protoc-gen-foo", Version: "1.0.0"}}
+	assert.Empty(t, prober.WithRegistry(EcosystemNPM, srv.URL+"/private-npm/").Check(t.Context(), private))
+	assert.Len(t, prober.Check(t.Context(), private), 1)
+
+	notReady := []Artifact{
+		{Ecosystem: EcosystemGo, Name: "google.golang.org/protobuf/cmd/protoc-gen-go", Version: "v1.36.2", File: "Dockerfile"},
+		{Ecosystem: EcosystemNPM, Name: "@bufbuild/protoc-gen-es", Version: "2.3.0", File: "package.json"},
+		{Ecosystem: EcosystemPyPI, Name: "grpcio", Version: "1.67.0", File: "requirements.txt"},
+		{Ecosystem: EcosystemURL, Name: srv.URL + "/maven/io/grpc/protoc-gen-grpc-java/1.67.0/protoc-gen-grpc-java-1.67.0.jar", File: "Dockerfile"},
+	}
+	assert.Equal(t, []string{
+		"go google.golang.org/protobuf/cmd/protoc-gen-go@v1.36.2 (Dockerfile): not found at " + srv.URL + "/go/google.golang.org/@v/v1.36.2.info",
+		"npm @bufbuild/protoc-gen-es@2.3.0 (package.json): not found at " + srv.URL + "/npm/@bufbuild/protoc-gen-es/2.3.0",
+		"pypi grpcio@1.67.0 (requirements.txt): version 1.67.0 not found at " + srv.URL + "/pypi/grpcio/",
+		"url " + srv.URL + "/maven/io/grpc/protoc-gen-grpc-java/1.67.0/protoc-gen-grpc-java-1.67.0.jar (Dockerfile): not found at " + srv.URL + "/maven/io/grpc/protoc-gen-grpc-java/1.67.0/protoc-gen-grpc-java-1.67.0.jar",
+	}, prober.Check(t.Context(), notReady))
+}
+
+func TestProbeURL(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t,
+		"https://github.com/protocolbuffers/protobuf-javascript/releases/tag/v4.0.2",
+		probeURL("https://github.com/protocolbuffers/protobuf-javascript/releases/download/v4.0.2/protobuf-javascript-4.0.2-linux-${arch}.tar.gz"),
+	)
+	assert.Equal(t,
+		"https://repo1.maven.org/maven2/io/grpc/protoc-gen-grpc-java/1.83.1/",
+		probeURL("https://repo1.maven.org/maven2/io/grpc/protoc-gen-grpc-java/1.83.1/protoc-gen-grpc-java-1.83.1-linux-${arch}.exe"),
+	)
+	assert.Equal(t,
+		"https://github.com/grpc/grpc-web/releases/download/2.0.2/grpc-web-source-2.0.2.tar.gz",
+		probeURL("https://github.com/grpc/grpc-web/releases/download/2.0.2/grpc-web-source-2.0.2.tar.gz"),
+	)
+}
@@ -0,0 +1,286 @@
+// Package readiness determines whether the upstream artifacts installed by a new
+// plugin version are available before the version is created.
+package readiness
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bufbuild/plugins/internal/docker"
+	"github.com/bufbuild/plugins/internal/substitute"
+)
+
+// Ecosystem is the kind of registry an artifact is published to.
+type Ecosystem string
+
+const (
+	// EcosystemGo is a Go module installed with "go install".
+	EcosystemGo Ecosystem = "go"
+	// EcosystemNPM is an npm package declared in package.json.
+	EcosystemNPM Ecosystem = "npm"
+	// EcosystemPyPI is a Python package declared in requirements.txt or requirements.in.
+	EcosystemPyPI Ecosystem = "pypi"
+	// EcosystemMaven is a Maven artifact declared in pom.xml.
+	EcosystemMaven Ecosystem = "maven"
+	// EcosystemCrates is a crate installed with "cargo install".
+	EcosystemCrates Ecosystem = "crates"
+	// EcosystemURL is a file downloaded from a URL in a Dockerfile.
+	EcosystemURL Ecosystem = "url"
+)
+
+// dockerfileDefaultArgs are the values assumed for the automatic platform ARGs.
+var dockerfileDefaultArgs = map[string]string{
+	"TARGETOS":       "linux",
+	"TARGETARCH":     "amd64",
+	"TARGETPLATFORM": "linux/amd64",
+	"BUILDOS":        "linux",
+	"BUILDARCH":      "amd64",
+	"BUILDPLATFORM":  "linux/amd64",
+}
+
+var (
+	dockerfileVariablePattern = regexp.MustCompile(`\$\{?([A-Za-z_][A-Za-z0-9_]*)\}?`)
+	urlPattern                = regexp.MustCompile(`https?://[^\s"'\\;|&)]+`)
+	// requirementPattern matches pinned requirements such as "name[extra]==1.0 ; marker".
+	requirementPattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)(?:\[[^\]]*\])?\s*===?\s*([^\s;#]+)`)
+	// cargoValueFlags are "cargo install" flags which take a value.
+	cargoValueFlags = map[string]struct{}{
+		"--root": {}, "--git": {}, "--branch": {}, "--tag": {}, "--rev": {}, "--path": {},
+		"--features": {}, "-F": {}, "--target": {}, "--target-dir": {}, "--registry": {},
+		"--index": {}, "--profile": {}, "--bin": {}, "--example": {}, "-j": {}, "--jobs": {},
+		"--config": {}, "-Z": {},
+	}
+)
+
+// Artifact is an upstream artifact installed by a plugin version.
+type Artifact struct {
+	Ecosystem Ecosystem
+	// Name is the module or package path, the "group:artifact" Maven coordinates,
+	// or the URL of a downloaded file.
+	Name string
+	// Version is the artifact version, empty for URLs.
+	Version string
+	// File is the name of the file which references the artifact.
+	File string
+}
+
+func (a Artifact) String() string {
+	if a.Version == "" {
+		return fmt.Sprintf("%s %s (%s)", a.Ecosystem, a.Name, a.File)
+	}
+	return fmt.Sprintf("%s %s@%s (%s)", a.Ecosystem, a.Name, a.Version, a.File)
+}
+
+// FindArtifacts returns the upstream artifacts a new plugin version would install.
+// The files of the previous version directory are read with the version substituted
+// by the substituter, as the fetcher does when creating the new version.
+// Only artifacts referencing the new version are returned: other artifacts were
+// available when the previous version was created.
+func FindArtifacts(dir string, substituter *substitute.Substituter) ([]Artifact, error) {
+	parsers := []struct {
+		filename string
+		parse    func(content string, version string) ([]Artifact, error)
+	}{
+		{filename: "Dockerfile", parse: parseDockerfile},
+		{filename: "package.json", parse: parsePackageJSON},
+		{filename: "requirements.txt", parse: parseRequirements},
+		{filename: "requirements.in", parse: parseRequirements},
+		{filename: "pom.xml", parse: parsePOM},
+	}
+	var artifacts []Artifact
+	for _, parser := range parsers {
+		content, err := os.ReadFile(filepath.Join(dir, parser.filename))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		result, err := substituter.File(parser.filename, content)
+		if err != nil {
+			return nil, err
+		}
+		found, err := parser.parse(string(result.Content), substituter.NewVersion())
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", parser.filename, err)
+		}
+		for i := range found {
+			found[i].File = parser.filename
+		}
+		artifacts = append(artifacts, found...)
+	}
+	return artifacts, nil
+}
+
+// parseDockerfile finds Go modules installed with "go install", crates installed with
+// "cargo install", and downloaded URLs which reference the version.
+func parseDockerfile(content string, version string) ([]Artifact, error) {
+	args := make(map[string]string, len(dockerfileDefaultArgs))
+	for key, value := range dockerfileDefaultArgs {
+		args[key] = value
+	}
+	dockerfile, err := docker.ParseDockerfile([]byte(content))
+	if err != nil {
+		return nil, err
+	}
+	var artifacts []Artifact
+	for _, instruction := range dockerfile.Instructions() {
+		_, rest, _ := strings.Cut(instruction.Original, " ")
+		switch instruction.Keyword {
+		case "ARG":
+			for _, field := range strings.Fields(rest) {
+				if name, value, ok := strings.Cut(field, "="); ok {
+					args[name] = strings.Trim(value, `"'`)
+				}
+			}
+			continue
+		case "RUN", "ADD":
+		default:
+			continue
+		}
+		rest = dockerfileVariablePattern.ReplaceAllStringFunc(rest, func(match string) string {
+			name := dockerfileVariablePattern.FindStringSubmatch(match)[1]
+			if value, ok := args[name]; ok {
+				return value
+			}
+			return match
+		})
+		fields := strings.Fields(rest)
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i+1] != "install" {
+				continue
+			}
+			switch fields[i] {
+			case "go":
+				for _, field := range fields[i+2:] {
+					if isShellSeparator(field) {
+						break
+					}
+					if path, moduleVersion, ok := strings.Cut(field, "@"); ok && !strings.HasPrefix(field, "-") {
+						if strings.TrimPrefix(moduleVersion, "v") == version {
+							artifacts = append(artifacts, Artifact{Ecosystem: EcosystemGo, Name: path, Version: moduleVersion})
+						}
+					}
+				}
+			case "cargo":
+				if name, crateVersion := parseCargoInstall(fields[i+2:]); name != "" && crateVersion == version {
+					artifacts = append(artifacts, Artifact{Ecosystem: EcosystemCrates, Name: name, Version: crateVersion})
+				}
+			}
+		}
+		for _, match := range urlPattern.FindAllString(rest, -1) {
+			if strings.Contains(match, version) {
+				artifacts = append(artifacts, Artifact{Ecosystem: EcosystemURL, Name: match})
+			}
+		}
+	}
+	return artifacts, nil
+}
+
+// parseCargoInstall returns the crate name and version from "cargo install" arguments.
+func parseCargoInstall(fields []string) (string, string) {
+	var name, version string
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		switch {
+		case isShellSeparator(field):
+			return name, version
+		case field == "--version" || field == "--vers":
+			if i+1 < len(fields) {
+				version = strings.TrimPrefix(fields[i+1], "=")
+				i++
+			}
+		case strings.HasPrefix(field, "--version="):
+			version = strings.TrimPrefix(field, "--version=")
+		case strings.HasPrefix(field, "-"):
+			if _, ok := cargoValueFlags[field]; ok {
+				i++
+			}
+		case name == "":
+			name = field
+			if crateName, crateVersion, ok := strings.Cut(field, "@"); ok {
+				name, version = crateName, crateVersion
+			}
+		}
+	}
+	return name, version
+}
+
+func isShellSeparator(field string) bool {
+	switch field {
+	case "&&", "||", ";", "|":
+		return true
+	}
+	return strings.HasSuffix(field, ";")
+}
+
+// parsePackageJSON finds npm dependencies pinned to the version.
+func parsePackageJSON(content string, version string) ([]Artifact, error) {
+	var packageJSON struct {
+		Dependencies         map[string]string `json:"dependencies"`
+		DevDependencies      map[string]string `json:"devDependencies"`      //nolint:tagliatelle
+		OptionalDependencies map[string]string `json:"optionalDependencies"` //nolint:tagliatelle
+	}
+	if err := json.Unmarshal([]byte(content), &packageJSON); err != nil {
+		return nil, err
+	}
+	var artifacts []Artifact
+	for _, dependencies := range []map[string]string{
+		packageJSON.Dependencies,
+		packageJSON.DevDependencies,
+		packageJSON.OptionalDependencies,
+	} {
+		for name, spec := range dependencies {
+			if strings.TrimLeft(spec, "^~=v") == version {
+				artifacts = append(artifacts, Artifact{Ecosystem: EcosystemNPM, Name: name, Version: version})
+			}
+		}
+	}
+	sortArtifacts(artifacts)
+	return artifacts, nil
+}
+
+// parseRequirements finds Python requirements pinned to the version.
+func parseRequirements(content string, version string) ([]Artifact, error) {
+	var artifacts []Artifact
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		match := requirementPattern.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if match != nil && match[2] == version {
+			artifacts = append(artifacts, Artifact{Ecosystem: EcosystemPyPI, Name: match[1], Version: version})
+		}
+	}
+	return artifacts, scanner.Err()
+}
+
+// parsePOM finds Maven dependencies at the version.
+func parsePOM(content string, version string) ([]Artifact, error) {
+	var pom struct {
+		Dependencies []struct {
+			GroupID    string `xml:"groupId"`
+			ArtifactID string `xml:"artifactId"`
+			Version    string `xml:"version"`
+		} `xml:"dependencies>dependency"`
+	}
+	if err := xml.Unmarshal([]byte(content), &pom); err != nil {
+		return nil, err
+	}
+	var artifacts []Artifact
+	for _, dependency := range pom.Dependencies {
+		if strings.TrimSpace(dependency.Version) == version {
+			artifacts = append(artifacts, Artifact{
+				Ecosystem: EcosystemMaven,
+				Name:      strings.TrimSpace(dependency.GroupID) + ":" + strings.TrimSpace(dependency.ArtifactID),
+				Version:   version,
+			})
+		}
+	}
+	return artifacts, nil
+}
@@ -0,0 +1,78 @@
+package readiness
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bufbuild/plugins/internal/substitute"
+)
+
+func TestFindArtifacts(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFile(t, dir, "Dockerfile", `FROM golang:1.25.0-bookworm AS build
+RUN go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@v1.5.0
+`)
+	writeFile(t, dir, "package.json", `{
+  "dependencies": {"@bufbuild/protoc-gen-es": "1.5.0", "other": "^2.0.0"},
+  "devDependencies": {"@types/node": "^1.5.0"}
+}`)
+	writeFile(t, dir, "requirements.txt", `grpcio==1.5.0
+grpcio-tools[extra] == 1.5.0 ; python_version >= "3.9"
+protobuf==6.31.1
+# comment==1.5.0
+`)
+	writeFile(t, dir, "pom.xml", `<project>
+  <dependencies>
+    <dependency><groupId>io.grpc</groupId><artifactId>grpc-core</artifactId><version>1.5.0</version></dependency>
+    <dependency><groupId>com.google.protobuf</groupId><artifactId>protobuf-java</artifactId><version>4.35.1</version></dependency>
+  </dependencies>
+</project>`)
+
+	// The previous version's files are read with the version substituted.
+	artifacts, err := FindArtifacts(dir, substitute.New("v1.5.0", "v1.5.1", nil))
+	require.NoError(t, err)
+	assert.Equal(t, []Artifact{
+		{Ecosystem: EcosystemGo, Name: "google.golang.org/grpc/cmd/protoc-gen-go-grpc", Version: "v1.5.1", File: "Dockerfile"},
+		{Ecosystem: EcosystemNPM, Name: "@bufbuild/protoc-gen-es", Version: "1.5.1", File: "package.json"},
+		{Ecosystem: EcosystemNPM, Name: "@types/node", Version: "1.5.1", File: "package.json"},
+		{Ecosystem: EcosystemPyPI, Name: "grpcio", Version: "1.5.1", File: "requirements.txt"},
+		{Ecosystem: EcosystemPyPI, Name: "grpcio-tools", Version: "1.5.1", File: "requirements.txt"},
+		{Ecosystem: EcosystemMaven, Name: "io.grpc:grpc-core", Version: "1.5.1", File: "pom.xml"},
+	}, artifacts)
+}
+
+func TestParseDockerfile(t *testing.T) {
+	t.Parallel()
+	artifacts, err := parseDockerfile(`FROM golang:1.25.0-bookworm AS build
+ARG TARGETOS TARGETARCH
+ARG PROTOBUF_VERSION=36.0
+RUN --mount=type=cache,target=/go/pkg/mod \
+    GOOS=$TARGETOS GOARCH=$TARGETARCH go install -ldflags "-s -w" -trimpath google.golang.org/protobuf/cmd/protoc-gen-go@v36.0 \
+ && go install example.com/other@v1.0.0
+RUN cargo install --locked protoc-gen-prost --version 36.0 --root /app && cargo install other --version 1.0.0
+RUN cargo install protoc-gen-tonic@36.0
+RUN curl -fsSL -o protoc.zip https://github.com/protocolbuffers/protobuf/releases/download/v${PROTOBUF_VERSION}/protoc-${PROTOBUF_VERSION}-linux-x86_64.zip
+RUN arch=${TARGETARCH}; \
+    curl -fsSL -o protoc-gen-grpc-java https://repo1.maven.org/maven2/io/grpc/protoc-gen-grpc-java/36.0/protoc-gen-grpc-java-36.0-linux-${arch}.exe
+# RUN go install example.com/commented@v36.0
+RUN curl -fsSL https://example.com/unrelated-1.0.0.tar.gz
+`, "36.0")
+	require.NoError(t, err)
+	assert.Equal(t, []Artifact{
+		{Ecosystem: EcosystemGo, Name: "google.golang.org/protobuf/cmd/protoc-gen-go", Version: "v36.0"},
+		{Ecosystem: EcosystemCrates, Name: "protoc-gen-prost", Version: "36.0"},
+		{Ecosystem: EcosystemCrates, Name: "protoc-gen-tonic", Version: "36.0"},
+		{Ecosystem: EcosystemURL, Name: "https://github.com/protocolbuffers/protobuf/releases/download/v36.0/protoc-36.0-linux-x86_64.zip"},
+		{Ecosystem: EcosystemURL, Name: "https://repo1.maven.org/maven2/io/grpc/protoc-gen-grpc-java/36.0/protoc-gen-grpc-java-36.0-linux-${arch}.exe"},
+	}, artifacts)
+}
+
+func writeFile(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
@@ -1,6 +1,7 @@
 package source
 
 import (
+	"fmt"
 	"io"
 
 	"gopkg.in/yaml.v3"
@@ -16,6 +17,8 @@ type Cacheable interface {
 type Config struct {
 	Filename string `yaml:"-"`
 	Source   Source `yaml:"source"`
+	// PostProcess are the steps run on each new version of the plugin, in order.
+	PostProcess []PostProcessStep `yaml:"post_process"`
 }
 
 // NewConfig returns a new config.
@@ -26,6 +29,13 @@ func NewConfig(reader io.Reader) (*Config, error) {
 	if err := decoder.Decode(&config); err != nil {
 		return nil, err
 	}
+	seen := make(map[PostProcessStepName]struct{}, len(config.PostProcess))
+	for _, step := range config.PostProcess {
+		if _, ok := seen[step.Name]; ok {
+			return nil, fmt.Errorf("duplicate post_process step %q", step.Name)
+		}
+		seen[step.Name] = struct{}{}
+	}
 	return config, nil
 }
 
@@ -46,6 +56,8 @@ type Source struct {
 	Maven       *MavenConfig       `yaml:"maven"`
 	Crates      *CratesConfig      `yaml:"crates"`
 	PyPI        *PyPIConfig        `yaml:"pypi"`
+	HTTP        *HTTPConfig        `yaml:"http"`
+	OCI         *OCIConfig         `yaml:"oci"`
 	// IgnoreVersions is a list of versions to ignore when fetching.
 	IgnoreVersions []string `yaml:"ignore_versions"`
 	// MaxVersion is an exclusive upper bound for versions. Versions >= this value will be ignored.
@@ -55,6 +67,9 @@ type Source struct {
 	// will skip the plugin unless at least this much time has passed since the latest
 	// version was added.
 	UpdateFrequency *Duration `yaml:"update_frequency"`
+	// Verification is the level of upstream provenance verification required before
+	// a new version is created. Defaults to VerificationNone.
+	Verification VerificationLevel `yaml:"verification"`
 }
 
 var _ Cacheable = (*Source)(nil)
@@ -75,6 +90,10 @@ func (s *Source) Name() string {
 		return "crates"
 	case s.PyPI != nil:
 		return "pypi"
+	case s.HTTP != nil:
+		return "http"
+	case s.OCI != nil:
+		return "oci"
 	}
 	return "unknown"
 }
@@ -96,6 +115,10 @@ func (s *Source) CacheKey() string {
 		return name + "-" + s.Crates.CacheKey()
 	case s.PyPI != nil:
 		return name + "-" + s.PyPI.CacheKey()
+	case s.HTTP != nil:
+		return name + "-" + s.HTTP.CacheKey()
+	case s.OCI != nil:
+		return name + "-" + s.OCI.CacheKey()
 	}
 	return name
 }
@@ -115,11 +138,17 @@ func (c CratesConfig) CacheKey() string {
 type GitHubConfig struct {
 	Owner      string `yaml:"owner"`
 	Repository string `yaml:"repository"`
+	// RequireRelease only considers tags with a published GitHub release,
+	// excluding draft releases and releases marked as a pre-release.
+	RequireRelease bool `yaml:"require_release"`
 }
 
 var _ Cacheable = (*GitHubConfig)(nil)
 
 func (g GitHubConfig) CacheKey() string {
+	if g.RequireRelease {
+		return g.Owner + "-" + g.Repository + "-release"
+	}
 	return g.Owner + "-" + g.Repository
 }
 
@@ -137,44 +166,105 @@ func (d DartFlutterConfig) CacheKey() string {
 // GoProxyConfig is the go proxy configuration.
 type GoProxyConfig struct {
 	Name string `yaml:"name"`
+	// Registry is the URL of a private Go module proxy. Defaults to proxy.golang.org.
+	Registry string `yaml:"registry"`
 }
 
 var _ Cacheable = (*GoProxyConfig)(nil)
 
 func (g GoProxyConfig) CacheKey() string {
-	return g.Name
+	return withRegistry(g.Name, g.Registry)
 }
 
 // NPMRegistryConfig is the npm registry configuration.
 type NPMRegistryConfig struct {
 	Name string `yaml:"name"`
+	// Registry is the URL of a private npm registry. Defaults to registry.npmjs.org.
+	Registry string `yaml:"registry"`
+	// Repository is the URL of the GitHub repository the package's provenance attestations
+	// must have been built from (e.g. "https://github.com/bufbuild/protobuf-es"). Required
+	// for verification.
+	Repository string `yaml:"repository"`
 }
 
 var _ Cacheable = (*NPMRegistryConfig)(nil)
 
 func (n NPMRegistryConfig) CacheKey() string {
-	return n.Name
+	return withRegistry(n.Name, n.Registry)
 }
 
 // MavenConfig is the maven search configuration.
 type MavenConfig struct {
 	Group string `yaml:"group"`
 	Name  string `yaml:"name"`
+	// Qualifier selects versions with this qualifier (e.g. "jre" for "33.0.0-jre").
+	// Versions with other non-release qualifiers are ignored.
+	Qualifier string `yaml:"qualifier"`
+	// Registry is the URL of a private Maven repository. Defaults to Maven Central.
+	Registry string `yaml:"registry"`
 }
 
 var _ Cacheable = (*MavenConfig)(nil)
 
 func (m MavenConfig) CacheKey() string {
-	return m.Group + "-" + m.Name
+	key := m.Group + "-" + m.Name
+	if m.Qualifier != "" {
+		key += "-" + m.Qualifier
+	}
+	return withRegistry(key, m.Registry)
 }
 
 // PyPIConfig is the PyPI configuration.
 type PyPIConfig struct {
 	Name string `yaml:"name"`
+	// Registry is the URL of a private package index implementing the simple
+	// repository API (e.g. "https://pypi.example.com/simple"). Defaults to pypi.org.
+	Registry string `yaml:"registry"`
 }
 
 var _ Cacheable = (*PyPIConfig)(nil)
 
 func (p PyPIConfig) CacheKey() string {
-	return p.Name
+	return withRegistry(p.Name, p.Registry)
+}
+
+// withRegistry qualifies a cache key with a private registry URL, if set.
+func withRegistry(key string, registry string) string {
+	if registry == "" {
+		return key
+	}
+	return key + "@" + registry
+}
+
+// HTTPConfig extracts versions from a document served over HTTP, for upstreams which
+// don't publish to a package registry (e.g. a JSON endpoint or a text file in a
+// repository). Exactly one of JSONPath or Regex must be set.
+type HTTPConfig struct {
+	URL string `yaml:"url"`
+	// JSONPath selects the versions in a JSON document (e.g. "$.releases[*].version").
+	JSONPath string `yaml:"json_path"`
+	// Regex matches the versions in the document. If it has capture groups, the group
+	// named "version" or else the first group is the version.
+	Regex string `yaml:"regex"`
+}
+
+var _ Cacheable = (*HTTPConfig)(nil)
+
+func (h HTTPConfig) CacheKey() string {
+	if h.JSONPath != "" {
+		return h.URL + "#" + h.JSONPath
+	}
+	return h.URL + "#" + h.Regex
+}
+
+// OCIConfig lists the tags of an image in an OCI distribution registry.
+type OCIConfig struct {
+	// Image is the image repository (e.g. "ghcr.io/acme/protoc-gen-foo").
+	Image string `yaml:"image"`
+}
+
+var _ Cacheable = (*OCIConfig)(nil)
+
+func (o OCIConfig) CacheKey() string {
+	return o.Image
 }
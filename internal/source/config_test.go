@@ -23,3 +23,71 @@ func TestConfigWithUpdateFrequency(t *testing.T) {
 	assert.Equal(t, Duration(30*24*time.Hour), *config.Source.UpdateFrequency)
 	assert.Equal(t, "test", config.Source.GitHub.Owner)
 }
+
+func TestConfigWithVerification(t *testing.T) {
+	t.Parallel()
+	config, err := NewConfig(strings.NewReader(`source:
+  verification: required
+  npm_registry:
+    name: "@bufbuild/protoc-gen-es"
+    repository: https://github.com/bufbuild/protobuf-es
+`))
+	require.NoError(t, err)
+	assert.Equal(t, VerificationRequired, config.Source.Verification)
+	assert.Equal(t, "https://github.com/bufbuild/protobuf-es", config.Source.NPMRegistry.Repository)
+
+	_, err = NewConfig(strings.NewReader(`source:
+  verification: sometimes
+  npm_registry:
+    name: "@bufbuild/protoc-gen-es"
+`))
+	require.ErrorContains(t, err, `invalid verification level "sometimes"`)
+}
+
+func TestConfigWithPostProcess(t *testing.T) {
+	t.Parallel()
+	config, err := NewConfig(strings.NewReader(`source:
+  npm_registry:
+    name: "@bufbuild/protoc-gen-es"
+post_process:
+  - maven_deps
+  - name: npm_lock
+    image: node:24.11.1-trixie
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []PostProcessStep{
+		{Name: PostProcessMavenDeps},
+		{Name: PostProcessNPMLock, Image: "node:24.11.1-trixie"},
+	}, config.PostProcess)
+
+	for _, invalid := range []string{
+		"post_process: [yarn_lock]",
+		"post_process: [{name: npm_lock, tag: latest}]",
+		"post_process: [npm_lock, npm_lock]",
+	} {
+		_, err = NewConfig(strings.NewReader("source:\n  github:\n    owner: test\n    repository: test-repo\n" + invalid + "\n"))
+		require.Error(t, err, invalid)
+	}
+	_, err = NewConfig(strings.NewReader("post_process: [yarn_lock]\n"))
+	require.ErrorContains(t, err, `unknown post_process step "yarn_lock"`)
+}
+
+func TestConfigWithHTTPAndOCI(t *testing.T) {
+	t.Parallel()
+	config, err := NewConfig(strings.NewReader(`source:
+  http:
+    url: https://example.com/releases.json
+    json_path: $.releases[*].tag
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "http", config.Source.Name())
+	assert.Equal(t, "http-https://example.com/releases.json#$.releases[*].tag", config.Source.CacheKey())
+
+	config, err = NewConfig(strings.NewReader(`source:
+  oci:
+    image: ghcr.io/acme/protoc-gen-foo
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "oci", config.Source.Name())
+	assert.Equal(t, "oci-ghcr.io/acme/protoc-gen-foo", config.Source.CacheKey())
+}
@@ -0,0 +1,100 @@
+package source
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IgnoreVersion adds the version to the ignore_versions of the source.yaml content,
+// preceded by the reason as a comment. The rest of the content is left unchanged, to
+// preserve its formatting and comments. The content is returned unchanged if the
+// version is already ignored.
+func IgnoreVersion(content []byte, version string, reason string) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return nil, errors.New("empty source config")
+	}
+	source := mappingValue(document.Content[0], "source")
+	if source == nil || source.Kind != yaml.MappingNode || len(source.Content) == 0 {
+		return nil, errors.New("no source mapping")
+	}
+	// line is the 1-based line after which the entry is inserted, and indent is
+	// the indentation of the entry's "-".
+	var (
+		line   int
+		indent int
+		lines  []string
+	)
+	ignoreVersions := mappingValue(source, "ignore_versions")
+	switch {
+	case ignoreVersions == nil:
+		line = lastLine(source)
+		keyIndent := source.Content[0].Column - 1
+		indent = keyIndent + 2
+		lines = append(lines, strings.Repeat(" ", keyIndent)+"ignore_versions:")
+	case ignoreVersions.Kind != yaml.SequenceNode || ignoreVersions.Style&yaml.FlowStyle != 0 || len(ignoreVersions.Content) == 0:
+		return nil, errors.New("ignore_versions must be a block sequence")
+	default:
+		for _, node := range ignoreVersions.Content {
+			if node.Value == version {
+				return content, nil
+			}
+		}
+		last := ignoreVersions.Content[len(ignoreVersions.Content)-1]
+		line = lastLine(last)
+		// Items are written as "- <version>".
+		indent = max(last.Column-3, 0)
+	}
+	for reasonLine := range strings.Lines(reason) {
+		lines = append(lines, strings.Repeat(" ", indent)+strings.TrimRight("# "+strings.TrimSpace(reasonLine), " "))
+	}
+	lines = append(lines, strings.Repeat(" ", indent)+"- "+version)
+
+	contentLines := strings.SplitAfter(string(content), "\n")
+	if line > len(contentLines) {
+		return nil, fmt.Errorf("invalid line %d", line)
+	}
+	if !strings.HasSuffix(contentLines[line-1], "\n") {
+		contentLines[line-1] += "\n"
+	}
+	inserted := make([]string, len(lines))
+	for i, l := range lines {
+		inserted[i] = l + "\n"
+	}
+	contentLines = slices.Insert(contentLines, line, inserted...)
+	var buffer bytes.Buffer
+	for _, l := range contentLines {
+		buffer.WriteString(l)
+	}
+	return buffer.Bytes(), nil
+}
+
+// mappingValue returns the value of the key in a mapping node, or nil if not found.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// lastLine returns the last line of a node and its children.
+func lastLine(node *yaml.Node) int {
+	line := node.Line
+	for _, child := range node.Content {
+		line = max(line, lastLine(child))
+	}
+	return line
+}
@@ -0,0 +1,100 @@
+package source
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreVersion(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "new ignore_versions",
+			content: `source:
+  github:
+    owner: acme
+    repository: plugin
+`,
+			want: `source:
+  github:
+    owner: acme
+    repository: plugin
+  ignore_versions:
+    # make test failed
+    - v1.1.0
+`,
+		},
+		{
+			name: "existing ignore_versions",
+			content: `source:
+  ignore_versions:
+    # https://github.com/acme/plugin/issues/1
+    - v1.0.0
+  github:
+    owner: acme
+    repository: plugin
+`,
+			want: `source:
+  ignore_versions:
+    # https://github.com/acme/plugin/issues/1
+    - v1.0.0
+    # make test failed
+    - v1.1.0
+  github:
+    owner: acme
+    repository: plugin
+`,
+		},
+		{
+			name: "without trailing newline",
+			content: `source:
+  maven:
+    group: io.acme
+    name: plugin`,
+			want: `source:
+  maven:
+    group: io.acme
+    name: plugin
+  ignore_versions:
+    # make test failed
+    - v1.1.0
+`,
+		},
+		{
+			name: "already ignored",
+			content: `source:
+  ignore_versions:
+    - v1.1.0
+  npm_registry:
+    name: plugin
+`,
+			want: `source:
+  ignore_versions:
+    - v1.1.0
+  npm_registry:
+    name: plugin
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			content, err := IgnoreVersion([]byte(tt.content), "v1.1.0", "make test failed")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(content))
+			config, err := NewConfig(bytes.NewReader(content))
+			require.NoError(t, err)
+			assert.Contains(t, config.Source.IgnoreVersions, "v1.1.0")
+		})
+	}
+
+	_, err := IgnoreVersion([]byte("source:\n  ignore_versions: []\n"), "v1.1.0", "make test failed")
+	require.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package source
+
+import (
+	"fmt"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PostProcessStepName is the name of a step run on a new version of a plugin after it
+// is created, before it is tested.
+type PostProcessStepName string
+
+const (
+	// PostProcessMavenDeps regenerates the pom.xml from the registry.maven config.
+	PostProcessMavenDeps PostProcessStepName = "maven_deps"
+	// PostProcessNugetDeps regenerates the build.csproj from the registry.nuget config.
+	PostProcessNugetDeps PostProcessStepName = "nuget_deps"
+	// PostProcessGoModTidy runs 'go mod tidy' on the checked in go.mod and go.sum.
+	PostProcessGoModTidy PostProcessStepName = "go_mod_tidy"
+	// PostProcessNPMLock recreates the package-lock.json.
+	PostProcessNPMLock PostProcessStepName = "npm_lock"
+	// PostProcessPythonRequirements recompiles the requirements.txt with hashes.
+	PostProcessPythonRequirements PostProcessStepName = "python_requirements"
+	// PostProcessSwiftResolve recreates the Package.resolved.
+	PostProcessSwiftResolve PostProcessStepName = "swift_resolve"
+	// PostProcessCargoLock regenerates the Cargo.lock.
+	PostProcessCargoLock PostProcessStepName = "cargo_lock"
+	// PostProcessGoMinVersion bumps registry.go.min_version to the Go version required by the deps.
+	PostProcessGoMinVersion PostProcessStepName = "go_min_version"
+	// PostProcessBazelShims renders the Bazel BUILD file and shim of protocolbuffers plugins.
+	PostProcessBazelShims PostProcessStepName = "bazel_shims"
+	// PostProcessPatches applies the patches to the upstream source cloned by the Dockerfile,
+	// and refreshes the context of the patches applying with offsets or fuzz.
+	PostProcessPatches PostProcessStepName = "patches"
+)
+
+// PostProcessStepNames are the names of all post-processing steps.
+var PostProcessStepNames = []PostProcessStepName{
+	PostProcessMavenDeps,
+	PostProcessNugetDeps,
+	PostProcessGoModTidy,
+	PostProcessNPMLock,
+	PostProcessPythonRequirements,
+	PostProcessSwiftResolve,
+	PostProcessCargoLock,
+	PostProcessGoMinVersion,
+	PostProcessBazelShims,
+	PostProcessPatches,
+}
+
+// PostProcessStep is a post-processing step declared in a source config. It is either
+// the name of the step, or a mapping with the name and the image to run the step in:
+//
+//	post_process:
+//	  - maven_deps
+//	  - name: npm_lock
+//	    image: node:24.11.1-trixie
+type PostProcessStep struct {
+	Name PostProcessStepName
+	// Image is the container image the step runs in. If empty, the step runs in the image
+	// of the plugin's Dockerfile for its toolchain (such as "node" for npm_lock).
+	Image string
+}
+
+func (s *PostProcessStep) UnmarshalYAML(value *yaml.Node) error {
+	var step PostProcessStep
+	switch value.Kind {
+	case yaml.ScalarNode:
+		var name string
+		if err := value.Decode(&name); err != nil {
+			return err
+		}
+		step.Name = PostProcessStepName(name)
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			key, field := value.Content[i], value.Content[i+1]
+			var err error
+			switch key.Value {
+			case "name":
+				err = field.Decode(&step.Name)
+			case "image":
+				err = field.Decode(&step.Image)
+			default:
+				return fmt.Errorf("line %d: field %s not found in post_process step", key.Line, key.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("line %d: post_process step must be a name or a mapping", value.Line)
+	}
+	if !slices.Contains(PostProcessStepNames, step.Name) {
+		return fmt.Errorf("line %d: unknown post_process step %q: must be one of %q", value.Line, step.Name, PostProcessStepNames)
+	}
+	*s = step
+	return nil
+}
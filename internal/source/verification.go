@@ -0,0 +1,35 @@
+package source
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// VerificationLevel is the level of upstream provenance verification (signatures,
+// attestations or checksum database inclusion) required before a version is adopted.
+type VerificationLevel string
+
+const (
+	// VerificationNone doesn't verify upstream provenance. This is the default.
+	VerificationNone VerificationLevel = "none"
+	// VerificationIfAvailable verifies all provenance published by the upstream source,
+	// but allows versions for which none is published.
+	VerificationIfAvailable VerificationLevel = "if_available"
+	// VerificationRequired requires the upstream source to publish provenance for the
+	// version, and verifies it.
+	VerificationRequired VerificationLevel = "required"
+)
+
+func (v *VerificationLevel) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+	switch level := VerificationLevel(s); level {
+	case VerificationNone, VerificationIfAvailable, VerificationRequired:
+		*v = level
+		return nil
+	}
+	return fmt.Errorf("invalid verification level %q: must be one of %q, %q or %q", s, VerificationNone, VerificationIfAvailable, VerificationRequired)
+}
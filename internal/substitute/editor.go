@@ -0,0 +1,96 @@
+package substitute
+
+import (
+	"slices"
+	"strings"
+)
+
+// edit is a version substitution at a position of a line.
+type edit struct {
+	match
+	line int
+	rule string
+}
+
+// editor collects the substitutions made in the lines of a file.
+type editor struct {
+	substituter *Substituter
+	lines       []string
+	edits       []edit
+}
+
+func newEditor(substituter *Substituter, content []byte) *editor {
+	return &editor{
+		substituter: substituter,
+		lines:       strings.SplitAfter(string(content), "\n"),
+	}
+}
+
+// substitute rewrites the versions in lines[line][start:end].
+func (e *editor) substitute(line int, start int, end int, rule string) {
+	text := e.lines[line]
+	end = min(end, len(text))
+	if start >= end {
+		return
+	}
+	for _, m := range e.substituter.find(text[start:end]) {
+		m.start += start
+		m.end += start
+		if slices.ContainsFunc(e.edits, func(other edit) bool {
+			return other.line == line && other.start < m.end && m.start < other.end
+		}) {
+			continue
+		}
+		e.edits = append(e.edits, edit{match: m, line: line, rule: rule})
+	}
+}
+
+// substituteLine rewrites the versions in a whole line.
+func (e *editor) substituteLine(line int, rule string) {
+	e.substitute(line, 0, len(e.lines[line]), rule)
+}
+
+// markers rewrites the lines following a Marker.
+func (e *editor) markers() {
+	for i := 0; i+1 < len(e.lines); i++ {
+		if strings.Contains(e.lines[i], Marker) {
+			e.substituteLine(i+1, "marker")
+		}
+	}
+}
+
+// result applies the edits, reporting the lines which still reference the previous
+// version if reportUnchanged is set.
+func (e *editor) result(reportUnchanged bool) *Result {
+	slices.SortFunc(e.edits, func(a, b edit) int {
+		if a.line != b.line {
+			return a.line - b.line
+		}
+		return a.start - b.start
+	})
+	result := &Result{}
+	lines := slices.Clone(e.lines)
+	// Apply the edits from the end, so the positions of earlier edits stay valid.
+	for _, edit := range slices.Backward(e.edits) {
+		text := lines[edit.line]
+		lines[edit.line] = text[:edit.start] + edit.new + text[edit.end:]
+	}
+	for _, edit := range e.edits {
+		result.Substitutions = append(result.Substitutions, Substitution{
+			Line: edit.line + 1,
+			Old:  edit.old,
+			New:  edit.new,
+			Rule: edit.rule,
+		})
+	}
+	for i, line := range lines {
+		if reportUnchanged && len(e.substituter.find(line)) > 0 {
+			result.Unchanged = append(result.Unchanged, Occurrence{
+				Line: i + 1,
+				Text: strings.TrimSpace(line),
+			})
+		}
+	}
+	result.Content = []byte(strings.Join(lines, ""))
+	return result
+}
@@ -0,0 +1,335 @@
+package substitute
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// shellSeparatorPattern separates the commands of a shell command line.
+	shellSeparatorPattern = regexp.MustCompile(`&&|\|\||[;|]`)
+	// requirementNamePattern matches the project name of a requirements specifier.
+	requirementNamePattern = regexp.MustCompile(`^\s*([A-Za-z0-9][A-Za-z0-9._-]*)`)
+	// packageReferencePattern matches a NuGet package reference of a csproj file.
+	packageReferencePattern = regexp.MustCompile(`<PackageReference\s+Include="([^"]+)"\s+Version="([^"]+)"`)
+	// packageJSONDependencyKeys are the package.json members declaring dependencies.
+	packageJSONDependencyKeys = map[string]struct{}{
+		"dependencies":         {},
+		"devDependencies":      {},
+		"peerDependencies":     {},
+		"optionalDependencies": {},
+	}
+	// bufPluginYAMLVersionKeys are the buf.plugin.yaml members which are always versioned
+	// with the plugin.
+	bufPluginYAMLVersionKeys = map[string]struct{}{
+		"plugin_version":        {},
+		"source_url":            {},
+		"license_url":           {},
+		"integration_guide_url": {},
+	}
+)
+
+// dockerfile rewrites versions in the instructions of a Dockerfile, other than FROM.
+// A shell command (or an ARG or ENV value) is rewritten if it references the upstream
+// project. ARG and ENV values are also rewritten if their name contains "VERSION".
+// Comments are left unchanged.
+func (e *editor) dockerfile() {
+	for first := 0; first < len(e.lines); {
+		last := first
+		for last+1 < len(e.lines) && strings.HasSuffix(strings.TrimRight(e.lines[last], " \t\r\n"), `\`) {
+			last++
+		}
+		keyword, _, _ := strings.Cut(strings.TrimSpace(e.lines[first]), " ")
+		switch keyword = strings.ToUpper(keyword); {
+		case keyword == "" || strings.HasPrefix(keyword, "#") || keyword == "FROM":
+		case keyword == "ARG" || keyword == "ENV":
+			for line := first; line <= last; line++ {
+				for _, field := range fieldIndexes(e.lines[line]) {
+					name, value, ok := strings.Cut(e.lines[line][field[0]:field[1]], "=")
+					if ok && (strings.Contains(strings.ToUpper(name), "VERSION") || e.substituter.references(value)) {
+						e.substitute(line, field[0], field[1], keyword)
+					}
+				}
+			}
+		default:
+			e.shellCommands(first, last, keyword)
+		}
+		first = last + 1
+	}
+}
+
+// shellCommands rewrites the commands of lines[first:last+1] which reference the
+// upstream project.
+func (e *editor) shellCommands(first int, last int, rule string) {
+	text := strings.Join(e.lines[first:last+1], "")
+	commandStart := 0
+	separators := append(shellSeparatorPattern.FindAllStringIndex(text, -1), []int{len(text), len(text)})
+	for _, separator := range separators {
+		command := text[commandStart:separator[0]]
+		if e.substituter.references(command) {
+			// Rewrite the parts of the command on each line.
+			lineStart := 0
+			for line := first; line <= last; line++ {
+				lineEnd := lineStart + len(e.lines[line])
+				if commandStart < lineEnd && separator[0] > lineStart {
+					e.substitute(line, max(commandStart, lineStart)-lineStart, min(separator[0], lineEnd)-lineStart, rule)
+				}
+				lineStart = lineEnd
+			}
+		}
+		commandStart = separator[1]
+	}
+}
+
+// bufPluginYAML rewrites the version of buf.plugin.yaml, its URLs, and the registry
+// dependencies and comments which reference the upstream project. Plugin dependencies
+// are not rewritten: they are updated to the latest version of each plugin.
+func (e *editor) bufPluginYAML() error {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(strings.Join(e.lines, "")), &document); err != nil {
+		return err
+	}
+	if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return errors.New("expected a mapping")
+	}
+	root := document.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if _, ok := bufPluginYAMLVersionKeys[key.Value]; ok {
+			e.substituteScalar(value, key.Value)
+		}
+		if key.Value == "registry" {
+			e.registryDeps(value)
+		}
+	}
+	for line, text := range e.lines {
+		if index := yamlCommentIndex(text); index != -1 && e.substituter.references(text[index:]) {
+			e.substitute(line, index, len(text), "comment")
+		}
+	}
+	return nil
+}
+
+// registryDeps rewrites the items of the "deps" sequences of a registry configuration
+// which reference the upstream project.
+func (e *editor) registryDeps(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value != "deps" || value.Kind != yaml.SequenceNode {
+				e.registryDeps(value)
+				continue
+			}
+			for _, item := range value.Content {
+				scalars := yamlScalars(item)
+				values := make([]string, len(scalars))
+				for i, scalar := range scalars {
+					values[i] = scalar.Value
+				}
+				if !e.substituter.references(strings.Join(values, " ")) {
+					continue
+				}
+				for _, scalar := range scalars {
+					e.substituteScalar(scalar, "registry deps")
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			e.registryDeps(item)
+		}
+	}
+}
+
+// substituteScalar rewrites the versions of a single-line scalar node.
+func (e *editor) substituteScalar(node *yaml.Node, rule string) {
+	if node.Kind != yaml.ScalarNode || node.Line < 1 || node.Line > len(e.lines) || strings.Contains(node.Value, "\n") {
+		return
+	}
+	length := len(node.Value)
+	if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		length += 2
+	}
+	e.substitute(node.Line-1, node.Column-1, node.Column-1+length, rule)
+}
+
+// yamlScalars returns the scalar nodes of a node and its descendants.
+func yamlScalars(node *yaml.Node) []*yaml.Node {
+	if node.Kind == yaml.ScalarNode {
+		return []*yaml.Node{node}
+	}
+	var scalars []*yaml.Node
+	for _, child := range node.Content {
+		scalars = append(scalars, yamlScalars(child)...)
+	}
+	return scalars
+}
+
+// yamlCommentIndex returns the index of the comment of a YAML line, or -1.
+func yamlCommentIndex(line string) int {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return i
+		}
+	}
+	return -1
+}
+
+// packageJSON rewrites the version of package.json, and the dependencies on packages
+// of the upstream project.
+func (e *editor) packageJSON() error {
+	content := strings.Join(e.lines, "")
+	decoder := json.NewDecoder(strings.NewReader(content))
+	// frames are the objects and arrays being decoded, with the member name of
+	// their current value (or "[]" in arrays).
+	type frame struct {
+		name      string
+		expectKey bool
+	}
+	var frames []*frame
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch token := token.(type) {
+		case json.Delim:
+			switch token {
+			case '{':
+				frames = append(frames, &frame{expectKey: true})
+				continue
+			case '[':
+				frames = append(frames, &frame{name: "[]"})
+				continue
+			}
+			frames = frames[:len(frames)-1]
+		case string:
+			if len(frames) > 0 && frames[len(frames)-1].expectKey {
+				frames[len(frames)-1].name = token
+				frames[len(frames)-1].expectKey = false
+				continue
+			}
+			path := make([]string, len(frames))
+			for i, frame := range frames {
+				path[i] = frame.name
+			}
+			if rule := e.packageJSONRule(path); rule != "" {
+				end := int(decoder.InputOffset())
+				e.substituteOffset(strings.LastIndex(content[:end-1], `"`), end, rule)
+			}
+		}
+		// A value was decoded: the next token of an object is a member name.
+		if len(frames) > 0 && frames[len(frames)-1].name != "[]" {
+			frames[len(frames)-1].expectKey = true
+		}
+	}
+}
+
+// packageJSONRule returns the rule rewriting the value at the path, or an empty string.
+func (e *editor) packageJSONRule(path []string) string {
+	switch len(path) {
+	case 1:
+		if path[0] == "version" {
+			return "$.version"
+		}
+	case 2:
+		if _, ok := packageJSONDependencyKeys[path[0]]; ok && e.substituter.references(path[1]) {
+			return "$." + path[0] + "['" + path[1] + "']"
+		}
+	}
+	return ""
+}
+
+// substituteOffset rewrites the versions of content[start:end], which is on a single line.
+func (e *editor) substituteOffset(start int, end int, rule string) {
+	lineStart := 0
+	for line, text := range e.lines {
+		if start < lineStart+len(text) {
+			e.substitute(line, start-lineStart, end-lineStart, rule)
+			return
+		}
+		lineStart += len(text)
+	}
+}
+
+// requirements rewrites the specifiers of the requirements on projects of the upstream
+// project.
+func (e *editor) requirements() {
+	for line, text := range e.lines {
+		requirement, _, _ := strings.Cut(text, "#")
+		name := requirementNamePattern.FindStringSubmatchIndex(requirement)
+		if name == nil || !e.substituter.references(requirement[name[2]:name[3]]) {
+			continue
+		}
+		e.substitute(line, name[3], len(requirement), "requirements")
+	}
+}
+
+// csproj rewrites the versions of the NuGet package references on packages of the
+// upstream project.
+func (e *editor) csproj() {
+	for line, text := range e.lines {
+		for _, reference := range packageReferencePattern.FindAllStringSubmatchIndex(text, -1) {
+			if e.substituter.references(text[reference[2]:reference[3]]) {
+				e.substitute(line, reference[4], reference[5], "PackageReference")
+			}
+		}
+	}
+}
+
+// pom rewrites the versions of the Maven dependencies on artifacts of the upstream project.
+func (e *editor) pom() {
+	first := -1
+	for line, text := range e.lines {
+		if strings.Contains(text, "<dependency>") {
+			first = line
+		}
+		if !strings.Contains(text, "</dependency>") || first == -1 {
+			continue
+		}
+		if e.substituter.references(strings.Join(e.lines[first:line+1], "")) {
+			for versionLine := first; versionLine <= line; versionLine++ {
+				if start := strings.LastIndex(e.lines[versionLine], "<version>"); start != -1 {
+					e.substitute(versionLine, start, len(e.lines[versionLine]), "dependency")
+				}
+			}
+		}
+		first = -1
+	}
+}
+
+// fieldIndexes returns the start and end indexes of the whitespace-separated fields of a line.
+func fieldIndexes(line string) [][2]int {
+	var fields [][2]int
+	start := -1
+	for i := 0; i <= len(line); i++ {
+		if i == len(line) || line[i] == ' ' || line[i] == '\t' || line[i] == '\n' || line[i] == '\r' {
+			if start != -1 {
+				fields = append(fields, [2]int{start, i})
+				start = -1
+			}
+			continue
+		}
+		if start == -1 {
+			start = i
+		}
+	}
+	return fields
+}
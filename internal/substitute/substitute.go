@@ -0,0 +1,255 @@
+// Package substitute rewrites the version of a plugin in the files of its previous
+// version, when creating a new version.
+//
+// Versions are only rewritten where the file format says they refer to the plugin or
+// its upstream project: install commands in Dockerfiles, the version and dependencies
+// of package.json, requirements specifiers, and the version, URLs, and registry
+// dependencies of buf.plugin.yaml. Other lines of any file can opt in with a Marker
+// comment on the preceding line.
+package substitute
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+// Marker opts the following line of a file into version substitution: every
+// occurrence of the previous version on that line is rewritten. It must be written
+// in a comment, such as "# fetcher:version".
+const Marker = "fetcher:version"
+
+// prereleaseSuffixes follow a version to form a different (pre-release) version.
+var prereleaseSuffixes = []string{"-alpha", "-beta", "-rc", "-pre", "-dev", "-snapshot"}
+
+// Substitution is a version rewritten in a file.
+type Substitution struct {
+	// Line is the 1-based line number.
+	Line int
+	Old  string
+	New  string
+	// Rule describes why the version was rewritten, such as "RUN" or "$.version".
+	Rule string
+}
+
+func (s Substitution) String() string {
+	return fmt.Sprintf("line %d: %s → %s (%s)", s.Line, s.Old, s.New, s.Rule)
+}
+
+// Occurrence is a previous version left unchanged in a file.
+type Occurrence struct {
+	// Line is the 1-based line number.
+	Line int
+	Text string
+}
+
+// Result is the result of substituting the version in a file.
+type Result struct {
+	Content       []byte
+	Substitutions []Substitution
+	// Unchanged are the lines which still reference the previous version.
+	Unchanged []Occurrence
+}
+
+// Substituter rewrites a previous plugin version to a new version.
+type Substituter struct {
+	previousVersion string
+	newVersion      string
+	// majorPrefixed is set if versions written as "<major>.<version>" are rewritten
+	// too, for two-component versions such as protobuf's ("v29.1" is "4.29.1" in Maven).
+	majorPrefixed bool
+	// names identify the upstream project: a dependency or command referencing
+	// one of them is considered to be versioned with the plugin.
+	names []string
+}
+
+// New returns a Substituter rewriting previousVersion to newVersion, with or
+// without the "v" prefix. The upstream names are typically from UpstreamNames:
+// if empty, all versions in the rewritten parts of a file are considered to
+// reference the upstream project.
+func New(previousVersion string, newVersion string, upstreamNames []string) *Substituter {
+	previousVersion = strings.TrimPrefix(previousVersion, "v")
+	newVersion = strings.TrimPrefix(newVersion, "v")
+	names := make([]string, 0, len(upstreamNames))
+	for _, name := range upstreamNames {
+		names = append(names, strings.ToLower(name))
+	}
+	return &Substituter{
+		previousVersion: previousVersion,
+		newVersion:      newVersion,
+		majorPrefixed:   strings.Count(previousVersion, ".") == 1 && strings.Count(newVersion, ".") == 1,
+		names:           names,
+	}
+}
+
+// NewVersion returns the new version, without the "v" prefix.
+func (s *Substituter) NewVersion() string {
+	return s.newVersion
+}
+
+// UpstreamNames returns the names identifying the upstream project of a source: its
+// names and owner, without any "protoc-gen-" prefix, and their first hyphenated word
+// ("protobuf" for "protobuf-go", matching "google.golang.org/protobuf"). Names shorter
+// than 3 characters and words shorter than 5 characters are dropped, as they would
+// match unrelated dependencies.
+func UpstreamNames(config source.Source) []string {
+	var names []string
+	add := func(values ...string) {
+		for _, value := range values {
+			value = strings.TrimPrefix(value, "protoc-gen-")
+			names = append(names, value)
+			if word, _, ok := strings.Cut(value, "-"); ok && len(word) >= 5 && !strings.ContainsAny(word, "@/") {
+				names = append(names, word)
+			}
+		}
+	}
+	switch {
+	case config.GitHub != nil:
+		add(config.GitHub.Owner, config.GitHub.Repository)
+	case config.DartFlutter != nil:
+		add(config.DartFlutter.Name)
+	case config.GoProxy != nil:
+		add(config.GoProxy.Name, path.Base(config.GoProxy.Name))
+	case config.NPMRegistry != nil:
+		scope, name, ok := strings.Cut(config.NPMRegistry.Name, "/")
+		if ok {
+			add(config.NPMRegistry.Name, strings.TrimPrefix(scope, "@"), name)
+		} else {
+			add(config.NPMRegistry.Name)
+		}
+	case config.Maven != nil:
+		add(config.Maven.Group, strings.ReplaceAll(config.Maven.Group, ".", "/"), config.Maven.Name)
+	case config.Crates != nil:
+		add(config.Crates.CrateName)
+	case config.PyPI != nil:
+		add(config.PyPI.Name)
+	case config.OCI != nil:
+		add(path.Base(config.OCI.Image))
+	}
+	names = slices.DeleteFunc(names, func(name string) bool {
+		return len(name) < 3
+	})
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// File substitutes the version in the content of a file, based on its name.
+// Files of an unknown type are only rewritten on the lines following a Marker,
+// and the occurrences of the previous version they still contain are not reported.
+func (s *Substituter) File(filename string, content []byte) (*Result, error) {
+	e := newEditor(s, content)
+	known := true
+	var err error
+	switch filename := filepath.Base(filename); {
+	case strings.HasPrefix(filename, "Dockerfile"):
+		e.dockerfile()
+	case filename == "buf.plugin.yaml":
+		err = e.bufPluginYAML()
+	case filename == "package.json":
+		err = e.packageJSON()
+	case filename == "requirements.txt" || filename == "requirements.in":
+		e.requirements()
+	case filename == "build.csproj":
+		e.csproj()
+	case filename == "pom.xml":
+		e.pom()
+	default:
+		known = false
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to substitute version in %s: %w", filename, err)
+	}
+	e.markers()
+	return e.result(known), nil
+}
+
+// references returns true if the text references the upstream project.
+func (s *Substituter) references(text string) bool {
+	if len(s.names) == 0 {
+		return true
+	}
+	text = strings.ToLower(text)
+	for _, name := range s.names {
+		if strings.Contains(text, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// match is an occurrence of the previous version in a text.
+type match struct {
+	start, end int
+	old, new   string
+}
+
+// find returns the occurrences of the previous version in the text. A version is only
+// matched as a whole: "1.2.3" doesn't match in "11.2.3", "1.2.30", or "1.2.3-rc.1".
+func (s *Substituter) find(text string) []match {
+	if s.previousVersion == "" {
+		return nil
+	}
+	var matches []match
+	for offset := 0; ; {
+		index := strings.Index(text[offset:], s.previousVersion)
+		if index == -1 {
+			return matches
+		}
+		start := offset + index
+		end := start + len(s.previousVersion)
+		offset = start + 1
+		if !versionEndsAt(text, end) {
+			continue
+		}
+		m := match{start: start, end: end, old: s.previousVersion, new: s.newVersion}
+		if start > 0 {
+			switch c := text[start-1]; {
+			case isDigit(c) && !(start >= 3 && text[start-3] == '%'):
+				// Digits may only precede a version in a percent-encoded character ("%40").
+				continue
+			case c == '.':
+				// Only "<major>.<version>" is another form of a two-component version.
+				major := start - 1
+				for major > 0 && isDigit(text[major-1]) {
+					major--
+				}
+				if !s.majorPrefixed || major == start-1 || (major > 0 && (isDigit(text[major-1]) || text[major-1] == '.')) {
+					continue
+				}
+				m.start, m.old, m.new = major, text[major:end], text[major:start]+s.newVersion
+			case c == 'v':
+				m.start, m.old, m.new = start-1, "v"+m.old, "v"+m.new
+			}
+		}
+		matches = append(matches, m)
+	}
+}
+
+// versionEndsAt returns true if a version in the text can end at the index.
+func versionEndsAt(text string, end int) bool {
+	if end == len(text) {
+		return true
+	}
+	rest := text[end:]
+	switch {
+	case isDigit(rest[0]), rest[0] == '+':
+		return false
+	case rest[0] == '.':
+		return len(rest) == 1 || !isDigit(rest[1])
+	}
+	lower := strings.ToLower(rest)
+	for _, suffix := range prereleaseSuffixes {
+		if strings.HasPrefix(lower, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
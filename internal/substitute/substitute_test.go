@@ -0,0 +1,315 @@
+package substitute
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bufbuild/plugins/internal/source"
+)
+
+func TestFind(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		previous, new string
+		text          string
+		want          []match
+	}{
+		{previous: "v1.2.3", new: "v1.3.0", text: "pkg@v1.2.3", want: []match{{start: 4, end: 10, old: "v1.2.3", new: "v1.3.0"}}},
+		{previous: "v1.2.3", new: "v1.3.0", text: "pkg==1.2.3", want: []match{{start: 5, end: 10, old: "1.2.3", new: "1.3.0"}}},
+		{previous: "v1.2.3", new: "v1.3.0", text: "archive-1.2.3.tar.gz", want: []match{{start: 8, end: 13, old: "1.2.3", new: "1.3.0"}}},
+		{previous: "v1.2.3", new: "v1.3.0", text: "grpc-tools%401.2.3", want: []match{{start: 13, end: 18, old: "1.2.3", new: "1.3.0"}}},
+		{previous: "v1.2.3", new: "v1.3.0", text: "11.2.3 1.2.30 1.2.3.4 1.2.3-rc.1 1.2.3+build"},
+		// Two-component versions are also written with a major version prefix.
+		{previous: "v29.1", new: "v29.2", text: "protobuf-java:4.29.1", want: []match{{start: 14, end: 20, old: "4.29.1", new: "4.29.2"}}},
+		{previous: "v29.1", new: "v29.2", text: "1.4.29.1 v29.10"},
+		{previous: "v1.29.1", new: "v1.29.2", text: "4.29.1"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, New(tt.previous, tt.new, nil).find(tt.text), tt.text)
+	}
+}
+
+func TestUpstreamNames(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, []string{"grpc-gateway"}, UpstreamNames(source.Source{
+		GitHub: &source.GitHubConfig{Owner: "go", Repository: "grpc-gateway"},
+	}))
+	assert.Equal(t, []string{"connectrpc", "connectrpc-codegen"}, UpstreamNames(source.Source{
+		Crates: &source.CratesConfig{CrateName: "connectrpc-codegen"},
+	}))
+	assert.Equal(t, []string{"@bufbuild/protoc-gen-es", "bufbuild"}, UpstreamNames(source.Source{
+		NPMRegistry: &source.NPMRegistryConfig{Name: "@bufbuild/protoc-gen-es"},
+	}))
+	assert.Equal(t, []string{"grpc-java", "io.grpc", "io/grpc"}, UpstreamNames(source.Source{
+		Maven: &source.MavenConfig{Group: "io.grpc", Name: "protoc-gen-grpc-java"},
+	}))
+}
+
+func TestFile(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		filename      string
+		previous, new string
+		upstream      source.Source
+		content       string
+		want          string
+		substitutions []Substitution
+		unchanged     []Occurrence
+	}{
+		{
+			name:     "dockerfile",
+			filename: "Dockerfile",
+			previous: "v1.28.1",
+			new:      "v1.29.0",
+			upstream: source.Source{GitHub: &source.GitHubConfig{Owner: "grpc", Repository: "grpc-web"}},
+			content: `# syntax=docker/dockerfile:1.19
+FROM golang:1.28.1-bookworm AS build
+ARG PLUGIN_VERSION=1.28.1
+ARG OTHER=1.28.1
+RUN curl -fsSL -o /usr/local/bin/bazelisk https://github.com/bazelbuild/bazelisk/releases/download/v1.28.1/bazelisk-linux-amd64 \
+ && curl -fsSL -o grpc-web.tar.gz https://github.com/grpc/grpc-web/archive/1.28.1.tar.gz \
+ && tar -xzf grpc-web.tar.gz
+WORKDIR /build/grpc-web-1.28.1
+`,
+			want: `# syntax=docker/dockerfile:1.19
+FROM golang:1.28.1-bookworm AS build
+ARG PLUGIN_VERSION=1.29.0
+ARG OTHER=1.28.1
+RUN curl -fsSL -o /usr/local/bin/bazelisk https://github.com/bazelbuild/bazelisk/releases/download/v1.28.1/bazelisk-linux-amd64 \
+ && curl -fsSL -o grpc-web.tar.gz https://github.com/grpc/grpc-web/archive/1.29.0.tar.gz \
+ && tar -xzf grpc-web.tar.gz
+WORKDIR /build/grpc-web-1.29.0
+`,
+			substitutions: []Substitution{
+				{Line: 3, Old: "1.28.1", New: "1.29.0", Rule: "ARG"},
+				{Line: 6, Old: "1.28.1", New: "1.29.0", Rule: "RUN"},
+				{Line: 8, Old: "1.28.1", New: "1.29.0", Rule: "WORKDIR"},
+			},
+			unchanged: []Occurrence{
+				{Line: 2, Text: "FROM golang:1.28.1-bookworm AS build"},
+				{Line: 4, Text: "ARG OTHER=1.28.1"},
+				{Line: 5, Text: `RUN curl -fsSL -o /usr/local/bin/bazelisk https://github.com/bazelbuild/bazelisk/releases/download/v1.28.1/bazelisk-linux-amd64 \`},
+			},
+		},
+		{
+			name:     "buf.plugin.yaml",
+			filename: "buf.plugin.yaml",
+			previous: "v29.1",
+			new:      "v29.2",
+			upstream: source.Source{GitHub: &source.GitHubConfig{Owner: "protocolbuffers", Repository: "protobuf"}},
+			content: `version: v1
+name: buf.build/protocolbuffers/kotlin
+plugin_version: v29.1
+source_url: https://github.com/protocolbuffers/protobuf
+description: Version v29.1 of the plugin.
+deps:
+  - plugin: buf.build/protocolbuffers/java:v29.1
+license_url: "https://github.com/protocolbuffers/protobuf/blob/v29.1/LICENSE"
+registry:
+  maven:
+    # https://github.com/protocolbuffers/protobuf/blob/v29.1/java/pom.xml
+    deps:
+      - com.google.protobuf:protobuf-kotlin:4.29.1
+      - org.example:unrelated:29.1
+    additional_runtimes:
+      - name: lite
+        deps:
+          - com.google.protobuf:protobuf-kotlin-lite:4.29.1
+`,
+			want: `version: v1
+name: buf.build/protocolbuffers/kotlin
+plugin_version: v29.2
+source_url: https://github.com/protocolbuffers/protobuf
+description: Version v29.1 of the plugin.
+deps:
+  - plugin: buf.build/protocolbuffers/java:v29.1
+license_url: "https://github.com/protocolbuffers/protobuf/blob/v29.2/LICENSE"
+registry:
+  maven:
+    # https://github.com/protocolbuffers/protobuf/blob/v29.2/java/pom.xml
+    deps:
+      - com.google.protobuf:protobuf-kotlin:4.29.2
+      - org.example:unrelated:29.1
+    additional_runtimes:
+      - name: lite
+        deps:
+          - com.google.protobuf:protobuf-kotlin-lite:4.29.2
+`,
+			substitutions: []Substitution{
+				{Line: 3, Old: "v29.1", New: "v29.2", Rule: "plugin_version"},
+				{Line: 8, Old: "v29.1", New: "v29.2", Rule: "license_url"},
+				{Line: 11, Old: "v29.1", New: "v29.2", Rule: "comment"},
+				{Line: 13, Old: "4.29.1", New: "4.29.2", Rule: "registry deps"},
+				{Line: 18, Old: "4.29.1", New: "4.29.2", Rule: "registry deps"},
+			},
+			unchanged: []Occurrence{
+				{Line: 5, Text: "description: Version v29.1 of the plugin."},
+				{Line: 7, Text: "- plugin: buf.build/protocolbuffers/java:v29.1"},
+				{Line: 14, Text: "- org.example:unrelated:29.1"},
+			},
+		},
+		{
+			name:     "buf.plugin.yaml dependency mapping",
+			filename: "buf.plugin.yaml",
+			previous: "v1.20.0",
+			new:      "v1.21.0",
+			upstream: source.Source{GitHub: &source.GitHubConfig{Owner: "connectrpc", Repository: "connect-go"}},
+			content: `plugin_version: v1.20.0
+registry:
+  go:
+    deps:
+      - module: connectrpc.com/connect
+        version: v1.20.0
+      - module: example.com/other
+        version: v1.20.0
+`,
+			want: `plugin_version: v1.21.0
+registry:
+  go:
+    deps:
+      - module: connectrpc.com/connect
+        version: v1.21.0
+      - module: example.com/other
+        version: v1.20.0
+`,
+			substitutions: []Substitution{
+				{Line: 1, Old: "v1.20.0", New: "v1.21.0", Rule: "plugin_version"},
+				{Line: 6, Old: "v1.20.0", New: "v1.21.0", Rule: "registry deps"},
+			},
+			unchanged: []Occurrence{{Line: 8, Text: "version: v1.20.0"}},
+		},
+		{
+			name:     "package.json",
+			filename: "package.json",
+			previous: "v2.1.0",
+			new:      "v2.2.0",
+			upstream: source.Source{NPMRegistry: &source.NPMRegistryConfig{Name: "@bufbuild/protoc-gen-es"}},
+			content: `{
+  "name": "plugins-bufbuild-es",
+  "version": "2.1.0",
+  "dependencies": {
+    "@bufbuild/protoc-gen-es": "2.1.0",
+    "@bufbuild/protobuf": "^2.1.0"
+  },
+  "devDependencies": {"esbuild": "^2.1.0"},
+  "files": ["2.1.0"]
+}
+`,
+			want: `{
+  "name": "plugins-bufbuild-es",
+  "version": "2.2.0",
+  "dependencies": {
+    "@bufbuild/protoc-gen-es": "2.2.0",
+    "@bufbuild/protobuf": "^2.2.0"
+  },
+  "devDependencies": {"esbuild": "^2.1.0"},
+  "files": ["2.1.0"]
+}
+`,
+			substitutions: []Substitution{
+				{Line: 3, Old: "2.1.0", New: "2.2.0", Rule: "$.version"},
+				{Line: 5, Old: "2.1.0", New: "2.2.0", Rule: "$.dependencies['@bufbuild/protoc-gen-es']"},
+				{Line: 6, Old: "2.1.0", New: "2.2.0", Rule: "$.dependencies['@bufbuild/protobuf']"},
+			},
+			unchanged: []Occurrence{
+				{Line: 8, Text: `"devDependencies": {"esbuild": "^2.1.0"},`},
+				{Line: 9, Text: `"files": ["2.1.0"]`},
+			},
+		},
+		{
+			name:     "requirements.txt",
+			filename: "requirements.txt",
+			previous: "v5.1.0",
+			new:      "v5.2.0",
+			upstream: source.Source{PyPI: &source.PyPIConfig{Name: "mypy-protobuf"}},
+			content: `mypy-protobuf==5.1.0  # 5.1.0
+types-protobuf==5.1.0
+`,
+			want: `mypy-protobuf==5.2.0  # 5.1.0
+types-protobuf==5.1.0
+`,
+			substitutions: []Substitution{{Line: 1, Old: "5.1.0", New: "5.2.0", Rule: "requirements"}},
+			unchanged: []Occurrence{
+				{Line: 1, Text: "mypy-protobuf==5.2.0  # 5.1.0"},
+				{Line: 2, Text: "types-protobuf==5.1.0"},
+			},
+		},
+		{
+			name:     "hashed requirements.txt",
+			filename: "requirements.txt",
+			previous: "v5.1.0",
+			new:      "v5.2.0",
+			upstream: source.Source{PyPI: &source.PyPIConfig{Name: "mypy-protobuf"}},
+			content: `mypy-protobuf==5.1.0 \
+    --hash=sha256:5a8f
+`,
+			want: `mypy-protobuf==5.2.0 \
+    --hash=sha256:5a8f
+`,
+			substitutions: []Substitution{{Line: 1, Old: "5.1.0", New: "5.2.0", Rule: "requirements"}},
+		},
+		{
+			name:     "requirements.in",
+			filename: "requirements.in",
+			previous: "v5.1.0",
+			new:      "v5.2.0",
+			upstream: source.Source{PyPI: &source.PyPIConfig{Name: "mypy-protobuf"}},
+			content: `mypy-protobuf==5.1.0
+`,
+			want: `mypy-protobuf==5.2.0
+`,
+			substitutions: []Substitution{{Line: 1, Old: "5.1.0", New: "5.2.0", Rule: "requirements"}},
+		},
+		{
+			name:     "build.csproj",
+			filename: "build.csproj",
+			previous: "v29.1",
+			new:      "v30.0",
+			upstream: source.Source{GitHub: &source.GitHubConfig{Owner: "protocolbuffers", Repository: "protobuf"}},
+			content: `<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Include="Google.Protobuf" Version="3.29.1" />
+    <PackageReference Include="Other" Version="29.1" />
+  </ItemGroup>
+</Project>
+`,
+			want: `<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Include="Google.Protobuf" Version="3.30.0" />
+    <PackageReference Include="Other" Version="29.1" />
+  </ItemGroup>
+</Project>
+`,
+			substitutions: []Substitution{{Line: 3, Old: "3.29.1", New: "3.30.0", Rule: "PackageReference"}},
+			unchanged:     []Occurrence{{Line: 4, Text: `<PackageReference Include="Other" Version="29.1" />`}},
+		},
+		{
+			name:     "marker",
+			filename: "Package.swift",
+			previous: "v1.2.3",
+			new:      "v1.3.0",
+			content: `// fetcher:version
+.package(url: "https://github.com/acme/plugin.git", exact: "1.2.3"),
+.package(url: "https://github.com/acme/other.git", exact: "1.2.3"),
+`,
+			want: `// fetcher:version
+.package(url: "https://github.com/acme/plugin.git", exact: "1.3.0"),
+.package(url: "https://github.com/acme/other.git", exact: "1.2.3"),
+`,
+			substitutions: []Substitution{{Line: 2, Old: "1.2.3", New: "1.3.0", Rule: "marker"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := New(tt.previous, tt.new, UpstreamNames(tt.upstream)).File(tt.filename, []byte(tt.content))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(result.Content))
+			assert.Equal(t, tt.substitutions, result.Substitutions)
+			assert.Equal(t, tt.unchanged, result.Unchanged)
+		})
+	}
+}